@@ -0,0 +1,50 @@
+package contentencoding
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithAdvertisement returns a Option to add Accept-Encoding with the supported request codings
+// to responses of OPTIONS requests as RFC 7694 describes, so that clients can discover them, e.g. "br, gzip, zstd".
+// If header is not empty, the codings are also set to the header, for clients looking for a custom one.
+// The headers are set before calling the next handler, which can override them.
+func WithAdvertisement(header string) Option {
+	return func(cfg *config) {
+		cfg.advertise = true
+		cfg.advertiseHeader = header
+	}
+}
+
+// supportedCodings returns the request codings Decode supports, the built-in ones first.
+func (cfg *config) supportedCodings() []string {
+	var codings []string
+	for _, c := range []string{"br", "gzip", "zstd"} {
+		if cfg.builtinEnabled(c) {
+			codings = append(codings, c)
+		}
+	}
+	for _, d := range cfg.decoders {
+		e := CanonicalCoding(d.Encoding)
+		found := false
+		for _, c := range codings {
+			found = found || c == e
+		}
+		if !found {
+			codings = append(codings, e)
+		}
+	}
+	return codings
+}
+
+// advertiseCodings sets the supported codings to the response of an OPTIONS request.
+func (cfg *config) advertiseCodings(w http.ResponseWriter, r *http.Request) {
+	if !cfg.advertise || r.Method != http.MethodOptions {
+		return
+	}
+	v := strings.Join(cfg.supportedCodings(), ", ")
+	w.Header().Set("Accept-Encoding", v)
+	if cfg.advertiseHeader != "" {
+		w.Header().Set(cfg.advertiseHeader, v)
+	}
+}
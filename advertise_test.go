@@ -0,0 +1,48 @@
+package contentencoding_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestWithAdvertisement(t *testing.T) {
+	custom := &contentencoding.Decoder{
+		Encoding: "Custom",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	}
+	tests := []struct {
+		name   string
+		opts   []contentencoding.Option
+		method string
+		want   string
+		custom string
+	}{
+		{"OPTIONS", []contentencoding.Option{contentencoding.WithAdvertisement("")}, http.MethodOptions, "br, gzip, zstd", ""},
+		{"custom header", []contentencoding.Option{contentencoding.WithAdvertisement("X-Accept-Content-Encoding")}, http.MethodOptions, "br, gzip, zstd", "br, gzip, zstd"},
+		{"custom decoder", []contentencoding.Option{contentencoding.WithAdvertisement(""), contentencoding.WithDecoder(custom)}, http.MethodOptions, "br, gzip, zstd, custom", ""},
+		{"encodings", []contentencoding.Option{contentencoding.WithAdvertisement(""), contentencoding.WithEncodings("GZIP")}, http.MethodOptions, "gzip", ""},
+		{"POST", []contentencoding.Option{contentencoding.WithAdvertisement("")}, http.MethodPost, "", ""},
+		{"disabled", nil, http.MethodOptions, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/", nil))
+			if got := rec.Header().Get("Accept-Encoding"); got != tt.want {
+				t.Errorf("Accept-Encoding should be %q but got %q", tt.want, got)
+			}
+			if got := rec.Header().Get("X-Accept-Content-Encoding"); got != tt.custom {
+				t.Errorf("custom header should be %q but got %q", tt.custom, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package contentencoding
+
+import (
+	"sync"
+	"time"
+)
+
+// CodingStats are the statistics of the requests with a Content-Encoding in an interval of StatsAggregator.
+type CodingStats struct {
+	// Requests is the number of decoded and rejected requests.
+	Requests int64
+	// Rejected is the number of requests reported to the error handler before the handler was called.
+	Rejected int64
+	// Failed is the number of decoded requests whose body failed to be read, see Stats.Err.
+	Failed int64
+	// EncodedBytes is the number of encoded bytes read from the decoded requests.
+	EncodedBytes int64
+	// DecodedBytes is the number of decoded bytes read by the handlers.
+	DecodedBytes int64
+}
+
+// ErrorRate returns the ratio of rejected and failed requests to all requests, or 0 if there is no request.
+func (s CodingStats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Rejected+s.Failed) / float64(s.Requests)
+}
+
+// StatsAggregator rolls up the statistics of requests by Content-Encoding, see Stats.ContentEncoding,
+// and reports them every interval, for services that emit periodic log lines instead of running a metrics server.
+// Requests with only identity codings are not counted unless they are rejected.
+type StatsAggregator struct {
+	report func(stats map[string]CodingStats)
+
+	mu    sync.Mutex
+	stats map[string]CodingStats
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewStatsAggregator returns a StatsAggregator that calls report with the statistics of each interval,
+// keyed by Content-Encoding. report is not called for intervals without requests.
+// It panics if interval is not positive.
+func NewStatsAggregator(interval time.Duration, report func(stats map[string]CodingStats)) *StatsAggregator {
+	if interval <= 0 {
+		panic("contentencoding: StatsAggregator interval must be positive")
+	}
+	a := &StatsAggregator{
+		report: report,
+		stats:  make(map[string]CodingStats),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go a.run(interval)
+	return a
+}
+
+// WithStatsAggregator returns a Option to count decoded and rejected requests in a.
+// It is independent of WithStatsHook.
+func WithStatsAggregator(a *StatsAggregator) Option {
+	return func(cfg *config) {
+		cfg.aggregator = a
+	}
+}
+
+// Close stops the reports after reporting the statistics of the current interval.
+func (a *StatsAggregator) Close() error {
+	close(a.stop)
+	<-a.done
+	return nil
+}
+
+func (a *StatsAggregator) run(interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush()
+		case <-a.stop:
+			a.flush()
+			return
+		}
+	}
+}
+
+// flush reports the statistics of the interval and starts the next one.
+func (a *StatsAggregator) flush() {
+	a.mu.Lock()
+	stats := a.stats
+	a.stats = make(map[string]CodingStats)
+	a.mu.Unlock()
+	if len(stats) > 0 {
+		a.report(stats)
+	}
+}
+
+func (a *StatsAggregator) observe(s Stats) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cs := a.stats[s.ContentEncoding]
+	cs.Requests++
+	if s.Err != nil {
+		cs.Failed++
+	}
+	cs.EncodedBytes += s.EncodedBytes
+	cs.DecodedBytes += s.DecodedBytes
+	a.stats[s.ContentEncoding] = cs
+}
+
+func (a *StatsAggregator) reject(key string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cs := a.stats[key]
+	cs.Requests++
+	cs.Rejected++
+	a.stats[key] = cs
+}
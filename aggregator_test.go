@@ -0,0 +1,67 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestStatsAggregator(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string]contentencoding.CodingStats)
+	a := contentencoding.NewStatsAggregator(time.Hour, func(stats map[string]contentencoding.CodingStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		for k, v := range stats {
+			got[k] = v
+		}
+	})
+	h := contentencoding.Decode(
+		contentencoding.WithStatsAggregator(a),
+		contentencoding.WithLimits(contentencoding.Limits{MaxLayers: 1}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+
+	payload := []byte("aggregator test")
+	gz, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", gz},
+		{"x-gzip", gz},
+		{"gzip", gz[:len(gz)-4]},
+		{"gzip, br", gz},
+	}
+	for _, req := range requests {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(req.body))
+		r.Header.Set("Content-Encoding", req.encoding)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	a.Close()
+
+	want := map[string]contentencoding.CodingStats{
+		"gzip":     {Requests: 3, Failed: 1, EncodedBytes: int64(len(gz)*3 - 4), DecodedBytes: int64(len(payload) * 3)},
+		"gzip, br": {Requests: 1, Rejected: 1},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("should be %+v but got %+v", want, got)
+	}
+	if rate := got["gzip"].ErrorRate(); rate != 1.0/3 {
+		t.Errorf("error rate should be 1/3 but got %v", rate)
+	}
+}
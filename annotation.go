@@ -0,0 +1,104 @@
+package contentencoding
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Annotation describes how the body of a request was decoded, for security middleware and access logs.
+// It is attached by WithAnnotation to the decoded requests.
+type Annotation struct {
+	// Codings are the codings of Content-Encoding in the order they were applied.
+	Codings []string
+	// EncodedBytes is the size of the encoded body, Content-Length or -1 if it is unknown while the handler runs,
+	// and the number of bytes read when the handler returns.
+	EncodedBytes int64
+	// DecodedBytes is -1 while the handler runs, and the number of bytes read when the handler returns.
+	DecodedBytes int64
+	// Limits are the limits applied to decoding.
+	Limits Limits
+}
+
+// String returns a in the format of the header of WithAnnotation, a Dictionary of RFC 8941 Structured Field Values:
+//
+//	codings=("gzip" "br"), encoded=1024, decoded=8192, max-decoded=1048576, max-ratio=100.0, max-layers=2
+//
+// Unknown sizes and zero limits are omitted.
+func (a *Annotation) String() string {
+	var b strings.Builder
+	b.WriteString("codings=(")
+	for i, c := range a.Codings {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.Quote(c))
+	}
+	b.WriteByte(')')
+	writeInt := func(key string, v int64) {
+		if v >= 0 {
+			b.WriteString(", " + key + "=" + strconv.FormatInt(v, 10))
+		}
+	}
+	writeInt("encoded", a.EncodedBytes)
+	writeInt("decoded", a.DecodedBytes)
+	if a.Limits.MaxDecodedBytes > 0 {
+		writeInt("max-decoded", a.Limits.MaxDecodedBytes)
+	}
+	if a.Limits.MaxRatio > 0 {
+		// decimals have at most three fractional digits.
+		ratio := strings.TrimRight(strconv.FormatFloat(a.Limits.MaxRatio, 'f', 3, 64), "0")
+		if strings.HasSuffix(ratio, ".") {
+			ratio += "0"
+		}
+		b.WriteString(", max-ratio=" + ratio)
+	}
+	if a.Limits.MaxLayers > 0 {
+		writeInt("max-layers", int64(a.Limits.MaxLayers))
+	}
+	return b.String()
+}
+
+// WithAnnotation returns a Option to attach Annotation to decoded requests, which is returned by AnnotationFromRequest.
+// If header is not empty, the annotation is also set to the request header in the format of Annotation.String,
+// and it is updated with the sizes when the handler returns, so that access logs outside the middleware can read it.
+// The header sent by clients is removed from all requests so that it can't be spoofed.
+// It applies to Decode and Middleware.
+func WithAnnotation(header string) Option {
+	return func(cfg *config) {
+		cfg.annotate = true
+		cfg.annotationHeader = http.CanonicalHeaderKey(header)
+	}
+}
+
+type annotationKey struct{}
+
+// AnnotationFromRequest returns the Annotation of r, or nil if r is not annotated.
+func AnnotationFromRequest(r *http.Request) *Annotation {
+	a, _ := r.Context().Value(annotationKey{}).(*Annotation)
+	return a
+}
+
+// annotation returns r with the Annotation of the body decoded from contentEncoding,
+// and the function to update it with the sizes when the handler returns.
+func (cfg *config) annotation(r *http.Request, contentEncoding string, encoded, decoded *countingBody) (*http.Request, func()) {
+	if !cfg.annotate {
+		return r, func() {}
+	}
+	codings, _ := contentCodings(contentEncoding)
+	a := &Annotation{Codings: codings, EncodedBytes: r.ContentLength, DecodedBytes: -1, Limits: cfg.limits}
+	if a.EncodedBytes < 0 {
+		a.EncodedBytes = -1
+	}
+	if cfg.annotationHeader != "" {
+		r.Header.Set(cfg.annotationHeader, a.String())
+	}
+	return r.WithContext(context.WithValue(r.Context(), annotationKey{}, a)), func() {
+		a.EncodedBytes = encoded.n
+		a.DecodedBytes = decoded.n
+		if cfg.annotationHeader != "" {
+			r.Header.Set(cfg.annotationHeader, a.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithAnnotation(t *testing.T) {
+	payload := bytes.Repeat([]byte("annotation test "), 100)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip", "br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := contentencoding.Limits{MaxDecodedBytes: 1 << 20, MaxRatio: 2.5}
+
+	var inner *contentencoding.Annotation
+	var innerHeader, outerHeader string
+	h := contentencoding.Decode(contentencoding.WithAnnotation("X-Decoded"), contentencoding.WithLimits(limits))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = nil
+		if a := contentencoding.AnnotationFromRequest(r); a != nil {
+			copied := *a
+			inner = &copied
+		}
+		innerHeader = r.Header.Get("X-Decoded")
+		ioutil.ReadAll(r.Body)
+	}))
+	logged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		outerHeader = r.Header.Get("X-Decoded")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+	req.Header.Set("Content-Encoding", "gzip, br")
+	req.Header.Set("X-Decoded", "spoofed")
+	logged.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := &contentencoding.Annotation{Codings: []string{"gzip", "br"}, EncodedBytes: int64(len(encoded)), DecodedBytes: -1, Limits: limits}
+	if !reflect.DeepEqual(inner, want) {
+		t.Errorf("should be %+v but got %+v", want, inner)
+	}
+	if want := `codings=("gzip" "br"), encoded=` + strconv.Itoa(len(encoded)) + `, max-decoded=1048576, max-ratio=2.5`; innerHeader != want {
+		t.Errorf("header should be %q but got %q", want, innerHeader)
+	}
+	if want := `, decoded=` + strconv.Itoa(len(payload)) + `, `; !strings.Contains(outerHeader, want) {
+		t.Errorf("header should be updated with the decoded size but got %q", outerHeader)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+	req.Header.Set("X-Decoded", "spoofed")
+	logged.ServeHTTP(httptest.NewRecorder(), req)
+	if outerHeader != "" {
+		t.Errorf("header of the client should be removed but got %q", outerHeader)
+	}
+	if inner != nil {
+		t.Errorf("identity request should not be annotated but got %+v", inner)
+	}
+}
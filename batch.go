@@ -0,0 +1,148 @@
+package contentencoding
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Batch decodes the sub-requests of a batch request, such as a JSON array or a multipart/mixed body
+// of embedded HTTP requests, each of which has its own Content-Encoding.
+// MaxDecodedBytes of WithLimits is shared across the batch, so it bounds the total decoded size of all sub-requests,
+// and the other limits apply to each sub-request.
+// As DecodeBody, a coding without decoder is UnsupportedEncodingError even without WithStrict.
+// It is safe to decode sub-requests concurrently.
+type Batch struct {
+	cfg *config
+	max int64
+
+	mu        sync.Mutex
+	remaining int64
+}
+
+// NewBatch returns a Batch configured with opts.
+func NewBatch(opts ...Option) *Batch {
+	cfg := newConfig(opts)
+	b := &Batch{cfg: cfg, max: cfg.limits.MaxDecodedBytes, remaining: cfg.limits.MaxDecodedBytes}
+	cfg.limits.MaxDecodedBytes = 0
+	return b
+}
+
+// Decode returns body decoded by the Content-Encoding of header, e.g. of an element of a JSON batch.
+// Closing the returned reader closes body if it is an io.Closer.
+func (b *Batch) Decode(header http.Header, body io.Reader) (io.ReadCloser, error) {
+	contentEncoding, err := b.cfg.contentEncoding(header)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := b.cfg.decodeBody(contentEncoding, body)
+	if err != nil {
+		return nil, err
+	}
+	if b.max <= 0 {
+		return rc, nil
+	}
+	return &batchBody{ReadCloser: rc, b: b}, nil
+}
+
+// DecodeRequest replaces the body of the sub-request r with the decoded body,
+// and removes Content-Encoding and Content-Length from r as the middleware does.
+// Sub-requests with only identity codings are left as they are and do not count toward the limits.
+func (b *Batch) DecodeRequest(r *http.Request) error {
+	if _, ok := r.Header["Content-Encoding"]; ok {
+		contentEncoding, err := b.cfg.contentEncoding(r.Header)
+		if err != nil {
+			return err
+		}
+		r.Header["Content-Encoding"] = []string{contentEncoding}
+	}
+	if r.Body == nil || r.Body == http.NoBody || !isEncoded(r) {
+		return nil
+	}
+	body, err := b.Decode(r.Header, r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// ReadRequest reads a sub-request embedded in part as application/http, e.g. a part of multipart/mixed,
+// and decodes its body by DecodeRequest.
+func (b *Batch) ReadRequest(part io.Reader) (*http.Request, error) {
+	r, err := http.ReadRequest(bufio.NewReader(part))
+	if err != nil {
+		return nil, err
+	}
+	if err := b.DecodeRequest(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Remaining returns the decoded bytes the batch can still read, or -1 if MaxDecodedBytes is not limited.
+func (b *Batch) Remaining() int64 {
+	if b.max <= 0 {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+// take reserves up to n bytes of the batch, it returns false if nothing is left.
+func (b *Batch) take(n int) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return 0, false
+	}
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+	}
+	b.remaining -= int64(n)
+	return n, true
+}
+
+// refund returns n bytes reserved but not read.
+func (b *Batch) refund(n int) {
+	b.mu.Lock()
+	b.remaining += int64(n)
+	b.mu.Unlock()
+}
+
+// batchBody reads the decoded body of a sub-request within the shared limit of the batch.
+type batchBody struct {
+	io.ReadCloser
+	b   *Batch
+	err error
+}
+
+func (bb *batchBody) Read(p []byte) (int, error) {
+	if bb.err != nil {
+		return 0, bb.err
+	}
+	if len(p) == 0 {
+		return bb.ReadCloser.Read(p)
+	}
+	n, ok := bb.b.take(len(p))
+	if !ok {
+		// read a byte to tell whether the body ends exactly at the limit.
+		var one [1]byte
+		if m, err := bb.ReadCloser.Read(one[:]); m == 0 && err != nil {
+			bb.err = err
+			return 0, err
+		}
+		bb.err = &LimitError{Limit: "MaxDecodedBytes", Max: float64(bb.b.max)}
+		return 0, bb.err
+	}
+	m, err := bb.ReadCloser.Read(p[:n])
+	if m < n {
+		bb.b.refund(n - m)
+	}
+	return m, err
+}
@@ -0,0 +1,116 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestBatch_ReadRequest(t *testing.T) {
+	payload := bytes.Repeat([]byte("batch test "), 100)
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, coding := range []string{"gzip", "zstd", "br", ""} {
+		body := payload
+		if coding != "" {
+			var err error
+			if body, err = contentencodingtest.CompressBody(payload, coding); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/items", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coding != "" {
+			req.Header.Set("Content-Encoding", coding)
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := req.Write(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mw.Close()
+
+	tests := []struct {
+		name   string
+		limits contentencoding.Limits
+		want   int
+	}{
+		{"unlimited", contentencoding.Limits{}, 4},
+		{"shared limit", contentencoding.Limits{MaxDecodedBytes: int64(len(payload)) * 2}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := contentencoding.NewBatch(contentencoding.WithLimits(tt.limits))
+			mr := multipart.NewReader(bytes.NewReader(buf.Bytes()), mw.Boundary())
+			decoded := 0
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				req, err := b.ReadRequest(part)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ce := req.Header.Get("Content-Encoding"); ce != "" {
+					t.Errorf("Content-Encoding should be removed but got %s", ce)
+				}
+				got, err := io.ReadAll(req.Body)
+				var limitErr *contentencoding.LimitError
+				if errors.As(err, &limitErr) {
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Error("decoded body is wrong")
+				}
+				decoded++
+			}
+			if decoded != tt.want {
+				t.Errorf("decoded sub-requests should be %d but got %d", tt.want, decoded)
+			}
+		})
+	}
+}
+
+func TestBatch_Decode(t *testing.T) {
+	encoded, err := contentencodingtest.CompressBody([]byte("test"), "br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := contentencoding.NewBatch()
+	rc, err := b.Decode(http.Header{"Content-Encoding": {"br"}}, bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "test" {
+		t.Errorf("should be test but got %s", got)
+	}
+
+	var unsupported *contentencoding.UnsupportedEncodingError
+	if _, err := b.Decode(http.Header{"Content-Encoding": {"deflate"}}, bytes.NewReader(encoded)); !errors.As(err, &unsupported) {
+		t.Errorf("should be UnsupportedEncodingError but got %v", err)
+	}
+}
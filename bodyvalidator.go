@@ -0,0 +1,137 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ValidationError is the error of the validator of WithBodyValidator, which rejected the decoded body.
+// DefaultErrorHandler responds 400 Bad Request for it.
+type ValidationError struct {
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return "contentencoding: invalid decoded body: " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// WithBodyValidator returns a Option to check decoded bodies as they are read, e.g. for the syntax of JSON,
+// so that malformed data produced by decoding doesn't reach the handler.
+// newValidator returns the validator for r, or nil to skip the request. Each chunk of the decoded body
+// is written to the validator before the handler receives it, and the validator is closed at the end of the body.
+// If Write or Close returns an error, the read fails with ValidationError without the rejected chunk,
+// and the error handler responds unless the handler has already written the response,
+// in which case later writes of the handler are discarded.
+// Requests with only identity codings are not validated.
+func WithBodyValidator(newValidator func(r *http.Request) io.WriteCloser) Option {
+	return func(cfg *config) {
+		cfg.newValidator = newValidator
+	}
+}
+
+// validate returns r and w to validate the decoded body of r with the validator of WithBodyValidator.
+func (cfg *config) validate(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	if cfg.newValidator == nil {
+		return w, r
+	}
+	v := cfg.newValidator(r)
+	if v == nil {
+		return w, r
+	}
+	vw := &validatedWriter{ResponseWriter: w}
+	r.Body = &validatedBody{ReadCloser: r.Body, v: v, fail: func(err error) {
+		vw.fail(func() { cfg.handleReadError(w, r, err) })
+	}}
+	return vw, r
+}
+
+// validatedBody writes the decoded body to the validator before returning it.
+type validatedBody struct {
+	io.ReadCloser
+	v    io.WriteCloser
+	fail func(err error)
+	err  error
+}
+
+func (b *validatedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if _, verr := b.v.Write(p[:n]); verr != nil {
+			return 0, b.reject(verr)
+		}
+	}
+	if err == io.EOF {
+		if verr := b.v.Close(); verr != nil {
+			return 0, b.reject(verr)
+		}
+	}
+	return n, err
+}
+
+func (b *validatedBody) reject(err error) error {
+	b.err = &ValidationError{Err: err}
+	b.fail(b.err)
+	return b.err
+}
+
+// validatedWriter discards the response of the handler once the error handler has responded.
+// The error handler responds on the goroutine reading the body, which may differ from the one writing the response,
+// so they are serialized by mu.
+type validatedWriter struct {
+	http.ResponseWriter
+	mu     sync.Mutex
+	wrote  bool
+	failed bool
+}
+
+// fail calls respond unless the handler or the error handler has already written the response.
+func (vw *validatedWriter) fail(respond func()) {
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	if !vw.wrote && !vw.failed {
+		vw.failed = true
+		respond()
+	}
+}
+
+func (vw *validatedWriter) WriteHeader(status int) {
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	if vw.failed {
+		return
+	}
+	vw.wrote = true
+	vw.ResponseWriter.WriteHeader(status)
+}
+
+func (vw *validatedWriter) Write(p []byte) (int, error) {
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	if vw.failed {
+		return len(p), nil
+	}
+	vw.wrote = true
+	return vw.ResponseWriter.Write(p)
+}
+
+func (vw *validatedWriter) Flush() {
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	if f, ok := vw.ResponseWriter.(http.Flusher); ok && !vw.failed {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (vw *validatedWriter) Unwrap() http.ResponseWriter {
+	return vw.ResponseWriter
+}
@@ -0,0 +1,98 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+// jsonValidator rejects NUL bytes as they are written and the syntax of JSON when it is closed.
+type jsonValidator struct {
+	buf bytes.Buffer
+}
+
+func (v *jsonValidator) Write(p []byte) (int, error) {
+	if bytes.IndexByte(p, 0) >= 0 {
+		return 0, errors.New("NUL in JSON")
+	}
+	return v.buf.Write(p)
+}
+
+func (v *jsonValidator) Close() error {
+	if !json.Valid(v.buf.Bytes()) {
+		return errors.New("invalid JSON")
+	}
+	return nil
+}
+
+func TestWithBodyValidator(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		encoding string
+		want     int
+	}{
+		{"valid", `{"a": [1, 2]}`, "gzip", http.StatusOK},
+		{"invalid", `{"a": [1, 2}`, "gzip", http.StatusBadRequest},
+		{"NUL", "{\"a\": \x00}", "zstd", http.StatusBadRequest},
+		{"identity", `{"a": [1, 2}`, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithBodyValidator(func(r *http.Request) io.WriteCloser {
+				return &jsonValidator{}
+			}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				var validationErr *contentencoding.ValidationError
+				if tt.want != http.StatusOK && !errors.As(err, &validationErr) {
+					t.Errorf("should be ValidationError but got %v", err)
+				}
+				if tt.want == http.StatusOK && string(b) != tt.body {
+					t.Errorf("should be %s but got %s", tt.body, b)
+				}
+				w.Write([]byte("ok"))
+			}))
+			req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte(tt.body), tt.encoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("should be %d but got %d", tt.want, rec.Code)
+			}
+			if tt.want != http.StatusOK && bytes.Contains(rec.Body.Bytes(), []byte("ok")) {
+				t.Errorf("response of the handler should be discarded but got %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestWithBodyValidator_concurrent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	h := contentencoding.Decode(contentencoding.WithBodyValidator(func(r *http.Request) io.WriteCloser {
+		return &jsonValidator{}
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != rec {
+			t.Error("writer should unwrap to the recorder")
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			io.ReadAll(r.Body)
+		}()
+		w.Write([]byte("ok"))
+		<-done
+	}))
+	req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte(`{"a": [1, 2}`), "gzip")
+	h.ServeHTTP(rec, req)
+	// either the handler or the error handler responds, whichever comes first.
+	if got := rec.Body.String(); rec.Code == http.StatusOK && got != "ok" {
+		t.Errorf("response should be ok but got %s", got)
+	}
+}
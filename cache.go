@@ -0,0 +1,96 @@
+package contentencoding
+
+import (
+	"container/list"
+	"sync"
+)
+
+// VariantKey identifies a transcoded variant of an upstream response.
+type VariantKey struct {
+	// URL is the URL of the upstream request.
+	URL string
+	// Encoding is the coding of the variant.
+	Encoding string
+	// Validator is the strong ETag of the upstream response.
+	Validator string
+}
+
+// VariantCache is a storage of transcoded response bodies used by TranscodeResponse.
+// It must be safe for concurrent use.
+type VariantCache interface {
+	// Get returns the body stored for key.
+	Get(key VariantKey) ([]byte, bool)
+	// Set stores body for key.
+	Set(key VariantKey, body []byte)
+}
+
+// WithVariantCache returns a Option to make TranscodeResponse store transcoded bodies in c
+// and serve them instead of transcoding the same upstream response again.
+// Only 200 OK responses with a strong ETag and without Cache-Control: no-store are cached,
+// since weak ETags and Last-Modified don't guarantee the same body. Responses that vary on other fields
+// than Accept-Encoding, e.g. Accept-Language or Cookie, are not cached either, since the key doesn't include them.
+// Bodies larger than maxSize bytes are not stored.
+func WithVariantCache(c VariantCache, maxSize int) Option {
+	return func(cfg *config) {
+		cfg.variantCache = c
+		cfg.maxVariantSize = maxSize
+	}
+}
+
+// MemoryVariantCache is a VariantCache that keeps bodies in memory
+// and evicts the least recently used ones when the total size exceeds the limit.
+type MemoryVariantCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	ll       *list.List
+	entries  map[VariantKey]*list.Element
+}
+
+type variantEntry struct {
+	key  VariantKey
+	body []byte
+}
+
+// NewMemoryVariantCache returns a MemoryVariantCache that holds up to maxBytes bytes of bodies.
+func NewMemoryVariantCache(maxBytes int) *MemoryVariantCache {
+	return &MemoryVariantCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		entries:  make(map[VariantKey]*list.Element),
+	}
+}
+
+// Get implements VariantCache.
+func (c *MemoryVariantCache) Get(key VariantKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*variantEntry).body, true
+}
+
+// Set implements VariantCache.
+func (c *MemoryVariantCache) Set(key VariantKey, body []byte) {
+	if len(body) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.size -= len(e.Value.(*variantEntry).body)
+		c.ll.Remove(e)
+	}
+	c.entries[key] = c.ll.PushFront(&variantEntry{key: key, body: body})
+	c.size += len(body)
+	for c.size > c.maxBytes {
+		e := c.ll.Back()
+		ent := e.Value.(*variantEntry)
+		c.ll.Remove(e)
+		delete(c.entries, ent.key)
+		c.size -= len(ent.body)
+	}
+}
@@ -0,0 +1,119 @@
+package contentencoding_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestMemoryVariantCache(t *testing.T) {
+	c := contentencoding.NewMemoryVariantCache(8)
+	k1 := contentencoding.VariantKey{URL: "/1", Encoding: "br", Validator: `"a"`}
+	k2 := contentencoding.VariantKey{URL: "/2", Encoding: "br", Validator: `"a"`}
+	k3 := contentencoding.VariantKey{URL: "/3", Encoding: "br", Validator: `"a"`}
+
+	c.Set(k1, []byte("1111"))
+	c.Set(k2, []byte("2222"))
+	if _, ok := c.Get(k1); !ok { // k1 becomes the most recently used
+		t.Error("k1 should be cached")
+	}
+	c.Set(k3, []byte("3333"))
+	if _, ok := c.Get(k2); ok {
+		t.Error("k2 should be evicted")
+	}
+	for _, k := range []contentencoding.VariantKey{k1, k3} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%v should be cached", k)
+		}
+	}
+	c.Set(k1, []byte("too large body"))
+	if b, _ := c.Get(k1); string(b) != "1111" {
+		t.Errorf("too large body should not be stored, got='%s'", b)
+	}
+}
+
+func TestTranscodeResponse_WithVariantCache(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if etag := r.URL.Query().Get("etag"); etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		if vary := r.URL.Query().Get("vary"); vary != "" {
+			w.Header().Set("Vary", vary)
+		}
+		if lastModified := r.URL.Query().Get("last-modified"); lastModified != "" {
+			w.Header().Set("Last-Modified", lastModified)
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.ModifyResponse = contentencoding.TranscodeResponse(
+		contentencoding.WithVariantCache(contentencoding.NewMemoryVariantCache(1<<20), 1<<10),
+	)
+	srv := httptest.NewServer(proxy)
+	t.Cleanup(srv.Close)
+
+	get := func(query string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", "br")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		cached bool
+	}{
+		{"first", `etag="a"`, false},
+		{"hit", `etag="a"`, true},
+		{"other validator", `etag="b"`, false},
+		{"no validator", "", false},
+		{"no validator again", "", false},
+		{"weak etag", `etag=W/"c"`, false},
+		{"weak etag again", `etag=W/"c"`, false},
+		{"last-modified", "last-modified=Mon,+02+Jan+2006+15:04:05+GMT", false},
+		{"last-modified again", "last-modified=Mon,+02+Jan+2006+15:04:05+GMT", false},
+		{"vary", `etag="d"&vary=Accept-Language`, false},
+		{"vary again", `etag="d"&vary=Accept-Language`, false},
+		{"vary accept-encoding", `etag="e"&vary=Accept-Encoding`, false},
+		{"vary accept-encoding again", `etag="e"&vary=Accept-Encoding`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := get(tt.query)
+			if ce := resp.Header.Get("Content-Encoding"); ce != "br" {
+				t.Errorf("Content-Encoding should be br but got='%s'", ce)
+			}
+			if cached := resp.ContentLength >= 0; cached != tt.cached {
+				t.Errorf("cached should be %v, ContentLength=%d", tt.cached, resp.ContentLength)
+			}
+		})
+	}
+}
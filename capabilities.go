@@ -0,0 +1,104 @@
+package contentencoding
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// Capabilities describes what the middleware accepts, it is served as JSON by Middleware.CapabilityHandler.
+type Capabilities struct {
+	// Codings are the supported request codings.
+	Codings []string `json:"codings"`
+	// Methods are the methods whose bodies are decoded, see WithMethods.
+	// It is empty both by default, when all methods except GET and HEAD are decoded, and when NoMethods is true.
+	Methods []string `json:"methods,omitempty"`
+	// NoMethods reports that no bodies are decoded, as WithMethods is called without methods.
+	NoMethods bool `json:"noMethods,omitempty"`
+	// Limits are the limits of decoding, zero values mean no limit.
+	Limits CapabilityLimits `json:"limits"`
+	// Passthrough reports whether encoded bodies are validated and passed on encoded, see WithPassthrough.
+	Passthrough bool `json:"passthrough,omitempty"`
+	// Dictionaries describes the store of WithDictionaryStore, it is nil without one.
+	// The middleware serves no dictionaries, so there is no endpoint to fetch them from,
+	// clients are expected to have the dictionaries they compress with, e.g. from TrainDictionary.
+	Dictionaries *CapabilityDictionaries `json:"dictionaries,omitempty"`
+}
+
+// CapabilityDictionaries lists the dictionaries of a DictionaryStore,
+// the lists are empty if it does not implement DictionaryLister.
+type CapabilityDictionaries struct {
+	// IDs are the IDs of the zstd dictionaries, by which zstd bodies are decoded.
+	IDs []uint32 `json:"ids,omitempty"`
+	// Hashes are the hex encoded SHA-256 of all dictionaries.
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// CapabilityLimits is the JSON form of Limits.
+type CapabilityLimits struct {
+	MaxDecodedBytes int64   `json:"maxDecodedBytes,omitempty"`
+	MaxRatio        float64 `json:"maxRatio,omitempty"`
+	MaxLayers       int     `json:"maxLayers,omitempty"`
+	// MaxDecoderMemory is the maximum window of brotli and zstd streams.
+	MaxDecoderMemory int64 `json:"maxDecoderMemory,omitempty"`
+	// MaxCompressedBytes is the maximum Content-Length of encoded bodies, see WithMaxCompressedBytes.
+	MaxCompressedBytes int64 `json:"maxCompressedBytes,omitempty"`
+}
+
+// Capabilities returns the capabilities of m generated from its configuration.
+func (m *Middleware) Capabilities() Capabilities {
+	return m.config().capabilities()
+}
+
+func (cfg *config) capabilities() Capabilities {
+	c := Capabilities{
+		Codings: cfg.supportedCodings(),
+		Limits: CapabilityLimits{
+			MaxDecodedBytes:  cfg.limits.MaxDecodedBytes,
+			MaxRatio:         cfg.limits.MaxRatio,
+			MaxLayers:        cfg.limits.MaxLayers,
+			MaxDecoderMemory: cfg.limits.MaxDecoderMemory,
+		},
+	}
+	c.Passthrough = cfg.passthrough
+	if cfg.oversize == OversizeReject {
+		c.Limits.MaxCompressedBytes = cfg.maxCompressed
+	}
+	for method := range cfg.methods {
+		c.Methods = append(c.Methods, method)
+	}
+	sort.Strings(c.Methods)
+	c.NoMethods = cfg.methods != nil && len(cfg.methods) == 0
+	if cfg.dictionaries != nil {
+		c.Dictionaries = new(CapabilityDictionaries)
+		if l, ok := cfg.dictionaries.(DictionaryLister); ok {
+			c.Dictionaries.IDs = l.DictionaryIDs()
+			sort.Slice(c.Dictionaries.IDs, func(i, j int) bool { return c.Dictionaries.IDs[i] < c.Dictionaries.IDs[j] })
+			for _, h := range l.DictionaryHashes() {
+				c.Dictionaries.Hashes = append(c.Dictionaries.Hashes, hex.EncodeToString(h[:]))
+			}
+			sort.Strings(c.Dictionaries.Hashes)
+		}
+	}
+	return c
+}
+
+// CapabilityHandler returns a handler that serves Capabilities as JSON, for example at /.well-known/content-encoding,
+// so that clients can discover which request codings and sizes are accepted.
+func (m *Middleware) CapabilityHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := json.Marshal(m.Capabilities())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,95 @@
+package contentencoding_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestMiddleware_CapabilityHandler(t *testing.T) {
+	m := contentencoding.New(
+		contentencoding.WithMethods(http.MethodPut, http.MethodPost),
+		contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1 << 20, MaxLayers: 2}),
+	)
+	h := m.CapabilityHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/content-encoding", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("should be 200 but got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("should be application/json but got %s", got)
+	}
+	want := `{"codings":["br","gzip","zstd"],"methods":["POST","PUT"],"limits":{"maxDecodedBytes":1048576,"maxLayers":2}}`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("should be %s but got %s", want, got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/.well-known/content-encoding", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("should be 405 but got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_Capabilities(t *testing.T) {
+	raw := []byte("raw dictionary")
+	sum := sha256.Sum256(raw)
+	tests := []struct {
+		name string
+		opts []contentencoding.Option
+		want string
+	}{
+		{"default methods", nil, `{"codings":["br","gzip","zstd"],"limits":{}}`},
+		{"no methods", []contentencoding.Option{contentencoding.WithMethods()}, `{"codings":["br","gzip","zstd"],"noMethods":true,"limits":{}}`},
+		{
+			"dictionaries",
+			[]contentencoding.Option{contentencoding.WithDictionaryStore(contentencoding.NewMemoryDictionaryStore(raw))},
+			`{"codings":["br","gzip","zstd"],"limits":{},"dictionaries":{"hashes":["` + hex.EncodeToString(sum[:]) + `"]}}`,
+		},
+		{
+			"dictionaries not listed",
+			[]contentencoding.Option{contentencoding.WithDictionaryStore(unlistedStore{})},
+			`{"codings":["br","gzip","zstd"],"limits":{},"dictionaries":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(contentencoding.New(tt.opts...).Capabilities())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("should be %s but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Capabilities_zstdDictionary(t *testing.T) {
+	dict, err := os.ReadFile("testdata/dict.zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := contentencoding.New(contentencoding.WithDictionaryStore(contentencoding.NewMemoryDictionaryStore(dict))).Capabilities()
+	if c.Dictionaries == nil || len(c.Dictionaries.IDs) != 1 || c.Dictionaries.IDs[0] != 42 {
+		t.Errorf("dictionary 42 should be listed but got %+v", c.Dictionaries)
+	}
+}
+
+type unlistedStore struct{}
+
+func (unlistedStore) Dictionary(uint32) ([]byte, error) {
+	return nil, contentencoding.ErrDictionaryNotFound
+}
+
+func (unlistedStore) DictionaryByHash([sha256.Size]byte) ([]byte, error) {
+	return nil, contentencoding.ErrDictionaryNotFound
+}
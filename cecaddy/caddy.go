@@ -0,0 +1,218 @@
+// Package cecaddy provides Caddy HTTP handler modules that decode compressed request bodies
+// and compress responses with go-content-encoding.
+//
+// It registers the http.handlers.content_decode module and the content_decode Caddyfile directive:
+//
+//	content_decode [<matcher>] {
+//		zstd_max_memory <size>
+//		zstd_max_window <size>
+//		decoder <module> [<args...>]
+//	}
+//
+// Custom decoders are Caddy modules in the http.content_decoders namespace that implement DecoderProvider,
+// so plugins can add codings that are usable from the Caddyfile without writing Go.
+//
+// It also registers the http.handlers.content_encode module and the content_encode Caddyfile directive,
+// which compresses responses as contentencoding.Encode does:
+//
+//	content_encode [<matcher>] {
+//		prefer <codings...>
+//		minimum_length <size>
+//		gzip_level <level>
+//		brotli_quality <quality>
+//	}
+//
+// The directives have no default order, so they are used in a route block or ordered by the order global option.
+package cecaddy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/dustin/go-humanize"
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	caddy.RegisterModule(Decode{})
+	caddy.RegisterModule(Encode{})
+	httpcaddyfile.RegisterHandlerDirective("content_decode", parseCaddyfile)
+	httpcaddyfile.RegisterHandlerDirective("content_encode", parseEncodeCaddyfile)
+}
+
+// DecoderProvider is implemented by modules in the http.content_decoders namespace.
+type DecoderProvider interface {
+	ContentDecoder() *contentencoding.Decoder
+}
+
+// Decode is a middleware which decodes request bodies detected by Content-Encoding.
+type Decode struct {
+	// The maximum memory the zstd decoder may use, in bytes.
+	ZstdMaxMemory uint64 `json:"zstd_max_memory,omitempty"`
+	// The maximum window size the zstd decoder accepts, in bytes.
+	ZstdMaxWindow uint64 `json:"zstd_max_window,omitempty"`
+	// Custom decoders keyed by module name.
+	DecodersRaw caddy.ModuleMap `json:"decoders,omitempty" caddy:"namespace=http.content_decoders"`
+
+	decode func(http.Handler) http.Handler
+}
+
+// CaddyModule returns the Caddy module information.
+func (Decode) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.content_decode",
+		New: func() caddy.Module { return new(Decode) },
+	}
+}
+
+// Provision sets up the middleware.
+func (d *Decode) Provision(ctx caddy.Context) error {
+	var dopts []zstd.DOption
+	if d.ZstdMaxMemory > 0 {
+		dopts = append(dopts, zstd.WithDecoderMaxMemory(d.ZstdMaxMemory))
+	}
+	if d.ZstdMaxWindow > 0 {
+		dopts = append(dopts, zstd.WithDecoderMaxWindow(d.ZstdMaxWindow))
+	}
+
+	var decoders []*contentencoding.Decoder
+	if d.DecodersRaw != nil {
+		mods, err := ctx.LoadModule(d, "DecodersRaw")
+		if err != nil {
+			return fmt.Errorf("loading decoder modules: %w", err)
+		}
+		for name, mod := range mods.(map[string]interface{}) {
+			p, ok := mod.(DecoderProvider)
+			if !ok {
+				return fmt.Errorf("module %s is not a DecoderProvider", name)
+			}
+			decoders = append(decoders, p.ContentDecoder())
+		}
+	}
+
+	d.decode = contentencoding.Decode(
+		contentencoding.WithDOptions(dopts...),
+		contentencoding.WithDecoder(decoders...),
+		contentencoding.WithErrorHandler(captureError),
+	)
+	return nil
+}
+
+// ServeHTTP implements caddyhttp.MiddlewareHandler.
+// Decoding errors are returned as caddyhttp.HandlerError so that Caddy's error routes handle them.
+func (d Decode) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	s := new(state)
+	d.decode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.nextErr = next.ServeHTTP(w, r)
+	})).ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), stateKey{}, s)))
+	if s.err != nil {
+		return caddyhttp.Error(statusCode(r, s.err), s.err)
+	}
+	return s.nextErr
+}
+
+// UnmarshalCaddyfile sets up the handler from Caddyfile tokens.
+func (d *Decode) UnmarshalCaddyfile(h *caddyfile.Dispenser) error {
+	for h.Next() {
+		if h.NextArg() {
+			return h.ArgErr()
+		}
+		for h.NextBlock(0) {
+			switch name := h.Val(); name {
+			case "zstd_max_memory", "zstd_max_window":
+				if !h.NextArg() {
+					return h.ArgErr()
+				}
+				size, err := humanize.ParseBytes(h.Val())
+				if err != nil {
+					return h.Errf("parsing %s: %v", name, err)
+				}
+				if name == "zstd_max_memory" {
+					d.ZstdMaxMemory = size
+				} else {
+					d.ZstdMaxWindow = size
+				}
+			case "decoder":
+				if !h.NextArg() {
+					return h.ArgErr()
+				}
+				modName := h.Val()
+				mod, err := caddyfile.UnmarshalModule(h, "http.content_decoders."+modName)
+				if err != nil {
+					return err
+				}
+				if d.DecodersRaw == nil {
+					d.DecodersRaw = make(caddy.ModuleMap)
+				}
+				d.DecodersRaw[modName] = caddyconfig.JSON(mod, nil)
+			default:
+				return h.Errf("unrecognized subdirective: %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+func parseCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	d := new(Decode)
+	err := d.UnmarshalCaddyfile(h.Dispenser)
+	return d, err
+}
+
+type stateKey struct{}
+
+type state struct {
+	err     error
+	nextErr error
+}
+
+func captureError(w http.ResponseWriter, r *http.Request, err error) {
+	if s, ok := r.Context().Value(stateKey{}).(*state); ok {
+		s.err = err
+	}
+}
+
+func statusCode(r *http.Request, err error) int {
+	rec := &statusRecorder{header: make(http.Header)}
+	contentencoding.DefaultErrorHandler(rec, r, err)
+	if rec.code == 0 {
+		return http.StatusBadRequest
+	}
+	return rec.code
+}
+
+type statusRecorder struct {
+	header http.Header
+	code   int
+}
+
+func (r *statusRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return len(b), nil
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Decode)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Decode)(nil)
+	_ caddyfile.Unmarshaler       = (*Decode)(nil)
+)
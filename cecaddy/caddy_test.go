@@ -0,0 +1,156 @@
+package cecaddy_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cecaddy"
+)
+
+func init() {
+	caddy.RegisterModule(suffixDecoder{})
+}
+
+// suffixDecoder is a DecoderProvider for "suffix" coding used in tests.
+type suffixDecoder struct{}
+
+func (suffixDecoder) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.content_decoders.suffix",
+		New: func() caddy.Module { return new(suffixDecoder) },
+	}
+}
+
+func (suffixDecoder) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+	}
+	return nil
+}
+
+func (suffixDecoder) ContentDecoder() *contentencoding.Decoder {
+	return &contentencoding.Decoder{
+		Encoding: "suffix",
+		Handler: func(w http.ResponseWriter, r *http.Request) error {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				return err
+			}
+			r.Body = io.NopCloser(strings.NewReader(string(b) + "-suffix"))
+			return nil
+		},
+	}
+}
+
+func provision(t *testing.T, input string) *cecaddy.Decode {
+	t.Helper()
+	d := new(cecaddy.Decode)
+	if err := d.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	if err := d.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func echoBody(w http.ResponseWriter, r *http.Request) error {
+	_, err := io.Copy(w, r.Body)
+	return err
+}
+
+func TestDecode_ServeHTTP(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte("test"))
+	gw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     io.Reader
+		want     string
+	}{
+		{"gzip", "gzip", &buf, "test"},
+		{"custom", "suffix", strings.NewReader("test"), "test-suffix"},
+	}
+
+	d := provision(t, `content_decode {
+		zstd_max_memory 64MiB
+		zstd_max_window 8MiB
+		decoder suffix
+	}`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", tt.body)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			if err := d.ServeHTTP(rec, req, caddyhttp.HandlerFunc(echoBody)); err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("should be %s but got='%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecode_ServeHTTP_error(t *testing.T) {
+	d := provision(t, `content_decode`)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test")) // not compressed
+	req.Header.Set("Content-Encoding", "gzip")
+	err := d.ServeHTTP(rec, req, caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		t.Error("next should not be called")
+		return nil
+	}))
+
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) {
+		t.Fatalf("should be caddyhttp.HandlerError but got %v", err)
+	}
+	if herr.StatusCode != http.StatusBadRequest {
+		t.Errorf("should be 400 but got %d", herr.StatusCode)
+	}
+}
+
+func TestDecode_UnmarshalCaddyfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"empty", `content_decode`, true},
+		{"sizes", "content_decode {\nzstd_max_memory 1MB\nzstd_max_window 1024\n}", true},
+		{"arg", `content_decode foo`, false},
+		{"bad size", "content_decode {\nzstd_max_memory abc\n}", false},
+		{"unknown", "content_decode {\nfoo\n}", false},
+		{"missing decoder", "content_decode {\ndecoder\n}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := new(cecaddy.Decode)
+			err := d.UnmarshalCaddyfile(caddyfile.NewTestDispenser(tt.input))
+			if tt.ok && err != nil {
+				t.Errorf("should not be error but got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("should be error")
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package cecaddy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/andybalholm/brotli"
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/dustin/go-humanize"
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/klauspost/compress/gzip"
+)
+
+// Encode is a middleware which compresses responses with the coding negotiated by Accept-Encoding.
+type Encode struct {
+	// The codings in the order of preference among those the client weights equally, from br, zstd and gzip.
+	// Codings not listed are not used, and br, zstd and gzip are preferred in this order by default.
+	Prefer []string `json:"prefer,omitempty"`
+	// The minimum length of responses to compress, in bytes.
+	MinLength int `json:"minimum_length,omitempty"`
+	// The gzip level from -2 to 9, the default level if not set.
+	GzipLevel *int `json:"gzip_level,omitempty"`
+	// The brotli quality from 0 to 11, the default quality if not set.
+	BrotliQuality *int `json:"brotli_quality,omitempty"`
+
+	encode func(http.Handler) http.Handler
+}
+
+// CaddyModule returns the Caddy module information.
+func (Encode) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.content_encode",
+		New: func() caddy.Module { return new(Encode) },
+	}
+}
+
+// Provision sets up the middleware.
+func (e *Encode) Provision(ctx caddy.Context) error {
+	var opts []contentencoding.Option
+	for _, c := range e.Prefer {
+		if c := contentencoding.CanonicalCoding(c); c != "br" && c != "gzip" && c != "zstd" {
+			return fmt.Errorf("unsupported coding: %s", c)
+		}
+	}
+	if len(e.Prefer) > 0 {
+		opts = append(opts, contentencoding.WithEncodingPreference(e.Prefer...))
+	}
+	if e.MinLength < 0 {
+		return fmt.Errorf("invalid minimum length: %d", e.MinLength)
+	}
+	if e.MinLength > 0 {
+		opts = append(opts, contentencoding.WithMinSize(e.MinLength))
+	}
+	// the options panic for invalid values, which are reported as errors here.
+	if l := e.GzipLevel; l != nil {
+		if *l < gzip.HuffmanOnly || *l > gzip.BestCompression {
+			return fmt.Errorf("invalid gzip level: %d", *l)
+		}
+		opts = append(opts, contentencoding.WithGzipLevel(*l))
+	}
+	if q := e.BrotliQuality; q != nil {
+		if *q < brotli.BestSpeed || *q > brotli.BestCompression {
+			return fmt.Errorf("invalid brotli quality: %d", *q)
+		}
+		opts = append(opts, contentencoding.WithBrotliQuality(*q))
+	}
+	e.encode = contentencoding.Encode(opts...)
+	return nil
+}
+
+// ServeHTTP implements caddyhttp.MiddlewareHandler.
+func (e Encode) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	var nextErr error
+	e.encode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextErr = next.ServeHTTP(w, r)
+	})).ServeHTTP(w, r)
+	return nextErr
+}
+
+// UnmarshalCaddyfile sets up the handler from Caddyfile tokens.
+func (e *Encode) UnmarshalCaddyfile(h *caddyfile.Dispenser) error {
+	for h.Next() {
+		if h.NextArg() {
+			return h.ArgErr()
+		}
+		for h.NextBlock(0) {
+			switch name := h.Val(); name {
+			case "prefer":
+				e.Prefer = h.RemainingArgs()
+				if len(e.Prefer) == 0 {
+					return h.ArgErr()
+				}
+			case "minimum_length":
+				if !h.NextArg() {
+					return h.ArgErr()
+				}
+				size, err := humanize.ParseBytes(h.Val())
+				if err != nil {
+					return h.Errf("parsing %s: %v", name, err)
+				}
+				e.MinLength = int(size)
+			case "gzip_level", "brotli_quality":
+				if !h.NextArg() {
+					return h.ArgErr()
+				}
+				n, err := strconv.Atoi(h.Val())
+				if err != nil {
+					return h.Errf("parsing %s: %v", name, err)
+				}
+				if name == "gzip_level" {
+					e.GzipLevel = &n
+				} else {
+					e.BrotliQuality = &n
+				}
+			default:
+				return h.Errf("unrecognized subdirective: %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+func parseEncodeCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	e := new(Encode)
+	err := e.UnmarshalCaddyfile(h.Dispenser)
+	return e, err
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Encode)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Encode)(nil)
+	_ caddyfile.Unmarshaler       = (*Encode)(nil)
+)
@@ -0,0 +1,150 @@
+package cecaddy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cecaddy"
+)
+
+func provisionEncode(t *testing.T, input string) (*cecaddy.Encode, error) {
+	t.Helper()
+	e := new(cecaddy.Encode)
+	if err := e.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	return e, e.Provision(ctx)
+}
+
+func TestEncode_ServeHTTP(t *testing.T) {
+	content := strings.Repeat("content encode test ", 100)
+	e, err := provisionEncode(t, `content_encode {
+		prefer zstd gzip
+		minimum_length 1KiB
+		gzip_level 9
+		brotli_quality 5
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		accept string
+		body   string
+		want   string
+	}{
+		{"preferred", "gzip, br, zstd", content, "zstd"},
+		{"accepted", "br, gzip", content, "gzip"},
+		{"not preferred", "br", content, ""},
+		{"small", "gzip", "small", ""},
+		{"identity", "", content, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			err := e.ServeHTTP(rec, req, caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("Content-Type", "text/plain")
+				_, err := w.Write([]byte(tt.body))
+				return err
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding should be %q but got %q", tt.want, got)
+			}
+			b := rec.Body.Bytes()
+			if tt.want != "" {
+				if b, err = contentencoding.DecodeBytes(tt.want, b, contentencoding.Limits{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(b) != tt.body {
+				t.Error("decoded response should be the body")
+			}
+		})
+	}
+}
+
+func TestEncode_ServeHTTP_error(t *testing.T) {
+	e, err := provisionEncode(t, `content_encode`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	herr := caddyhttp.Error(http.StatusNotFound, nil)
+	if err := e.ServeHTTP(rec, req, caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return herr
+	})); err != herr {
+		t.Errorf("error of next should be returned but got %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("nothing should be written but got %q", rec.Body)
+	}
+}
+
+func TestEncode_Provision(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"empty", `content_encode`, true},
+		{"unsupported coding", "content_encode {\nprefer deflate\n}", false},
+		{"gzip level", "content_encode {\ngzip_level 10\n}", false},
+		{"brotli quality", "content_encode {\nbrotli_quality -1\n}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provisionEncode(t, tt.input)
+			if tt.ok && err != nil {
+				t.Errorf("should not be error but got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("should be error")
+			}
+		})
+	}
+}
+
+func TestEncode_UnmarshalCaddyfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"empty", `content_encode`, true},
+		{"all", "content_encode {\nprefer br gzip\nminimum_length 512\ngzip_level 1\nbrotli_quality 4\n}", true},
+		{"arg", `content_encode foo`, false},
+		{"no codings", "content_encode {\nprefer\n}", false},
+		{"bad size", "content_encode {\nminimum_length abc\n}", false},
+		{"bad level", "content_encode {\ngzip_level high\n}", false},
+		{"unknown", "content_encode {\nfoo\n}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := new(cecaddy.Encode)
+			err := e.UnmarshalCaddyfile(caddyfile.NewTestDispenser(tt.input))
+			if tt.ok && err != nil {
+				t.Errorf("should not be error but got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("should be error")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package ceecho provides an Echo middleware for go-content-encoding.
+// Decoding errors are returned to Echo as *echo.HTTPError so that they flow through Echo's HTTPErrorHandler.
+package ceecho
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/labstack/echo/v4"
+)
+
+// Skipper defines a function to skip the middleware.
+type Skipper func(c echo.Context) bool
+
+// DefaultSkipper never skips.
+func DefaultSkipper(c echo.Context) bool {
+	return false
+}
+
+// Config is the configuration for DecodeWithConfig.
+type Config struct {
+	// Skipper defines a function to skip the middleware.
+	Skipper Skipper
+	// Options are passed to contentencoding.Decode.
+	// WithErrorHandler is ignored, use ErrorHandler instead.
+	Options []contentencoding.Option
+	// ErrorHandler decides the status code and message of the returned *echo.HTTPError.
+	// It is called with a recorder instead of the real response,
+	// the recorded status code becomes HTTPError.Code and the trimmed body becomes HTTPError.Message.
+	// Headers set by ErrorHandler are copied to the response.
+	// Default is contentencoding.DefaultErrorHandler.
+	ErrorHandler contentencoding.ErrorHandler
+}
+
+// Decode returns an Echo middleware that automatically decodes body detected by Content-Encoding.
+func Decode(opts ...contentencoding.Option) echo.MiddlewareFunc {
+	return DecodeWithConfig(Config{Options: opts})
+}
+
+// DecodeWithConfig returns an Echo middleware with Config.
+func DecodeWithConfig(config Config) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = contentencoding.DefaultErrorHandler
+	}
+	opts := make([]contentencoding.Option, 0, len(config.Options)+1)
+	opts = append(opts, config.Options...)
+	opts = append(opts, contentencoding.WithErrorHandler(captureError))
+	decode := contentencoding.Decode(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			s := new(state)
+			req := c.Request()
+			h := decode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				s.nextErr = next(c)
+			}))
+			h.ServeHTTP(c.Response(), req.WithContext(context.WithValue(req.Context(), stateKey{}, s)))
+			if s.err != nil {
+				return toHTTPError(c, config.ErrorHandler, s.err)
+			}
+			return s.nextErr
+		}
+	}
+}
+
+type stateKey struct{}
+
+type state struct {
+	err     error
+	nextErr error
+}
+
+func captureError(w http.ResponseWriter, r *http.Request, err error) {
+	if s, ok := r.Context().Value(stateKey{}).(*state); ok {
+		s.err = err
+	}
+}
+
+func toHTTPError(c echo.Context, eh contentencoding.ErrorHandler, err error) error {
+	rec := &recorder{header: make(http.Header)}
+	eh(rec, c.Request(), err)
+	h := c.Response().Header()
+	for k, v := range rec.header {
+		if k == "Content-Type" || k == "X-Content-Type-Options" {
+			continue // Echo writes its own body.
+		}
+		h[k] = v
+	}
+	code := rec.code
+	if code == 0 {
+		code = http.StatusBadRequest
+	}
+	msg := strings.TrimSpace(rec.body.String())
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	return echo.NewHTTPError(code, msg).SetInternal(err)
+}
+
+type recorder struct {
+	header http.Header
+	code   int
+	body   strings.Builder
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(b)
+}
@@ -0,0 +1,145 @@
+package ceecho_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johejo/go-content-encoding/ceecho"
+	"github.com/labstack/echo/v4"
+)
+
+func gzipBody(t *testing.T, s string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestDecode(t *testing.T) {
+	e := echo.New()
+	e.Use(ceecho.Decode())
+	e.POST("/", func(c echo.Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(b))
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", gzipBody(t, "test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("should be 200 but got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "test" {
+		t.Errorf("should be test but got='%s'", got)
+	}
+}
+
+func TestDecode_error(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ceecho.Config
+		code   int
+	}{
+		{"default", ceecho.Config{}, http.StatusBadRequest},
+		{
+			"custom",
+			ceecho.Config{
+				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+					w.Header().Set("X-Test", "custom")
+					http.Error(w, "custom", http.StatusUnsupportedMediaType)
+				},
+			},
+			http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var herr *echo.HTTPError
+			e := echo.New()
+			e.HTTPErrorHandler = func(err error, c echo.Context) {
+				herr, _ = err.(*echo.HTTPError)
+				e.DefaultHTTPErrorHandler(err, c)
+			}
+			e.Use(ceecho.DecodeWithConfig(tt.config))
+			e.POST("/", func(c echo.Context) error {
+				t.Error("handler should not be called")
+				return nil
+			})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test")) // not compressed
+			req.Header.Set("Content-Encoding", "gzip")
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Errorf("should be %d but got %d", tt.code, rec.Code)
+			}
+			if herr == nil {
+				t.Fatal("should be *echo.HTTPError")
+			}
+			if herr.Internal == nil {
+				t.Error("internal error should be set")
+			}
+			if tt.config.ErrorHandler != nil && rec.Header().Get("X-Test") != "custom" {
+				t.Errorf("header should be copied, %v", rec.Header())
+			}
+		})
+	}
+}
+
+func TestDecodeWithConfig_Skipper(t *testing.T) {
+	e := echo.New()
+	e.Use(ceecho.DecodeWithConfig(ceecho.Config{
+		Skipper: func(c echo.Context) bool { return true },
+	}))
+	e.POST("/", func(c echo.Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(b))
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	e.ServeHTTP(rec, req)
+
+	if got := rec.Body.String(); got != "test" {
+		t.Errorf("body should not be decoded but got='%s'", got)
+	}
+}
+
+func TestDecode_handlerError(t *testing.T) {
+	e := echo.New()
+	e.Use(ceecho.Decode())
+	e.POST("/", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot)
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", gzipBody(t, "test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("handler error should be propagated, got %d", rec.Code)
+	}
+}
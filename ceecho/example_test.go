@@ -0,0 +1,21 @@
+package ceecho_test
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/johejo/go-content-encoding/ceecho"
+	"github.com/labstack/echo/v4"
+)
+
+func ExampleDecode() {
+	e := echo.New()
+	e.Use(ceecho.Decode())
+	e.POST("/", func(c echo.Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(b)) // decoded body
+	})
+}
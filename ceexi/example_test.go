@@ -0,0 +1,16 @@
+package ceexi_test
+
+import (
+	"net/http"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/ceexi"
+)
+
+func ExampleDecoder() {
+	exi := ceexi.Decoder(&ceexi.Command{Path: "/usr/local/bin/exi2xml"})
+	decode := contentencoding.Decode(contentencoding.WithDecoder(exi), contentencoding.WithStrict())
+	http.Handle("/soap", decode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// r.Body is the XML document.
+	})))
+}
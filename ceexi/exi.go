@@ -0,0 +1,129 @@
+// Package ceexi provides the "exi" content coding, Efficient XML Interchange, for go-content-encoding.
+//
+// There is no EXI processor written in Go, so the coding is pluggable by Processor
+// and Command runs an external one such as EXIficient or exip as a reference implementation.
+package ceexi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// Encoding is the content coding of EXI registered to IANA.
+const Encoding = "exi"
+
+// Processor converts EXI streams to XML.
+type Processor interface {
+	// NewReader returns a reader of the XML document decoded from the EXI stream r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Decoder returns a contentencoding.Decoder of the exi coding with p.
+func Decoder(p Processor) *contentencoding.Decoder {
+	return &contentencoding.Decoder{
+		Encoding:  Encoding,
+		NewReader: p.NewReader,
+	}
+}
+
+// Command is a Processor that runs an external command reading EXI from stdin and writing XML to stdout,
+// e.g. a wrapper script of EXIficient.
+// The command is started for each body, the process is killed if the body is closed before the end.
+type Command struct {
+	// Path is the path of the command.
+	Path string
+	// Args are the arguments of the command.
+	Args []string
+	// Env is the environment of the command, the current one if nil.
+	Env []string
+}
+
+// NewReader implements Processor.
+func (c *Command) NewReader(r io.Reader) (io.ReadCloser, error) {
+	cmd := exec.Command(c.Path, c.Args...)
+	cmd.Env = c.Env
+	stderr := &limitedBuffer{max: 1 << 10}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	// the copy is not waited for, since r may block until the request body is closed.
+	go func() {
+		io.Copy(stdin, r)
+		stdin.Close()
+	}()
+	return &commandReader{stdout: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+type commandReader struct {
+	stdout io.Reader
+	cmd    *exec.Cmd
+	stderr *limitedBuffer
+	done   bool
+	err    error
+}
+
+func (cr *commandReader) Read(p []byte) (int, error) {
+	if cr.done {
+		return 0, cr.err
+	}
+	n, err := cr.stdout.Read(p)
+	if err == io.EOF {
+		cr.done = true
+		cr.err = io.EOF
+		if werr := cr.cmd.Wait(); werr != nil {
+			cr.err = cr.exitError(werr)
+		}
+		return n, cr.err
+	}
+	return n, err
+}
+
+func (cr *commandReader) Close() error {
+	if cr.done {
+		return nil
+	}
+	cr.done = true
+	cr.err = errors.New("ceexi: read after close")
+	cr.cmd.Process.Kill()
+	cr.cmd.Wait()
+	return nil
+}
+
+func (cr *commandReader) exitError(err error) error {
+	if msg := strings.TrimSpace(cr.stderr.String()); msg != "" {
+		return fmt.Errorf("ceexi: %s: %w: %s", cr.cmd.Path, err, msg)
+	}
+	return fmt.Errorf("ceexi: %s: %w", cr.cmd.Path, err)
+}
+
+// limitedBuffer keeps up to max bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if rest := b.max - b.Len(); rest > 0 {
+		if len(p) > rest {
+			b.Buffer.Write(p[:rest])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
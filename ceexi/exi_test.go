@@ -0,0 +1,90 @@
+//go:build !js
+// +build !js
+
+// The tests run the test binary as the external processor, which is not possible on js/wasm.
+
+package ceexi_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/ceexi"
+)
+
+// TestMain runs the test binary as a fake EXI processor when CEEXI_HELPER is set.
+func TestMain(m *testing.M) {
+	switch os.Getenv("CEEXI_HELPER") {
+	case "":
+		os.Exit(m.Run())
+	case "decode":
+		b, _ := ioutil.ReadAll(os.Stdin)
+		fmt.Printf("<doc>%s</doc>", bytes.TrimPrefix(b, []byte("EXI:")))
+		os.Exit(0)
+	case "fail":
+		io.Copy(ioutil.Discard, os.Stdin)
+		fmt.Fprint(os.Stderr, "invalid EXI header")
+		os.Exit(1)
+	}
+}
+
+func helper(mode string) *ceexi.Command {
+	return &ceexi.Command{Path: os.Args[0], Env: append(os.Environ(), "CEEXI_HELPER="+mode)}
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr string
+	}{
+		{"decode", "<doc>test</doc>", ""},
+		{"fail", "", "invalid EXI header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithDecoder(ceexi.Decoder(helper(tt.mode))))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Errorf("error should contain %q but got %v", tt.wantErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tt.want {
+					t.Errorf("body should be %s but got %s", tt.want, b)
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("EXI:test"))
+			req.Header.Set("Content-Encoding", ceexi.Encoding)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+}
+
+func TestCommand_Close(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	rc, err := helper("decode").NewReader(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the process waiting for stdin is killed.
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.Read(make([]byte, 1)); err == nil {
+		t.Error("read after close should be error")
+	}
+}
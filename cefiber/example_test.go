@@ -0,0 +1,14 @@
+package cefiber_test
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/johejo/go-content-encoding/cefiber"
+)
+
+func ExampleNew() {
+	app := fiber.New()
+	app.Use(cefiber.New())
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.Send(c.Request().Body()) // decoded body
+	})
+}
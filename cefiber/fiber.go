@@ -0,0 +1,194 @@
+// Package cefiber provides a Fiber middleware for go-content-encoding.
+// Unlike Fiber's compress middleware, which only handles responses,
+// it decodes compressed request bodies with the decoders and options of go-content-encoding.
+package cefiber
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// Config is the configuration for New.
+type Config struct {
+	// Next defines a function to skip the middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+	// Options are passed to contentencoding.Decode.
+	// WithErrorHandler is ignored, use ErrorHandler instead.
+	Options []contentencoding.Option
+	// ErrorHandler decides the status code and message of the returned *fiber.Error.
+	// It is called with a recorder instead of the real response,
+	// the recorded status code becomes Error.Code and the trimmed body becomes Error.Message.
+	// Headers set by ErrorHandler are copied to the response.
+	// Default is contentencoding.DefaultErrorHandler.
+	ErrorHandler contentencoding.ErrorHandler
+}
+
+// New returns a Fiber middleware that automatically decodes body detected by Content-Encoding.
+// Fiber buffers request bodies, so the decoded body replaces the raw body and Content-Encoding is set to
+// the codings left undecoded, or removed if all codings are decoded. With WithPassthrough, it is kept as it is.
+func New(config ...Config) fiber.Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = contentencoding.DefaultErrorHandler
+	}
+	opts := make([]contentencoding.Option, 0, len(cfg.Options)+1)
+	opts = append(opts, cfg.Options...)
+	opts = append(opts, contentencoding.WithErrorHandler(captureError))
+	m := contentencoding.New(opts...)
+	caps := m.Capabilities()
+	decode := m.Handler(http.HandlerFunc(readBody))
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+		if len(c.Request().Header.Peek(fiber.HeaderContentEncoding)) == 0 {
+			return c.Next()
+		}
+
+		s := new(state)
+		req, err := newRequest(context.WithValue(c.UserContext(), stateKey{}, s), c)
+		if err != nil {
+			return err
+		}
+		s.raw = req.Body
+		rec := newRecorder()
+		decode.ServeHTTP(rec, req)
+		if s.err != nil {
+			return toError(c, cfg.ErrorHandler, req, s.err)
+		}
+		if !s.decoded {
+			return c.Next()
+		}
+
+		c.Request().SetBody(s.body)
+		if caps.Passthrough {
+			return c.Next()
+		}
+		if remaining := undecoded(req.Header.Get(fiber.HeaderContentEncoding), caps.Codings); remaining != "" {
+			c.Request().Header.Set(fiber.HeaderContentEncoding, remaining)
+		} else {
+			c.Request().Header.Del(fiber.HeaderContentEncoding)
+		}
+		return c.Next()
+	}
+}
+
+// undecoded returns the codings of contentEncoding without a decoder among supported, in the order they were applied.
+func undecoded(contentEncoding string, supported []string) string {
+	values, _ := contentencoding.ParseContentEncoding(contentEncoding)
+	var codings []string
+	for _, v := range values {
+		c := contentencoding.CanonicalCoding(v.Coding)
+		if c == "identity" {
+			continue
+		}
+		found := false
+		for _, s := range supported {
+			found = found || s == c
+		}
+		if !found {
+			codings = append(codings, c)
+		}
+	}
+	return strings.Join(codings, ", ")
+}
+
+type stateKey struct{}
+
+type state struct {
+	raw     io.ReadCloser
+	decoded bool
+	body    []byte
+	err     error
+}
+
+func captureError(w http.ResponseWriter, r *http.Request, err error) {
+	if s, ok := r.Context().Value(stateKey{}).(*state); ok {
+		s.err = err
+	}
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(stateKey{}).(*state)
+	if r.Body == s.raw {
+		return // e.g. GET or identity
+	}
+	s.decoded = true
+	s.body, s.err = io.ReadAll(r.Body)
+	if err := r.Body.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+func newRequest(ctx context.Context, c *fiber.Ctx) (*http.Request, error) {
+	raw := c.Request().Body()
+	req, err := http.NewRequestWithContext(ctx, c.Method(), c.OriginalURL(), bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Host = c.Hostname()
+	req.RemoteAddr = c.Context().RemoteAddr().String()
+	c.Request().Header.VisitAll(func(k, v []byte) {
+		req.Header.Add(string(k), string(v))
+	})
+	return req, nil
+}
+
+func toError(c *fiber.Ctx, eh contentencoding.ErrorHandler, r *http.Request, err error) error {
+	rec := newRecorder()
+	eh(rec, r, err)
+	for k, vs := range rec.header {
+		if k == fiber.HeaderContentType || k == fiber.HeaderXContentTypeOptions {
+			continue // Fiber writes its own body.
+		}
+		for _, v := range vs {
+			c.Response().Header.Add(k, v)
+		}
+	}
+	code := rec.code
+	if code == 0 {
+		code = http.StatusBadRequest
+	}
+	msg := strings.TrimSpace(rec.body.String())
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	return fiber.NewError(code, msg)
+}
+
+type recorder struct {
+	header http.Header
+	code   int
+	body   strings.Builder
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header)}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(b)
+}
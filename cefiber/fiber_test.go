@@ -0,0 +1,180 @@
+package cefiber_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cefiber"
+)
+
+func gzipBody(t *testing.T, s string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func newApp(config ...cefiber.Config) *fiber.App {
+	app := fiber.New()
+	app.Use(cefiber.New(config...))
+	app.All("/", func(c *fiber.Ctx) error {
+		c.Set("X-Content-Encoding", string(c.Request().Header.Peek(fiber.HeaderContentEncoding)))
+		return c.Send(c.Request().Body())
+	})
+	return app
+}
+
+func do(t *testing.T, app *fiber.App, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestNew(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", gzipBody(t, "test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, body := do(t, newApp(), req)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("should be 200 but got %d", resp.StatusCode)
+	}
+	if body != "test" {
+		t.Errorf("should be test but got='%s'", body)
+	}
+	if ce := resp.Header.Get("X-Content-Encoding"); ce != "" {
+		t.Errorf("Content-Encoding should be removed but got='%s'", ce)
+	}
+}
+
+func TestNew_WithDecoder(t *testing.T) {
+	customDecoder := &contentencoding.Decoder{
+		Encoding: "custom",
+		Handler: func(w http.ResponseWriter, r *http.Request) error {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				return err
+			}
+			r.Body = io.NopCloser(strings.NewReader(string(b) + "-custom"))
+			return nil
+		},
+	}
+	app := newApp(cefiber.Config{
+		Options: []contentencoding.Option{contentencoding.WithDecoder(customDecoder)},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", "custom")
+	_, body := do(t, app, req)
+
+	if body != "test-custom" {
+		t.Errorf("should be test-custom but got='%s'", body)
+	}
+}
+
+func TestNew_notDecoded(t *testing.T) {
+	tests := []struct {
+		name   string
+		config cefiber.Config
+		method string
+	}{
+		{"GET", cefiber.Config{}, http.MethodGet},
+		{"Next", cefiber.Config{Next: func(c *fiber.Ctx) bool { return true }}, http.MethodPost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader("test"))
+			req.Header.Set("Content-Encoding", "gzip")
+			_, body := do(t, newApp(tt.config), req)
+
+			if body != "test" {
+				t.Errorf("body should not be decoded but got='%s'", body)
+			}
+		})
+	}
+}
+
+func TestNew_error(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   cefiber.Config
+		encoding string
+		code     int
+	}{
+		{"gzip", cefiber.Config{}, "gzip", http.StatusBadRequest},
+		{"brotli", cefiber.Config{}, "br", http.StatusBadRequest}, // fails while reading
+		{
+			"custom",
+			cefiber.Config{
+				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+					w.Header().Set("X-Test", "custom")
+					http.Error(w, "custom", http.StatusUnsupportedMediaType)
+				},
+			},
+			"gzip",
+			http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test")) // not compressed
+			req.Header.Set("Content-Encoding", tt.encoding)
+			resp, _ := do(t, newApp(tt.config), req)
+
+			if resp.StatusCode != tt.code {
+				t.Errorf("should be %d but got %d", tt.code, resp.StatusCode)
+			}
+			if tt.config.ErrorHandler != nil && resp.Header.Get("X-Test") != "custom" {
+				t.Errorf("header should be copied, %v", resp.Header)
+			}
+		})
+	}
+}
+
+func TestNew_partlyDecoded(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   cefiber.Config
+		encoding string
+		want     string
+		wantCE   string
+	}{
+		{"undecoded", cefiber.Config{}, "custom, gzip", "test", "custom"},
+		{"passthrough", cefiber.Config{Options: []contentencoding.Option{contentencoding.WithPassthrough()}}, "gzip", gzipBody(t, "test").String(), "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", gzipBody(t, "test"))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			resp, body := do(t, newApp(tt.config), req)
+
+			if body != tt.want {
+				t.Errorf("should be %q but got %q", tt.want, body)
+			}
+			if ce := resp.Header.Get("X-Content-Encoding"); ce != tt.wantCE {
+				t.Errorf("Content-Encoding should be '%s' but got='%s'", tt.wantCE, ce)
+			}
+		})
+	}
+}
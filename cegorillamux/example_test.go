@@ -0,0 +1,23 @@
+package cegorillamux_test
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/johejo/go-content-encoding/cegorillamux"
+)
+
+func ExampleDecode() {
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(cegorillamux.Decode())
+	api.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body) // decoded body
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write(append([]byte(mux.Vars(r)["id"]+": "), b...))
+	}).Methods(http.MethodPost)
+}
@@ -0,0 +1,13 @@
+module github.com/johejo/go-content-encoding/cegorillamux
+
+go 1.18
+
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/johejo/go-content-encoding v0.2.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+)
@@ -0,0 +1,24 @@
+// Package cegorillamux provides a gorilla/mux middleware for go-content-encoding.
+//
+// Wrapping the whole Router with contentencoding.Decode decodes requests before they are routed,
+// so a request with a broken body gets 400 Bad Request even for an unknown path or method.
+// The middleware of this package is added by Router.Use instead, it runs only for matched routes,
+// and NotFoundHandler and MethodNotAllowedHandler of the Router see the request as it is.
+// Route variables of mux.Vars and mux.CurrentRoute are kept.
+package cegorillamux
+
+import (
+	"github.com/gorilla/mux"
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// Decode returns a mux.MiddlewareFunc that automatically decodes body detected by Content-Encoding.
+func Decode(opts ...contentencoding.Option) mux.MiddlewareFunc {
+	return mux.MiddlewareFunc(contentencoding.Decode(opts...))
+}
+
+// For returns a mux.MiddlewareFunc that decodes as contentencoding.For for the route, e.g. a path template of the Router,
+// so that a subrouter or a single route has its own configuration and its stats are tagged by the route.
+func For(route string, opts ...contentencoding.Option) mux.MiddlewareFunc {
+	return contentencoding.For(route, opts...).Handler
+}
@@ -0,0 +1,77 @@
+package cegorillamux_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cegorillamux"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestDecode(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(cegorillamux.Decode())
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(mux.Vars(r)["id"] + ":" + string(b)))
+	}).Methods(http.MethodPost)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		req    func(method, path string) *http.Request
+		want   int
+		body   string
+	}{
+		{"decoded", http.MethodPost, "/items/1", compressed, http.StatusOK, "1:test"},
+		{"not found", http.MethodPost, "/unknown", broken, http.StatusNotFound, ""},
+		{"method not allowed", http.MethodPut, "/items/1", broken, http.StatusMethodNotAllowed, ""},
+		{"broken", http.MethodPost, "/items/1", broken, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, tt.req(tt.method, tt.path))
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body should be %s but got %s", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	var route string
+	r := mux.NewRouter()
+	r.Use(cegorillamux.For("/items/{id}", contentencoding.WithStatsHook(func(r *http.Request, s contentencoding.Stats) {
+		route = s.Route
+	})))
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), compressed(http.MethodPost, "/items/1"))
+	if route != "/items/{id}" {
+		t.Errorf("route should be /items/{id} but got %q", route)
+	}
+}
+
+func compressed(method, path string) *http.Request {
+	return contentencodingtest.NewCompressedRequest(method, path, []byte("test"), "gzip")
+}
+
+func broken(method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	return req
+}
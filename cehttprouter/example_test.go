@@ -0,0 +1,21 @@
+package cehttprouter_test
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/johejo/go-content-encoding/cehttprouter"
+	"github.com/julienschmidt/httprouter"
+)
+
+func ExampleHandle() {
+	router := httprouter.New()
+	router.POST("/items/:id", cehttprouter.Handle(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		b, err := io.ReadAll(r.Body) // decoded body
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write(append([]byte(ps.ByName("id")+": "), b...))
+	}))
+}
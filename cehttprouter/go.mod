@@ -0,0 +1,13 @@
+module github.com/johejo/go-content-encoding/cehttprouter
+
+go 1.18
+
+require (
+	github.com/johejo/go-content-encoding v0.2.0
+	github.com/julienschmidt/httprouter v1.3.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+)
@@ -0,0 +1,36 @@
+// Package cehttprouter provides an httprouter adapter for go-content-encoding.
+//
+// httprouter.Handle receives the route parameters as an argument, which is lost by wrapping it as http.Handler.
+// Handle decodes the request body while passing the parameters through,
+// and Router.NotFound, Router.MethodNotAllowed and Router.PanicHandler keep working as without decoding.
+package cehttprouter
+
+import (
+	"context"
+	"net/http"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/julienschmidt/httprouter"
+)
+
+type paramsKey struct{}
+
+// Handle returns h wrapped to automatically decode body detected by Content-Encoding.
+func Handle(h httprouter.Handle, opts ...contentencoding.Option) httprouter.Handle {
+	return wrap(h, contentencoding.Decode(opts...))
+}
+
+// For returns h wrapped to decode as contentencoding.For for the route, e.g. the path registered to the Router.
+func For(route string, h httprouter.Handle, opts ...contentencoding.Option) httprouter.Handle {
+	return wrap(h, contentencoding.For(route, opts...).Handler)
+}
+
+func wrap(h httprouter.Handle, mw func(http.Handler) http.Handler) httprouter.Handle {
+	decoded := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps, _ := r.Context().Value(paramsKey{}).(httprouter.Params)
+		h(w, r, ps)
+	}))
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		decoded.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, ps)))
+	}
+}
@@ -0,0 +1,69 @@
+package cehttprouter_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cehttprouter"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestHandle(t *testing.T) {
+	router := httprouter.New()
+	router.POST("/items/:id", cehttprouter.Handle(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(ps.ByName("id") + ":" + string(b)))
+	}))
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+		body string
+	}{
+		{"decoded", contentencodingtest.NewCompressedRequest(http.MethodPost, "/items/1", []byte("test"), "gzip"), http.StatusOK, "1:test"},
+		{"not found", broken(http.MethodPost, "/unknown"), http.StatusNotFound, ""},
+		{"method not allowed", broken(http.MethodPut, "/items/1"), http.StatusMethodNotAllowed, ""},
+		{"broken", broken(http.MethodPost, "/items/1"), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body should be %s but got %s", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	var route string
+	router := httprouter.New()
+	router.POST("/items/:id", cehttprouter.For("/items/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		io.ReadAll(r.Body)
+	}, contentencoding.WithStatsHook(func(r *http.Request, s contentencoding.Stats) {
+		route = s.Route
+	})))
+	router.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/items/1", []byte("test"), "gzip"))
+	if route != "/items/:id" {
+		t.Errorf("route should be /items/:id but got %q", route)
+	}
+}
+
+func broken(method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	return req
+}
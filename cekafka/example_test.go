@@ -0,0 +1,37 @@
+package cekafka_test
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/johejo/go-content-encoding/cekafka"
+	"github.com/segmentio/kafka-go"
+)
+
+func ExampleReader() {
+	kr := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{"localhost:9092"},
+		GroupID: "example",
+		Topic:   "events",
+	})
+	defer kr.Close()
+
+	ctx := context.Background()
+	r := cekafka.NewReader(kr)
+	for {
+		msg, err := r.FetchMessage(ctx)
+		var decodeErr *cekafka.DecodeError
+		switch {
+		case errors.As(err, &decodeErr):
+			log.Println(err) // msg can be sent to a dead letter topic here
+		case err != nil:
+			log.Fatal(err)
+		default:
+			log.Println(string(msg.Value)) // decoded value
+		}
+		if err := kr.CommitMessages(ctx, msg); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+module github.com/johejo/go-content-encoding/cekafka
+
+go 1.18
+
+require (
+	github.com/johejo/go-content-encoding v0.2.0
+	github.com/segmentio/kafka-go v0.4.47
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+)
@@ -0,0 +1,104 @@
+// Package cekafka provides kafka-go helpers for go-content-encoding.
+//
+// Producers set the content-encoding header of messages, and the Reader of this package
+// replaces the value of each fetched message with the decoded value,
+// with the same decoders and limits as the HTTP middleware.
+package cekafka
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/segmentio/kafka-go"
+)
+
+// Fetcher fetches messages, it is implemented by *kafka.Reader.
+type Fetcher interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+}
+
+// Reader is a Fetcher that decodes the values of messages fetched by another Fetcher.
+type Reader struct {
+	f    Fetcher
+	opts []contentencoding.Option
+}
+
+// NewReader returns a Reader that fetches messages from f and decodes them with opts.
+func NewReader(f Fetcher, opts ...contentencoding.Option) *Reader {
+	return &Reader{f: f, opts: opts}
+}
+
+// DecodeError is the error of Reader.FetchMessage for a message that failed to be decoded.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "cekafka: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// FetchMessage fetches the next message and decodes it as Decode.
+// If decoding fails, the message is returned as it is fetched together with DecodeError,
+// so that the fetch loop can commit it or send it to a dead letter topic.
+func (r *Reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := r.f.FetchMessage(ctx)
+	if err != nil {
+		return msg, err
+	}
+	decoded := msg
+	if err := Decode(&decoded, r.opts...); err != nil {
+		return msg, &DecodeError{Err: err}
+	}
+	return decoded, nil
+}
+
+// Decode replaces the value of msg with the value decoded by its content-encoding headers, and removes the headers.
+// The header keys are matched case-insensitively. msg is left as it is if decoding fails.
+func Decode(msg *kafka.Message, opts ...contentencoding.Option) error {
+	m := message{header: make(http.Header), value: msg.Value}
+	var rest []kafka.Header
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Key, "Content-Encoding") {
+			m.header.Add("Content-Encoding", string(h.Value))
+		} else {
+			rest = append(rest, h)
+		}
+	}
+	if len(m.header) == 0 {
+		return nil
+	}
+	body, err := contentencoding.DecodeMessage(m, opts...)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	value, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	msg.Value = value
+	msg.Headers = rest
+	return nil
+}
+
+// message is contentencoding.Message of a Kafka message.
+type message struct {
+	header http.Header
+	value  []byte
+}
+
+func (m message) Headers() http.Header {
+	return m.header
+}
+
+func (m message) Body() io.Reader {
+	return bytes.NewReader(m.value)
+}
@@ -0,0 +1,80 @@
+package cekafka_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cekafka"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+	"github.com/segmentio/kafka-go"
+)
+
+type fetcher []kafka.Message
+
+func (f *fetcher) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(*f) == 0 {
+		return kafka.Message{}, context.Canceled
+	}
+	msg := (*f)[0]
+	*f = (*f)[1:]
+	return msg, nil
+}
+
+func TestReader(t *testing.T) {
+	payload := bytes.Repeat([]byte("kafka test "), 100)
+	zstded, err := contentencodingtest.CompressBody(payload, "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := kafka.Header{Key: "trace-id", Value: []byte("1")}
+	tests := []struct {
+		name    string
+		msg     kafka.Message
+		wantErr bool
+	}{
+		{"zstd", kafka.Message{Value: zstded, Headers: []kafka.Header{{Key: "content-encoding", Value: []byte("zstd")}, trace}}, false},
+		{"no header", kafka.Message{Value: payload, Headers: []kafka.Header{trace}}, false},
+		{"broken", kafka.Message{Value: payload, Headers: []kafka.Header{{Key: "Content-Encoding", Value: []byte("zstd")}, trace}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := cekafka.NewReader(&fetcher{tt.msg})
+			got, err := r.FetchMessage(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error should be returned %v but got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				var decodeErr *cekafka.DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Errorf("error should be DecodeError but got %v", err)
+				}
+				if !bytes.Equal(got.Value, tt.msg.Value) || len(got.Headers) != len(tt.msg.Headers) {
+					t.Error("message should be returned as it is fetched")
+				}
+				return
+			}
+			if !bytes.Equal(got.Value, payload) {
+				t.Error("value should be decoded")
+			}
+			if len(got.Headers) != 1 || got.Headers[0].Key != "trace-id" {
+				t.Errorf("headers other than content-encoding should be kept but got %v", got.Headers)
+			}
+		})
+	}
+}
+
+func TestDecode_Limits(t *testing.T) {
+	value, err := contentencodingtest.CompressBody(bytes.Repeat([]byte("a"), 1000), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := kafka.Message{Value: value, Headers: []kafka.Header{{Key: "content-encoding", Value: []byte("gzip")}}}
+	err = cekafka.Decode(&msg, contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 10}))
+	var limitErr *contentencoding.LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("error should be LimitError but got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package cenats_test
+
+import (
+	"log"
+
+	"github.com/johejo/go-content-encoding/cenats"
+	"github.com/nats-io/nats.go"
+)
+
+func ExampleHandler() {
+	nc, err := nats.Connect(nats.DefaultURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer nc.Close()
+
+	h := cenats.Handler(func(msg *nats.Msg) {
+		log.Println(string(msg.Data)) // decoded data
+	}, func(msg *nats.Msg, err error) {
+		log.Printf("dropped a message of %s: %v", msg.Subject, err)
+	})
+	if _, err := nc.Subscribe("events", h); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/johejo/go-content-encoding/cenats
+
+go 1.18
+
+require (
+	github.com/johejo/go-content-encoding v0.2.0
+	github.com/nats-io/nats.go v1.31.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/nats-io/nkeys v0.4.6 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
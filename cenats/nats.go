@@ -0,0 +1,66 @@
+// Package cenats provides a NATS message handler for go-content-encoding.
+//
+// Publishers set the Content-Encoding header of messages, and the handler of this package
+// replaces the data of each message with the decoded data before calling the wrapped handler,
+// with the same decoders and limits as the HTTP middleware.
+package cenats
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrorHandler is called with a message that failed to be decoded.
+type ErrorHandler func(msg *nats.Msg, err error)
+
+// Handler returns a nats.MsgHandler that decodes the data of messages by their Content-Encoding header and calls h.
+// The header is removed from decoded messages, so h sees them as if they were published without encoding.
+// If decoding fails, eh is called instead of h, messages are dropped if eh is nil.
+func Handler(h nats.MsgHandler, eh ErrorHandler, opts ...contentencoding.Option) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		if err := Decode(msg, opts...); err != nil {
+			if eh != nil {
+				eh(msg, err)
+			}
+			return
+		}
+		h(msg)
+	}
+}
+
+// Decode replaces the data of msg with the data decoded by its Content-Encoding header, and removes the header.
+// msg is left as it is if decoding fails.
+func Decode(msg *nats.Msg, opts ...contentencoding.Option) error {
+	if msg.Header.Get("Content-Encoding") == "" {
+		return nil
+	}
+	body, err := contentencoding.DecodeMessage(message{msg}, opts...)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+	msg.Header.Del("Content-Encoding")
+	return nil
+}
+
+// message is contentencoding.Message of a NATS message.
+type message struct {
+	msg *nats.Msg
+}
+
+func (m message) Headers() http.Header {
+	return http.Header(m.msg.Header)
+}
+
+func (m message) Body() io.Reader {
+	return bytes.NewReader(m.msg.Data)
+}
@@ -0,0 +1,71 @@
+package cenats_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cenats"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+	"github.com/nats-io/nats.go"
+)
+
+func TestHandler(t *testing.T) {
+	payload := bytes.Repeat([]byte("nats test "), 100)
+	gzipped, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		header   nats.Header
+		data     []byte
+		opts     []contentencoding.Option
+		wantErr  bool
+		wantData []byte
+	}{
+		{"gzip", nats.Header{"Content-Encoding": {"gzip"}}, gzipped, nil, false, payload},
+		{"no header", nil, payload, nil, false, payload},
+		{"broken", nats.Header{"Content-Encoding": {"gzip"}}, payload, nil, true, nil},
+		{"unsupported", nats.Header{"Content-Encoding": {"deflate"}}, payload, nil, true, nil},
+		{"too large", nats.Header{"Content-Encoding": {"gzip"}}, gzipped, []contentencoding.Option{contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 10})}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got    *nats.Msg
+				gotErr error
+			)
+			h := cenats.Handler(func(msg *nats.Msg) {
+				got = msg
+			}, func(msg *nats.Msg, err error) {
+				gotErr = err
+			}, tt.opts...)
+			h(&nats.Msg{Subject: "test", Header: tt.header, Data: tt.data})
+
+			if (gotErr != nil) != tt.wantErr {
+				t.Fatalf("error should be returned %v but got %v", tt.wantErr, gotErr)
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Error("handler should not be called")
+				}
+				var limitErr *contentencoding.LimitError
+				if tt.name == "too large" && !errors.As(gotErr, &limitErr) {
+					t.Errorf("error should be LimitError but got %v", gotErr)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("handler should be called")
+			}
+			if !bytes.Equal(got.Data, tt.wantData) {
+				t.Error("data should be decoded")
+			}
+			if v := got.Header.Get("Content-Encoding"); v != "" {
+				t.Errorf("Content-Encoding should be removed but got %q", v)
+			}
+		})
+	}
+}
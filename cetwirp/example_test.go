@@ -0,0 +1,16 @@
+package cetwirp_test
+
+import (
+	"net/http"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cetwirp"
+)
+
+func ExampleWrap() {
+	limits := contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1 << 20})
+	var server http.Handler = http.NotFoundHandler() // the generated Twirp server, e.g. NewHaberdasherServer(impl)
+	mux := http.NewServeMux()
+	mux.Handle("/twirp/", cetwirp.Wrap(server, limits))
+	mux.Handle("/upload", contentencoding.Decode(limits)(http.NotFoundHandler()))
+}
@@ -0,0 +1,13 @@
+module github.com/johejo/go-content-encoding/cetwirp
+
+go 1.18
+
+require (
+	github.com/johejo/go-content-encoding v0.2.0
+	github.com/twitchtv/twirp v8.1.3+incompatible
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+)
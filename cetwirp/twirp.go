@@ -0,0 +1,101 @@
+// Package cetwirp provides go-content-encoding integration for Twirp servers.
+//
+// Twirp ServerHooks and interceptors run after the server has read the request body,
+// so decoding is done by wrapping the server as http.Handler with Wrap, before Twirp reads the body.
+// The same Options as the HTTP middleware are accepted, so limits can be shared between both.
+// Errors are written in the Twirp error format instead of plain text.
+package cetwirp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/twitchtv/twirp"
+)
+
+// Wrap returns server wrapped to decode request bodies detected by Content-Encoding
+// and to compress successful responses with the coding negotiated by Accept-Encoding.
+// WithErrorHandler in opts is overridden by ErrorHandler.
+// Limits exceeded while Twirp reads the body are reported by Twirp as malformed requests.
+func Wrap(server http.Handler, opts ...contentencoding.Option) http.Handler {
+	opts = append(opts[:len(opts):len(opts)], contentencoding.WithErrorHandler(ErrorHandler))
+	decoded := contentencoding.Decode(opts...)(server)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coding := "identity"
+		if ae := r.Header.Values("Accept-Encoding"); len(ae) > 0 {
+			coding, _ = contentencoding.Negotiate(strings.Join(ae, ","), []string{"br", "zstd", "gzip"})
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if coding == "" || coding == "identity" {
+			decoded.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, coding: coding}
+		defer cw.close()
+		decoded.ServeHTTP(cw, r)
+	})
+}
+
+// ErrorHandler is a contentencoding.ErrorHandler that writes errors as Twirp errors.
+// Limit errors are resource_exhausted, unsupported codings are unimplemented and the others are malformed.
+func ErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		limitErr       *contentencoding.LimitError
+		unsupportedErr *contentencoding.UnsupportedEncodingError
+	)
+	code := twirp.Malformed
+	switch {
+	case errors.As(err, &limitErr):
+		code = twirp.ResourceExhausted
+	case errors.As(err, &unsupportedErr):
+		code = twirp.Unimplemented
+	}
+	twirp.WriteError(w, twirp.NewError(code, err.Error()))
+}
+
+// compressWriter compresses responses with status 200, Twirp error responses are left as they are.
+type compressWriter struct {
+	http.ResponseWriter
+	coding string
+
+	wroteHeader bool
+	enc         interface {
+		Write(p []byte) (int, error)
+		Close() error
+	}
+	err error
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	if status == http.StatusOK && cw.Header().Get("Content-Encoding") == "" {
+		cw.Header().Del("Content-Length")
+		cw.Header().Set("Content-Encoding", cw.coding)
+		cw.enc, cw.err = contentencoding.NewWriter(cw.ResponseWriter, cw.coding)
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(p)
+	}
+	return cw.enc.Write(p)
+}
+
+func (cw *compressWriter) close() {
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
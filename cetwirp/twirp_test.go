@@ -0,0 +1,85 @@
+package cetwirp_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/cetwirp"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+	"github.com/twitchtv/twirp"
+)
+
+// echoServer behaves as a Twirp JSON server that echoes the request.
+var echoServer = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		twirp.WriteError(w, twirp.NewError(twirp.Malformed, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+})
+
+func TestWrap(t *testing.T) {
+	h := cetwirp.Wrap(echoServer, contentencoding.WithLimits(contentencoding.Limits{MaxLayers: 1}))
+	tests := []struct {
+		name     string
+		req      *http.Request
+		accept   string
+		status   int
+		encoding string
+		code     twirp.ErrorCode
+	}{
+		{"compressed", contentencodingtest.NewCompressedRequest(http.MethodPost, "/twirp/Echo/Say", []byte(`{"msg":"test"}`), "gzip"), "br, gzip", http.StatusOK, "br", ""},
+		{"identity", contentencodingtest.NewCompressedRequest(http.MethodPost, "/twirp/Echo/Say", []byte(`{"msg":"test"}`), "zstd"), "", http.StatusOK, "", ""},
+		{"malformed", broken("gzip"), "gzip", http.StatusBadRequest, "", twirp.Malformed},
+		{"limit", broken("gzip, gzip"), "gzip", http.StatusTooManyRequests, "", twirp.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.accept != "" {
+				tt.req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := contentencodingtest.NewRecorder()
+			h.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.status {
+				t.Errorf("status code should be %d but got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.encoding, got)
+			}
+			b, err := rec.DecodedBody()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.code == "" {
+				if string(b) != `{"msg":"test"}` {
+					t.Errorf("body should be the request but got %s", b)
+				}
+				return
+			}
+			var twerr struct {
+				Code twirp.ErrorCode `json:"code"`
+			}
+			if err := json.Unmarshal(b, &twerr); err != nil {
+				t.Fatal(err)
+			}
+			if twerr.Code != tt.code {
+				t.Errorf("error code should be %s but got %s", tt.code, twerr.Code)
+			}
+		})
+	}
+}
+
+func broken(encoding string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/twirp/Echo/Say", strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", encoding)
+	return req
+}
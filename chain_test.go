@@ -0,0 +1,101 @@
+package contentencoding_test
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func b64Writer(w io.Writer) (io.WriteCloser, error) {
+	return base64.NewEncoder(base64.StdEncoding, w), nil
+}
+
+var b64Decoder = &contentencoding.Decoder{
+	Encoding: "base64",
+	NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+	},
+}
+
+func TestDecode_Chain(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain contentencodingtest.Chain
+	}{
+		{"three built-ins", contentencodingtest.NewChain("br", "gzip", "zstd")},
+		{"same coding repeated", contentencodingtest.NewChain("gzip", "gzip", "gzip", "gzip")},
+		{"custom in the middle", contentencodingtest.NewChain("zstd", "gzip").Then("base64", b64Writer).Then("br", nil).Then("gzip", nil)},
+		{"custom at both ends", contentencodingtest.Chain{}.Then("base64", b64Writer).Then("zstd", nil).Then("base64", b64Writer)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithDecoder(b64Decoder))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "test" {
+					t.Errorf("should be test but got='%s'", b)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tt.chain.NewRequest(http.MethodPost, "/", []byte("test")))
+			if rec.Code != http.StatusOK {
+				t.Errorf("should be 200 but got %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	name   string
+	closed *[]string
+}
+
+func (c *closeRecorder) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return nil
+}
+
+func TestDecode_CloseOrder(t *testing.T) {
+	var closed []string
+	newDecoder := func(name string) *contentencoding.Decoder {
+		return &contentencoding.Decoder{
+			Encoding: name,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return &closeRecorder{Reader: r, name: name, closed: &closed}, nil
+			},
+		}
+	}
+	legacy := &contentencoding.Decoder{
+		Encoding: "legacy",
+		Handler: func(w http.ResponseWriter, r *http.Request) error {
+			// the replaced body does not close the body it reads.
+			r.Body = &closeRecorder{Reader: r.Body, name: "legacy", closed: &closed}
+			return nil
+		},
+	}
+	h := contentencoding.Decode(contentencoding.WithDecoder(newDecoder("a"), newDecoder("b"), legacy, newDecoder("c")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	body := &closeRecorder{Reader: strings.NewReader("test"), name: "body", closed: &closed}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Encoding", "a, legacy, b, c")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// the decoders are closed from the outermost reader down to the original body.
+	want := []string{"a", "legacy", "b", "c", "body"}
+	if !reflect.DeepEqual(closed, want) {
+		t.Errorf("should be %v but got %v", want, closed)
+	}
+}
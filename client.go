@@ -0,0 +1,189 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Transport is a http.RoundTripper that encodes request bodies with a content coding.
+// If the server responds 415 Unsupported Media Type with Accept-Encoding as RFC 7694 describes,
+// e.g. Decode with WithStrict, the request is retried once with the coding negotiated from it, which may be identity,
+// and the coding is remembered for the host.
+// Requests are retried only if GetBody is set, as http.NewRequest does for common bodies.
+// Requests that already have Content-Encoding are sent as they are.
+type Transport struct {
+	// Base is the RoundTripper to send requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Encoding is the coding tried first, br, gzip or zstd. If empty, gzip is used.
+	Encoding string
+	// Pool bounds the number of request bodies encoded at the same time if not nil, see EncoderPool.
+	Pool *EncoderPool
+
+	codings sync.Map // host -> coding
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return t.base().RoundTrip(req)
+	}
+	coding := t.coding(req.URL.Host)
+	resp, err := t.send(req, req.Body, coding)
+	if err != nil || resp.StatusCode != http.StatusUnsupportedMediaType || req.GetBody == nil {
+		return resp, err
+	}
+	accept := resp.Header.Values("Accept-Encoding")
+	if len(accept) == 0 {
+		return resp, nil
+	}
+	next, err := Negotiate(strings.Join(accept, ","), t.offered())
+	if err != nil || next == coding {
+		return resp, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	t.codings.Store(req.URL.Host, next)
+	return t.send(req, body, next)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// coding returns the coding to send to host.
+func (t *Transport) coding(host string) string {
+	if v, ok := t.codings.Load(host); ok {
+		return v.(string)
+	}
+	if t.Encoding != "" {
+		return t.Encoding
+	}
+	return "gzip"
+}
+
+// offered returns the codings to negotiate in the order of preference.
+func (t *Transport) offered() []string {
+	return append([]string{t.coding("")}, builtinEncodings...)
+}
+
+// send sends a clone of req with body encoded by coding.
+func (t *Transport) send(req *http.Request, body io.ReadCloser, coding string) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = body
+	if coding == "identity" {
+		return t.base().RoundTrip(out)
+	}
+	pr, pw := io.Pipe()
+	zw, err := t.Pool.encoder(req.Context(), func() (io.WriteCloser, error) {
+		return NewWriter(pw, coding)
+	})
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	go func() {
+		defer body.Close()
+		_, err := io.Copy(zw, body)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	out.Body = pr
+	out.GetBody = nil
+	out.ContentLength = -1
+	out.Header.Del("Content-Length")
+	out.Header.Set("Content-Encoding", coding)
+	return t.base().RoundTrip(out)
+}
+
+// DecodeTransport returns a http.RoundTripper that asks for responses encoded with br, zstd and gzip,
+// in the order of WithEncodingPreference, and decodes them as DecodeResponse does, e.g. within WithLimits.
+// Requests that already have Accept-Encoding or Range are sent as they are and their responses are not decoded,
+// as http.Transport does for gzip. If base is nil, http.DefaultTransport is used.
+// With GOOS=js, requests sent by the fetch API of browsers are negotiated and decoded by the browser,
+// so only the headers of responses encoded with br, gzip and zstd are fixed as if DecodeResponse decoded them.
+func DecodeTransport(base http.RoundTripper, opts ...Option) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cfg := newConfig(opts)
+	var accept []string
+	for _, e := range cfg.preference {
+		if cfg.builtinEnabled(e) {
+			accept = append(accept, e)
+		}
+	}
+	return &decodeTransport{
+		base:   base,
+		cfg:    cfg,
+		accept: strings.Join(accept, ", "),
+		decode: DecodeResponse(opts...),
+		fetch:  usesFetch(base),
+	}
+}
+
+type decodeTransport struct {
+	base   http.RoundTripper
+	cfg    *config
+	accept string
+	decode func(resp *http.Response) error
+	fetch  bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *decodeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.fetch {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			t.fetched(resp)
+		}
+		return resp, err
+	}
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+	out := req.Clone(req.Context())
+	out.Header.Set("Accept-Encoding", t.accept)
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.decode(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// fetched fixes the headers of resp whose body has been decoded by the fetch API,
+// which delivers decoded bodies while the transport of net/http removes Content-Encoding only for gzip.
+func (t *decodeTransport) fetched(resp *http.Response) {
+	if !hasBody(resp) {
+		return
+	}
+	values, err := contentCodings(resp.Header.Get("Content-Encoding"))
+	if err != nil || len(values) == 0 {
+		return
+	}
+	for _, v := range values {
+		if v != "identity" && !isBuiltin(v) {
+			// the fetch API does not decode other codings.
+			return
+		}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	t.cfg.responseFixup(resp, strings.Join(values, ", "), "identity")
+}
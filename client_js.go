@@ -0,0 +1,21 @@
+package contentencoding
+
+import (
+	"net/http"
+	"strings"
+	"syscall/js"
+)
+
+// usesFetch reports whether base sends requests by the fetch API, as http.Transport does
+// unless it has dial functions, fetch is missing or it runs on Node.js.
+func usesFetch(base http.RoundTripper) bool {
+	t, ok := base.(*http.Transport)
+	if !ok || t.Dial != nil || t.DialContext != nil || t.DialTLS != nil || t.DialTLSContext != nil {
+		return false
+	}
+	if js.Global().Get("fetch").IsUndefined() {
+		return false
+	}
+	process := js.Global().Get("process")
+	return !(process.Type() == js.TypeObject && strings.HasPrefix(process.Get("argv0").String(), "node"))
+}
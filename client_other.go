@@ -0,0 +1,11 @@
+//go:build !js
+// +build !js
+
+package contentencoding
+
+import "net/http"
+
+// usesFetch reports whether base sends requests by the fetch API, which is available only with GOOS=js.
+func usesFetch(base http.RoundTripper) bool {
+	return false
+}
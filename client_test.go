@@ -0,0 +1,181 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(contentencoding.Decode(contentencoding.WithStrict())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write(b)
+	})))
+	defer ts.Close()
+
+	for _, encoding := range []string{"", "br", "gzip", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			client := &http.Client{Transport: &contentencoding.Transport{Encoding: encoding}}
+			resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("test"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK || string(b) != "test" {
+				t.Errorf("response should be 200 test but got %d %s", resp.StatusCode, b)
+			}
+		})
+	}
+}
+
+func TestTransport_retry(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   []string
+	}{
+		{"negotiated", "gzip", []string{"br", "gzip", "gzip"}},
+		{"identity", "identity", []string{"br", "", ""}},
+		{"not acceptable", "identity;q=0", []string{"br", "br"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu   sync.Mutex
+				seen []string
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ce := r.Header.Get("Content-Encoding")
+				mu.Lock()
+				seen = append(seen, ce)
+				mu.Unlock()
+				if ce == "br" {
+					w.Header().Set("Accept-Encoding", tt.accept)
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				b, err := decompressRequest(r)
+				if err != nil {
+					t.Error(err)
+				}
+				if string(b) != "test" {
+					t.Errorf("body should be test but got %s", b)
+				}
+			}))
+			defer ts.Close()
+
+			client := &http.Client{Transport: &contentencoding.Transport{Encoding: "br"}}
+			for i := 0; i < 2; i++ {
+				resp, err := client.Post(ts.URL, "text/plain", bytes.NewReader([]byte("test")))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(seen, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Content-Encoding of requests should be %q but got %q", tt.want, seen)
+			}
+		})
+	}
+}
+
+func decompressRequest(r *http.Request) ([]byte, error) {
+	rc, err := contentencoding.NewReader(r.Body, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+func TestDecodeTransport(t *testing.T) {
+	content := strings.Repeat("decode transport test ", 1000)
+	var accepted string
+	ts := httptest.NewServer(contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(content))
+	})))
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		opts         []contentencoding.Option
+		accept       string
+		wantAccepted string
+		wantEncoding string
+	}{
+		{"default", nil, "", "br, zstd, gzip", ""},
+		{"preference", []contentencoding.Option{contentencoding.WithEncodingPreference("zstd")}, "", "zstd", ""},
+		{"accept by the caller", nil, "gzip", "gzip", "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: contentencoding.DecodeTransport(nil, tt.opts...)}
+			req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if accepted != tt.wantAccepted {
+				t.Errorf("Accept-Encoding should be %q but got %q", tt.wantAccepted, accepted)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.wantEncoding, got)
+			}
+			if tt.wantEncoding != "" {
+				return
+			}
+			b, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != content {
+				t.Error("decoded response should be the content")
+			}
+		})
+	}
+}
+
+func TestDecodeTransport_limits(t *testing.T) {
+	ts := httptest.NewServer(contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(bytes.Repeat([]byte("a"), 1<<20))
+	})))
+	defer ts.Close()
+
+	client := &http.Client{Transport: contentencoding.DecodeTransport(nil, contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1000}))}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var le *contentencoding.LimitError
+	if _, err := ioutil.ReadAll(resp.Body); !errors.As(err, &le) {
+		t.Errorf("error should be LimitError but got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+// Command cebench benchmarks the built-in codecs over a corpus.
+//
+// Usage:
+//
+//	cebench [-c br,gzip,zstd] [-n 3] file|dir...
+//
+// For each codec and level, it reports the compression ratio, the encode and decode throughput
+// of the uncompressed size, and the allocations per operation.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+type codec struct {
+	name      string
+	level     string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.Reader, error)
+}
+
+func codecs() []codec {
+	var cs []codec
+	for _, l := range []int{1, 4, 6, 9, 11} {
+		l := l
+		cs = append(cs, codec{
+			name:  "br",
+			level: fmt.Sprint(l),
+			newWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return brotli.NewWriterLevel(w, l), nil
+			},
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return brotli.NewReader(r), nil
+			},
+		})
+	}
+	for _, l := range []int{1, 6, 9} {
+		l := l
+		cs = append(cs, codec{
+			name:  "gzip",
+			level: fmt.Sprint(l),
+			newWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriterLevel(w, l)
+			},
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		})
+	}
+	for _, l := range []zstd.EncoderLevel{zstd.SpeedFastest, zstd.SpeedDefault, zstd.SpeedBetterCompression, zstd.SpeedBestCompression} {
+		l := l
+		cs = append(cs, codec{
+			name:  "zstd",
+			level: l.String(),
+			newWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return zstd.NewWriter(w, zstd.WithEncoderLevel(l))
+			},
+			newReader: func(r io.Reader) (io.Reader, error) {
+				zr, err := zstd.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return zr.IOReadCloser(), nil
+			},
+		})
+	}
+	return cs
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "cebench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("cebench", flag.ContinueOnError)
+	names := flags.String("c", "br,gzip,zstd", "comma-separated `codecs` to benchmark")
+	n := flags.Int("n", 3, "number of `iterations` per codec")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: cebench [-c codecs] [-n iterations] file|dir...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 || *n < 1 {
+		flags.Usage()
+		return fmt.Errorf("corpus is required")
+	}
+
+	corpus, err := loadCorpus(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(corpus) == 0 {
+		return fmt.Errorf("corpus is empty")
+	}
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(*names, ",") {
+		enabled[strings.TrimSpace(name)] = true
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "codec\tlevel\tratio\tencode MB/s\tdecode MB/s\tencode allocs/op\tdecode allocs/op\t")
+	for _, c := range codecs() {
+		if !enabled[c.name] {
+			continue
+		}
+		res, err := bench(c, corpus, *n)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", c.name, c.level, err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.3f\t%.1f\t%.1f\t%d\t%d\t\n",
+			c.name, c.level, res.ratio(), res.encodeMBps(), res.decodeMBps(), res.encodeAllocs, res.decodeAllocs)
+	}
+	return tw.Flush()
+}
+
+func loadCorpus(paths []string) ([][]byte, error) {
+	var corpus [][]byte
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			corpus = append(corpus, b)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return corpus, nil
+}
+
+type result struct {
+	size, compressed int64
+	encode, decode   time.Duration
+	encodeAllocs     uint64
+	decodeAllocs     uint64
+}
+
+func (r result) ratio() float64 {
+	if r.compressed == 0 {
+		return 0
+	}
+	return float64(r.size) / float64(r.compressed)
+}
+
+func (r result) encodeMBps() float64 { return mbps(r.size, r.encode) }
+func (r result) decodeMBps() float64 { return mbps(r.size, r.decode) }
+
+func mbps(size int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(size) / d.Seconds() / (1 << 20)
+}
+
+// bench encodes and decodes the corpus n times, allocations are reported per file.
+func bench(c codec, corpus [][]byte, n int) (result, error) {
+	var res result
+	ops := uint64(n * len(corpus))
+	compressed := make([][]byte, len(corpus))
+
+	var err error
+	var buf bytes.Buffer
+	res.encodeAllocs = mallocs(func() {
+		start := time.Now()
+		for i := 0; i < n && err == nil; i++ {
+			for j, b := range corpus {
+				buf.Reset()
+				if err = encode(c, &buf, b); err != nil {
+					return
+				}
+				compressed[j] = append(compressed[j][:0], buf.Bytes()...)
+			}
+		}
+		res.encode = time.Since(start)
+	}) / ops
+	if err != nil {
+		return res, err
+	}
+
+	res.decodeAllocs = mallocs(func() {
+		start := time.Now()
+		for i := 0; i < n && err == nil; i++ {
+			for _, b := range compressed {
+				if err = decode(c, b); err != nil {
+					return
+				}
+			}
+		}
+		res.decode = time.Since(start)
+	}) / ops
+	if err != nil {
+		return res, err
+	}
+
+	for j, b := range corpus {
+		res.size += int64(len(b))
+		res.compressed += int64(len(compressed[j]))
+	}
+	// throughput is of a single pass over the corpus.
+	res.encode /= time.Duration(n)
+	res.decode /= time.Duration(n)
+	return res, nil
+}
+
+func encode(c codec, dst io.Writer, b []byte) error {
+	w, err := c.newWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func decode(c codec, b []byte) error {
+	r, err := c.newReader(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if cl, ok := r.(io.Closer); ok {
+		defer cl.Close()
+	}
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+func mallocs(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.Mallocs - before.Mallocs
+}
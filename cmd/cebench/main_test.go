@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-c", "gzip,zstd", "-n", "1", "../../testdata"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"gzip", "zstd", "ratio"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output should contain %s but got\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "br") {
+		t.Errorf("br should not be benchmarked\n%s", got)
+	}
+}
+
+func TestRun_InvalidArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-n", "0", "."},
+		{"does-not-exist"},
+	} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v should be error", args)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Command cecodec encodes or decodes a payload with a chain of content codings.
+//
+// Usage:
+//
+//	cecodec -e "gzip,zstd" < in > out
+//	cecodec -d "gzip,zstd" -o out in
+//	cecodec -train -dict-id 1 -o dict.zstd samples...
+//
+// The chain has the same form as the Content-Encoding header, so the first coding is applied first when encoding
+// and last when decoding. br, gzip, x-gzip, zstd and identity are supported.
+//
+// With -train, it builds a zstd dictionary from the sample files instead,
+// which can be loaded by contentencoding.NewFSDictionaryStore.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "cecodec:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("cecodec", flag.ContinueOnError)
+	encode := fs.String("e", "", "encode with the comma-separated `codings`")
+	decode := fs.String("d", "", "decode the comma-separated `codings`")
+	output := fs.String("o", "", "write to `file` instead of stdout")
+	train := fs.Bool("train", false, "build a zstd dictionary from the sample files")
+	dictID := fs.Uint("dict-id", 0, "`id` of the trained dictionary, random if 0")
+	dictSize := fs.Int("dict-size", 0, "max `size` of the trained dictionary, 112KB if 0")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cecodec (-e | -d) codings [-o file] [file]")
+		fmt.Fprintln(fs.Output(), "       cecodec -train [-dict-id id] [-dict-size size] [-o file] samples...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *train {
+		return trainDictionary(fs.Args(), uint32(*dictID), *dictSize, *output, stdout)
+	}
+	if (*encode == "") == (*decode == "") {
+		fs.Usage()
+		return fmt.Errorf("exactly one of -e or -d is required")
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("too many arguments")
+	}
+
+	in := stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *decode != "" {
+		r, err := contentencoding.NewReader(in, *decode)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(out, r)
+		return err
+	}
+	w, err := contentencoding.NewWriter(out, *encode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func trainDictionary(files []string, id uint32, size int, output string, stdout io.Writer) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no sample files")
+	}
+	samples := make([][]byte, 0, len(files))
+	for _, name := range files {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		samples = append(samples, b)
+	}
+	dict, err := contentencoding.TrainDictionary(samples, id, size)
+	if err != nil {
+		return err
+	}
+	if output != "" {
+		return os.WriteFile(output, dict, 0o644)
+	}
+	_, err = stdout.Write(dict)
+	return err
+}
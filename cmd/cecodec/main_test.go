@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := run([]string{"-e", "gzip,zstd"}, strings.NewReader("test"), &encoded); err != nil {
+		t.Fatal(err)
+	}
+	var decoded bytes.Buffer
+	if err := run([]string{"-d", "gzip,zstd"}, &encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.String() != "test" {
+		t.Errorf("should be test but got='%s'", decoded.String())
+	}
+}
+
+func TestRun_InvalidArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-e", "gzip", "-d", "gzip"},
+		{"-e", "compress"},
+	} {
+		if err := run(args, strings.NewReader("test"), &bytes.Buffer{}); err == nil {
+			t.Errorf("%v should be error", args)
+		}
+	}
+}
+
+func TestRun_Train(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 200; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("sample%d.json", i))
+		sample := fmt.Sprintf(`{"id":%d,"user":"user-%d","event":"request","status":"ok"}`, i, i*7)
+		if err := os.WriteFile(name, []byte(sample), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	var out bytes.Buffer
+	if err := run(append([]string{"-train", "-dict-id", "7", "-dict-size", "4096"}, files...), nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	b := out.Bytes()
+	if len(b) < 8 || binary.LittleEndian.Uint32(b[4:]) != 7 {
+		t.Errorf("should be a zstd dictionary with id 7 but got % x", b[:8])
+	}
+	if err := run([]string{"-train"}, nil, &out); err == nil {
+		t.Error("-train without samples should be error")
+	}
+}
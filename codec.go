@@ -0,0 +1,136 @@
+package contentencoding
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// NewReader returns a reader that decodes r encoded with contentEncoding,
+// which is a comma-separated list of codings in the same form as the Content-Encoding header.
+// Only built-in codings and identity are supported, opts other than WithDOptions are ignored.
+// Closing the returned reader releases the decoders but does not close r.
+func NewReader(r io.Reader, contentEncoding string, opts ...Option) (io.ReadCloser, error) {
+	return newConfig(opts).newReader(r, contentEncoding)
+}
+
+func (cfg *config) newReader(r io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	var body io.ReadCloser = io.NopCloser(r)
+	values, err := contentCodings(contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(values) - 1; i >= 0; i-- {
+		v := values[i]
+		if v == "identity" {
+			continue
+		}
+		rc, ok, err := cfg.builtinReader(v, body)
+		if !ok {
+			err = fmt.Errorf("contentencoding: unsupported coding %q", v)
+		}
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		body = &layeredBody{ReadCloser: rc, under: body}
+	}
+	return body, nil
+}
+
+// NewWriter returns a writer that encodes to w with contentEncoding,
+// which is a comma-separated list of codings in the same form as the Content-Encoding header,
+// so the first coding is applied first.
+// Only built-in codings and identity are supported.
+// Close must be called to flush the encoders, it does not close w.
+func NewWriter(w io.Writer, contentEncoding string) (io.WriteCloser, error) {
+	return newConfig(nil).newWriter(w, contentEncoding)
+}
+
+func (cfg *config) newWriter(w io.Writer, contentEncoding string) (io.WriteCloser, error) {
+	return cfg.encoderPool.encoder(context.Background(), func() (io.WriteCloser, error) {
+		return cfg.newChainWriter(w, contentEncoding)
+	})
+}
+
+func (cfg *config) newChainWriter(w io.Writer, contentEncoding string) (io.WriteCloser, error) {
+	values, err := contentCodings(contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	var writers chainWriter
+	cur := w
+	for i := len(values) - 1; i >= 0; i-- {
+		v := values[i]
+		if v == "identity" {
+			continue
+		}
+		wc, err := cfg.newEncoder(v, cur, false, -1)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(chainWriter{wc}, writers...)
+		cur = wc
+	}
+	if len(writers) == 0 {
+		return nopWriteCloser{w}, nil
+	}
+	return writers, nil
+}
+
+// chainWriter is a stack of encoders, the first one receives writes and writes into the next one.
+type chainWriter []io.WriteCloser
+
+func (c chainWriter) Write(p []byte) (int, error) {
+	return c[0].Write(p)
+}
+
+func (c chainWriter) Close() error {
+	var err error
+	for _, w := range c {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// DecodeBody returns body decoded by contentEncoding, the value of Content-Encoding, as the middleware decodes request bodies,
+// for webhook receivers and frameworks that provide the header and the body without *http.Request.
+// Options for decoding such as WithDecoder, WithEncodings and WithLimits are applied,
+// MaxLayers is checked immediately and the other limits are reported by reads of the returned reader.
+// Unlike the middleware, a coding without decoder is UnsupportedEncodingError even without WithStrict.
+// Closing the returned reader closes body if it is an io.Closer.
+func DecodeBody(contentEncoding string, body io.Reader, opts ...Option) (io.ReadCloser, error) {
+	return newConfig(opts).decodeBody(contentEncoding, body)
+}
+
+func (c *config) decodeBody(contentEncoding string, body io.Reader) (io.ReadCloser, error) {
+	cfg := *c
+	cfg.strict = true
+	var decodeErr error
+	cfg.errHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		decodeErr = err
+	}
+	rc, ok := body.(io.ReadCloser)
+	if !ok {
+		rc = io.NopCloser(body)
+	}
+	encoded := &countingBody{ReadCloser: rc}
+	r := &http.Request{
+		Method: http.MethodPost,
+		Header: http.Header{"Content-Encoding": {contentEncoding}},
+		Body:   encoded,
+	}
+	if _, ok := cfg.decodeRequest(discardWriter{}, r); !ok {
+		return nil, decodeErr
+	}
+	return cfg.limits.limitBody(r.Body, func() int64 { return encoded.n }), nil
+}
@@ -0,0 +1,124 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestNewWriter_NewReader(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentEncoding string
+		encodings       []string
+	}{
+		{"identity", "identity", nil},
+		{"empty", "", nil},
+		{"gzip", "gzip", []string{"gzip"}},
+		{"gzip+zstd", "gzip, zstd", []string{"gzip", "zstd"}},
+		{"br+identity+gzip", "br,identity,gzip", []string{"br", "gzip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := contentencoding.NewWriter(&buf, tt.contentEncoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte("test")); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// the output must be compatible with other implementations.
+			b, err := contentencodingtest.DecompressBody(buf.Bytes(), tt.encodings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "test" {
+				t.Errorf("should be test but got='%s'", b)
+			}
+
+			r, err := contentencoding.NewReader(bytes.NewReader(buf.Bytes()), tt.contentEncoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			b, err = ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "test" {
+				t.Errorf("should be test but got='%s'", b)
+			}
+		})
+	}
+}
+
+func TestNewWriter_NewReader_Unsupported(t *testing.T) {
+	if _, err := contentencoding.NewWriter(ioutil.Discard, "gzip, compress"); err == nil {
+		t.Error("NewWriter should be error")
+	}
+	if _, err := contentencoding.NewReader(bytes.NewReader(nil), "compress"); err == nil {
+		t.Error("NewReader should be error")
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("decode body test "), 1000)
+	custom := &contentencoding.Decoder{
+		Encoding: "custom",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	}
+	tests := []struct {
+		name     string
+		encoding string
+		codings  []string
+		opts     []contentencoding.Option
+		wantErr  interface{}
+	}{
+		{"gzip", "gzip", []string{"gzip"}, nil, nil},
+		{"chain", "br, zstd", []string{"br", "zstd"}, nil, nil},
+		{"custom", "gzip, custom", []string{"gzip"}, []contentencoding.Option{contentencoding.WithDecoder(custom)}, nil},
+		{"unsupported", "deflate", nil, nil, new(*contentencoding.UnsupportedEncodingError)},
+		{"disabled", "br", []string{"br"}, []contentencoding.Option{contentencoding.WithEncodings("gzip")}, new(*contentencoding.UnsupportedEncodingError)},
+		{"too large", "gzip", []string{"gzip"}, []contentencoding.Option{contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 100})}, new(*contentencoding.LimitError)},
+		{"too many layers", "gzip, gzip", []string{"gzip", "gzip"}, []contentencoding.Option{contentencoding.WithLimits(contentencoding.Limits{MaxLayers: 1})}, new(*contentencoding.LimitError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := contentencodingtest.CompressBody(payload, tt.codings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := contentencoding.DecodeBody(tt.encoding, bytes.NewReader(encoded), tt.opts...)
+			var b []byte
+			if err == nil {
+				defer body.Close()
+				b, err = ioutil.ReadAll(body)
+			}
+			if tt.wantErr != nil {
+				if !errors.As(err, tt.wantErr) {
+					t.Fatalf("error should be %T but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, payload) {
+				t.Error("decoded body should be the payload")
+			}
+		})
+	}
+}
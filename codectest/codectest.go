@@ -0,0 +1,223 @@
+// Package codectest provides a compliance test suite for custom decoders of go-content-encoding.
+package codectest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+// Codec is a custom coding under test.
+type Codec struct {
+	// Decoder is passed to contentencoding.WithDecoder.
+	Decoder *contentencoding.Decoder
+	// NewWriter returns a writer that encodes to w with the coding, it is used to produce inputs.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	// Options are additional options passed to contentencoding.Decode.
+	Options []contentencoding.Option
+	// Timeout is the time limit of each request, 10 seconds if zero.
+	Timeout time.Duration
+}
+
+// Run runs the suite against codec.
+// It checks that the decoder round-trips various payloads alone and chained with built-in codings,
+// handles empty bodies, respects Limits, and reports truncated and corrupt input as an error instead of panicking,
+// hanging or silently returning wrong data.
+func Run(t *testing.T, codec Codec) {
+	t.Helper()
+	if codec.Decoder == nil || codec.NewWriter == nil {
+		t.Fatal("codectest: Decoder and NewWriter are required")
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for name, payload := range payloads() {
+			t.Run(name, func(t *testing.T) {
+				got, err := decode(t, codec, encode(t, codec, payload), codec.Decoder.Encoding)
+				if err != nil {
+					t.Fatalf("should not be error but got %v", err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Errorf("decoded body differs from payload, len=%d, want=%d", len(got), len(payload))
+				}
+			})
+		}
+	})
+
+	t.Run("Chain", func(t *testing.T) {
+		payload := payloads()["text"]
+		for _, builtin := range []string{"br", "gzip", "zstd"} {
+			t.Run(codec.Decoder.Encoding+"+"+builtin, func(t *testing.T) {
+				b, err := contentencodingtest.CompressBody(encode(t, codec, payload), builtin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := decode(t, codec, b, codec.Decoder.Encoding+", "+builtin)
+				if err != nil {
+					t.Fatalf("should not be error but got %v", err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Error("decoded body differs from payload")
+				}
+			})
+			t.Run(builtin+"+"+codec.Decoder.Encoding, func(t *testing.T) {
+				b, err := contentencodingtest.CompressBody(payload, builtin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := decode(t, codec, encode(t, codec, b), builtin+", "+codec.Decoder.Encoding)
+				if err != nil {
+					t.Fatalf("should not be error but got %v", err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Error("decoded body differs from payload")
+				}
+			})
+		}
+	})
+
+	t.Run("EmptyBody", func(t *testing.T) {
+		// an empty body is not a valid encoding for most codings, but it must not panic or hang.
+		got, err := decode(t, codec, nil, codec.Decoder.Encoding)
+		if err == nil && len(got) != 0 {
+			t.Errorf("empty body should be decoded to empty but got %d bytes", len(got))
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		payload := payloads()["random"]
+		b := encode(t, codec, payload)
+		for _, n := range []int{len(b) / 2, len(b) - 1} {
+			t.Run(fmt.Sprint(n), func(t *testing.T) {
+				got, err := decode(t, codec, b[:n], codec.Decoder.Encoding)
+				if err == nil && !bytes.Equal(got, payload) {
+					t.Errorf("truncated input should be reported as error, decoded %d bytes silently", len(got))
+				}
+			})
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		payload := payloads()["text"]
+		b := encode(t, codec, payload)
+		max := int64(len(payload) / 2)
+		got, err := decode(t, codec, b, codec.Decoder.Encoding, contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: max}))
+		var limitErr *contentencoding.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("should be LimitError but got %v", err)
+		}
+		if int64(len(got)) > max {
+			t.Errorf("decoded %d bytes over the limit %d", len(got), max)
+		}
+
+		_, err = decode(t, codec, b, "gzip, "+codec.Decoder.Encoding, contentencoding.WithLimits(contentencoding.Limits{MaxLayers: 1}))
+		if !errors.As(err, &limitErr) {
+			t.Errorf("should be LimitError for MaxLayers but got %v", err)
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		b := encode(t, codec, payloads()["text"])
+		corrupt := append([]byte(nil), b...)
+		for i := len(corrupt) / 3; i < len(corrupt)*2/3; i++ {
+			corrupt[i] ^= 0xff
+		}
+		// a coding without checksums cannot always detect corruption, it only must not panic or hang.
+		decode(t, codec, corrupt, codec.Decoder.Encoding)
+	})
+}
+
+func payloads() map[string][]byte {
+	random := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+	return map[string][]byte{
+		"empty":  {},
+		"byte":   {0},
+		"text":   bytes.Repeat([]byte("go-content-encoding "), 4096),
+		"random": random,
+	}
+}
+
+func encode(t *testing.T, codec Codec, payload []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serve sends body with Content-Encoding through contentencoding.Decode configured with codecs
+// and returns the body read by the handler or the error reported by the middleware or the read.
+func serve(body []byte, encoding string, extra []contentencoding.Option, codecs ...Codec) (decoded []byte, err error) {
+	opts := append([]contentencoding.Option{}, extra...)
+	var decoders []*contentencoding.Decoder
+	for _, c := range codecs {
+		opts = append(opts, c.Options...)
+		decoders = append(decoders, c.Decoder)
+	}
+	opts = append(opts,
+		contentencoding.WithDecoder(decoders...),
+		contentencoding.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+			err = e
+		}),
+	)
+	h := contentencoding.Decode(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decoded, err = ioutil.ReadAll(r.Body)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", encoding)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return decoded, err
+}
+
+// decode sends body with Content-Encoding through contentencoding.Decode
+// and returns the body read by the handler or the error reported by the middleware or the read.
+func decode(t *testing.T, codec Codec, body []byte, encoding string, extra ...contentencoding.Option) ([]byte, error) {
+	t.Helper()
+	type result struct {
+		body  []byte
+		err   error
+		panic interface{}
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		defer func() {
+			res.panic = recover()
+			done <- res
+		}()
+		res.body, res.err = serve(body, encoding, extra, codec)
+	}()
+
+	timeout := codec.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	select {
+	case res := <-done:
+		if res.panic != nil {
+			t.Fatalf("decoder panicked: %v", res.panic)
+		}
+		return res.body, res.err
+	case <-time.After(timeout):
+		t.Fatalf("decoding did not finish in %v", timeout)
+		return nil, nil
+	}
+}
@@ -0,0 +1,27 @@
+package codectest_test
+
+import (
+	"compress/zlib"
+	"io"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/codectest"
+)
+
+// deflate is "deflate" coding of RFC 9110, which is the zlib format.
+var deflate = codectest.Codec{
+	Decoder: &contentencoding.Decoder{
+		Encoding: "deflate",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	},
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriter(w), nil
+	},
+}
+
+func TestRun(t *testing.T) {
+	codectest.Run(t, deflate)
+}
@@ -0,0 +1,88 @@
+package codectest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+var builtins = []string{"br", "gzip", "zstd"}
+
+// Chains returns the chains of up to depth distinct codings of the built-in codings and codecs, in all permutations.
+func Chains(depth int, codecs ...Codec) [][]string {
+	codings := append([]string{}, builtins...)
+	for _, c := range codecs {
+		codings = append(codings, c.Decoder.Encoding)
+	}
+	var chains [][]string
+	var permute func(chain []string, used map[string]bool)
+	permute = func(chain []string, used map[string]bool) {
+		if len(chain) > 0 {
+			chains = append(chains, append([]string(nil), chain...))
+		}
+		if len(chain) == depth {
+			return
+		}
+		for _, c := range codings {
+			if used[c] {
+				continue
+			}
+			used[c] = true
+			permute(append(chain, c), used)
+			used[c] = false
+		}
+	}
+	permute(nil, make(map[string]bool))
+	return chains
+}
+
+// RoundTrip encodes b with chain, the first coding is applied first,
+// and decodes it with contentencoding.Decode configured with codecs.
+// Codings of chain other than the encodings of codecs are encoded by contentencoding.NewWriter.
+func RoundTrip(b []byte, chain []string, codecs ...Codec) ([]byte, error) {
+	for _, coding := range chain {
+		var buf bytes.Buffer
+		w, err := newWriter(&buf, coding, codecs)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+	return serve(b, strings.Join(chain, ", "), nil, codecs...)
+}
+
+func newWriter(w io.Writer, coding string, codecs []Codec) (io.WriteCloser, error) {
+	for _, c := range codecs {
+		if c.Decoder.Encoding == coding {
+			return c.NewWriter(w)
+		}
+	}
+	return contentencoding.NewWriter(w, coding)
+}
+
+// Property returns a property for testing/quick.Check and similar tools,
+// which reports whether b round-trips on every chain of Chains(depth, codecs...).
+//
+//	if err := quick.Check(codectest.Property(2, codec), nil); err != nil {
+//		t.Error(err)
+//	}
+func Property(depth int, codecs ...Codec) func(b []byte) bool {
+	chains := Chains(depth, codecs...)
+	return func(b []byte) bool {
+		for _, chain := range chains {
+			got, err := RoundTrip(b, chain, codecs...)
+			if err != nil || !bytes.Equal(got, b) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,37 @@
+package codectest_test
+
+import (
+	"testing"
+	"testing/quick"
+
+	"github.com/johejo/go-content-encoding/codectest"
+)
+
+func TestChains(t *testing.T) {
+	chains := codectest.Chains(2, deflate)
+	// 4 single codings and 4*3 pairs.
+	if len(chains) != 16 {
+		t.Errorf("should be 16 chains but got %d: %v", len(chains), chains)
+	}
+	for _, chain := range chains {
+		if len(chain) == 2 && chain[0] == chain[1] {
+			t.Errorf("chain should not repeat a coding: %v", chain)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	got, err := codectest.RoundTrip([]byte("test"), []string{"deflate", "gzip", "zstd"}, deflate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "test" {
+		t.Errorf("should be test but got='%s'", got)
+	}
+}
+
+func TestProperty(t *testing.T) {
+	if err := quick.Check(codectest.Property(2, deflate), &quick.Config{MaxCount: 10}); err != nil {
+		t.Error(err)
+	}
+}
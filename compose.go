@@ -0,0 +1,20 @@
+package contentencoding
+
+import "net/http"
+
+// ServeHTTP decodes the request body and calls next, so that m is a negroni.Handler:
+//
+//	n := negroni.New()
+//	n.Use(contentencoding.New())
+func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	m.Handler(next).ServeHTTP(w, r)
+}
+
+// Apply returns h wrapped by mws, the first one is the outermost as alice.New(mws...).Then(h) does,
+// e.g. Apply(h, Decode(), logging) decodes requests before logging them.
+func Apply(h http.Handler, mws ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
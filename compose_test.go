@@ -0,0 +1,53 @@
+package contentencoding_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+// negroniHandler is negroni.Handler.
+type negroniHandler interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+func TestMiddleware_ServeHTTP(t *testing.T) {
+	var h negroniHandler = contentencoding.New()
+	rec := httptest.NewRecorder()
+	req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "br")
+	h.ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "test" {
+			t.Errorf("should be test but got='%s'", b)
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name+":"+r.Header.Get("Content-Encoding"))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := contentencoding.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mw("outer"), contentencoding.Transcode("gzip"), mw("inner"))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "zstd"))
+
+	want := "outer:zstd,inner:gzip,handler"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order should be %s but got %s", want, got)
+	}
+}
@@ -0,0 +1,268 @@
+package contentencoding
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressWriter is a http.ResponseWriter that encodes successful responses with encoding,
+// or only adds Vary if encoding is identity.
+// gRPC and gRPC-web responses are never encoded, see isGRPC.
+// The handler must be called with req, which accepts SetSizeHint and SetCompressionHint,
+// and Close must be called after the handler returns to finish the encoding.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      *config
+	req      *http.Request
+	encoding string
+	head     bool
+	hints    *responseHints
+
+	wroteHeader bool
+	sniffing    bool
+	active      bool
+	size        int64
+	pending     *bytes.Buffer
+	buf         *bytes.Buffer
+	enc         io.WriteCloser
+	err         error
+	hijacked    bool
+}
+
+func (cfg *config) newCompressWriter(w http.ResponseWriter, r *http.Request, encoding string) *compressWriter {
+	r, hints := withResponseHints(r)
+	return &compressWriter{ResponseWriter: w, cfg: cfg, req: r, encoding: encoding, head: r.Method == http.MethodHead, hints: hints}
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	cw.cfg.varyAcceptEncoding(h)
+	eligible := status == http.StatusOK && cw.encoding != "identity" && cw.hints.compression != CompressNone &&
+		h.Get("Content-Encoding") == ""
+	if eligible && cw.cfg.hasContentTypes() && h.Get("Content-Type") == "" && !cw.head {
+		// the header is written when the type is sniffed from the first write.
+		cw.sniffing = true
+		return
+	}
+	// only complete representations are encoded, not ranges, errors or responses without content.
+	if eligible && !isGRPC(h.Get("Content-Type")) && (!cw.cfg.hasContentTypes() || cw.cfg.compressibleType(h.Get("Content-Type"))) {
+		if cw.hints.compression == CompressBest {
+			cw.cfg = cw.cfg.bestLevels()
+		}
+		cw.size = cw.hints.size
+		if cl, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil && cw.size < 0 {
+			cw.size = cl
+		}
+		if cw.size < 0 && cw.cfg.minSize > 0 && !cw.head {
+			// the coding is decided when minSize bytes are written or the handler returns.
+			cw.pending = new(bytes.Buffer)
+			return
+		}
+		if cw.size >= 0 && cw.size < int64(cw.cfg.minSize) {
+			cw.ResponseWriter.WriteHeader(status)
+			return
+		}
+		cw.active = true
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if cw.size >= 0 && cw.size <= bufferedResponseSize && !cw.head {
+			// the header is written with Content-Length when the buffered response is encoded.
+			cw.buf = bytes.NewBuffer(make([]byte, 0, cw.size))
+			return
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			// detect the type from the content before it is encoded.
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.sniffing {
+		cw.sniffing = false
+		if len(p) > 0 {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.wroteHeader = false
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.pending != nil {
+		if cw.pending.Len()+len(p) < cw.cfg.minSize {
+			return cw.pending.Write(p)
+		}
+		if err := cw.startPending(); err != nil {
+			return 0, err
+		}
+	}
+	if !cw.active {
+		return cw.ResponseWriter.Write(p)
+	}
+	if cw.buf != nil {
+		if cw.buf.Len()+len(p) <= bufferedResponseSize {
+			return cw.buf.Write(p)
+		}
+		if err := cw.stream(); err != nil {
+			return 0, err
+		}
+	}
+	if err := cw.init(); err != nil {
+		return 0, err
+	}
+	return cw.enc.Write(p)
+}
+
+func (cw *compressWriter) init() error {
+	if cw.enc == nil && cw.err == nil {
+		cw.enc, cw.err = cw.cfg.responseEncoder(cw.req, cw.encoding, cw.ResponseWriter, cw.size)
+	}
+	return cw.err
+}
+
+// startPending starts encoding the response held by WithMinSize, once it has reached the minimum size or is flushed.
+func (cw *compressWriter) startPending() error {
+	buf := cw.pending
+	cw.pending = nil
+	cw.active = true
+	cw.Header().Del("Content-Length")
+	cw.Header().Set("Content-Encoding", cw.encoding)
+	cw.ResponseWriter.WriteHeader(http.StatusOK)
+	if err := cw.init(); err != nil {
+		return err
+	}
+	_, err := cw.enc.Write(buf.Bytes())
+	return err
+}
+
+// stream gives up buffering, when the response turns out to be larger than hinted or is flushed,
+// and encodes the buffered bytes to the underlying writer.
+func (cw *compressWriter) stream() error {
+	buf := cw.buf
+	cw.buf = nil
+	cw.size = -1
+	cw.ResponseWriter.WriteHeader(http.StatusOK)
+	if err := cw.init(); err != nil {
+		return err
+	}
+	_, err := cw.enc.Write(buf.Bytes())
+	return err
+}
+
+// encodeBuffered encodes the buffered response at once and writes it with Content-Length.
+func (cw *compressWriter) encodeBuffered() error {
+	out, err := cw.cfg.encodeAll(cw.req, cw.encoding, cw.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	cw.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	cw.ResponseWriter.WriteHeader(http.StatusOK)
+	_, err = cw.ResponseWriter.Write(out)
+	return err
+}
+
+// Flush flushes the encoder and the underlying writer.
+func (cw *compressWriter) Flush() {
+	if cw.sniffing {
+		cw.sniffing = false
+		cw.ResponseWriter.WriteHeader(http.StatusOK)
+	}
+	if cw.pending != nil {
+		if err := cw.startPending(); err != nil {
+			return
+		}
+	}
+	if cw.buf != nil {
+		if err := cw.stream(); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		if f, ok := cw.enc.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection of the underlying writer, the response is no longer written by Close.
+// The encoding started by the handler is finished before the connection is taken over.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		cw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the encoding, an encoded response gets an empty encoded body if nothing has been written to it.
+// Nothing is written if the response is not encoded, e.g. when the handler has written neither header nor body,
+// which net/http then sends as an empty 200 response without Content-Encoding.
+func (cw *compressWriter) Close() error {
+	if cw.hijacked {
+		return nil
+	}
+	if !cw.wroteHeader {
+		// the header of a response without body is written after the handler returns.
+		cw.cfg.varyAcceptEncoding(cw.Header())
+	}
+	if cw.sniffing {
+		// nothing has been written to sniff.
+		cw.ResponseWriter.WriteHeader(http.StatusOK)
+		return nil
+	}
+	if cw.pending != nil {
+		// the response is smaller than WithMinSize.
+		cw.Header().Set("Content-Length", strconv.Itoa(cw.pending.Len()))
+		cw.ResponseWriter.WriteHeader(http.StatusOK)
+		_, err := cw.ResponseWriter.Write(cw.pending.Bytes())
+		return err
+	}
+	if !cw.active {
+		return nil
+	}
+	if cw.buf != nil {
+		return cw.encodeBuffered()
+	}
+	if cw.enc == nil && cw.head {
+		return nil
+	}
+	if err := cw.init(); err != nil {
+		return err
+	}
+	return cw.enc.Close()
+}
+
+// isGRPC reports whether ctype is the content type of gRPC or gRPC-web, e.g. application/grpc-web-text+proto.
+// Their messages are framed and compressed by grpc-encoding, and the final frame of gRPC-web carries the trailers,
+// so encoding the whole stream breaks clients that parse frames as they arrive.
+func isGRPC(ctype string) bool {
+	ctype = strings.ToLower(strings.TrimSpace(ctype))
+	return ctype == "application/grpc" || strings.HasPrefix(ctype, "application/grpc+") ||
+		strings.HasPrefix(ctype, "application/grpc-web") || strings.HasPrefix(ctype, "application/grpc;")
+}
@@ -4,7 +4,9 @@
 package contentencoding
 
 import (
-	"io/ioutil"
+	"bytes"
+	"errors"
+	"io"
 	"net/http"
 	"strings"
 
@@ -15,95 +17,335 @@ import (
 
 // Decode returns net/http compatible middleware that automatically decodes body detected by Content-Encoding.
 // By default, br(brotli), gzip and zstd(zstandard) are supported.
+// Codings are removed strictly from right to left, the last applied one first,
+// and each decoder reads from the output of the previous one as the handler reads the body.
+// Closing the decoded body closes the decoders from the last one created, which reads the others,
+// down to the original body.
+// Reads of the decoded body return the error of the request context as soon as it is done,
+// e.g. when the client disconnects, instead of waiting for the rest of the encoded data.
+// An empty Content-Encoding and identity codings leave the body as it is,
+// and empty list elements such as of a trailing comma are ignored unless WithRejectEmptyElements is used.
+// Multiple Content-Encoding field lines are combined into one before decoding unless WithRejectFieldLines is used,
+// so that the handler sees the same codings as decoded.
 func Decode(opts ...Option) func(next http.Handler) http.Handler {
-	cfg := new(config)
-	for _, opt := range append(defaults(), opts...) {
-		opt(cfg)
+	return newMiddleware(newConfig(opts)).Handler
+}
+
+// skipMethod reports whether the body of r is not decoded by its method.
+func (cfg *config) skipMethod(r *http.Request) bool {
+	if cfg.methods != nil {
+		return !cfg.methods[r.Method]
+	}
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// WithMethods returns a Option to decode only requests with the methods, it also applies to Transcode.
+// By default, requests of all methods except GET and HEAD are decoded, including DELETE and OPTIONS.
+// Methods are case-sensitive as in net/http.
+func WithMethods(methods ...string) Option {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return func(cfg *config) {
+		cfg.methods = set
 	}
+}
+
+// ErrEmptyElement is reported to the error handler for a Content-Encoding with empty list elements,
+// e.g. "gzip," or "gzip,,br", when WithRejectEmptyElements is used.
+var ErrEmptyElement = errors.New("contentencoding: empty element in Content-Encoding")
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodGet || r.Method == http.MethodHead {
-				next.ServeHTTP(w, r)
-				return
+// WithRejectEmptyElements returns a Option to handle a Content-Encoding with empty list elements as malformed,
+// since an intermediary that emits them may have dropped a coding of the chain.
+// An empty Content-Encoding is still accepted as no coding.
+func WithRejectEmptyElements() Option {
+	return func(cfg *config) {
+		cfg.rejectEmpty = true
+	}
+}
+
+// hasEmptyElement reports whether the non-empty list s has empty elements.
+func hasEmptyElement(s string) bool {
+	if strings.TrimSpace(s) == "" {
+		return false
+	}
+	for _, elem := range splitQuoted(s, ',') {
+		if strings.TrimSpace(elem) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrFieldLines is reported to the error handler for a Content-Encoding with CR or LF that is not obs-fold,
+// and for multiple Content-Encoding field lines or obs-fold when WithRejectFieldLines is used.
+var ErrFieldLines = errors.New("contentencoding: ambiguous Content-Encoding field lines")
+
+// WithRejectFieldLines returns a Option to handle multiple Content-Encoding field lines and obs-fold as malformed,
+// instead of combining them as RFC 9110 and RFC 9112 define, for deployments behind intermediaries
+// that might interpret them differently, e.g. by forwarding only the first line.
+func WithRejectFieldLines() Option {
+	return func(cfg *config) {
+		cfg.rejectFieldLines = true
+	}
+}
+
+// contentEncoding returns the Content-Encoding of h as one field value, see combineFieldLines.
+func (cfg *config) contentEncoding(h http.Header) (string, error) {
+	return combineFieldLines(h["Content-Encoding"], cfg.rejectFieldLines)
+}
+
+// WithEncodings returns a Option to decode only the built-in codings in encodings, e.g. to turn off br.
+// Disabled codings are handled as codings without decoder, see WithStrict.
+// Decoders given by WithDecoder are not affected.
+// By default, br, gzip and zstd are enabled.
+func WithEncodings(encodings ...string) Option {
+	set := make(map[string]bool, len(encodings))
+	for _, e := range encodings {
+		set[CanonicalCoding(e)] = true
+	}
+	return func(cfg *config) {
+		cfg.encodings = set
+	}
+}
+
+// builtinEnabled reports whether coding is a built-in coding enabled to decode.
+func (cfg *config) builtinEnabled(coding string) bool {
+	if !isBuiltin(coding) {
+		return false
+	}
+	return cfg.encodings == nil || cfg.encodings[coding]
+}
+
+// decodeRequest replaces r.Body with the body decoded by Content-Encoding.
+// It returns the codings that have no decoder, and false if the error handler has been called.
+func (cfg *config) decodeRequest(w http.ResponseWriter, r *http.Request) (undecoded []string, ok bool) {
+	raw := r.Header.Get("Content-Encoding")
+	values, err := contentCodings(raw)
+	if err == nil && cfg.rejectEmpty && hasEmptyElement(raw) {
+		err = ErrEmptyElement
+	}
+	if err == nil {
+		err = cfg.limits.checkLayers(values)
+	}
+	if err == nil && (cfg.strict || cfg.disabledStatus != 0) {
+		err = cfg.checkSupported(w, values)
+	}
+	if err == nil {
+		err = cfg.checkMinimum(values, r.ContentLength)
+	}
+	if err != nil {
+		cfg.handleError(w, r, err)
+		return nil, false
+	}
+	// writes of Decoder.Handler are held until all codings are decoded.
+	var guards []*guardWriter
+	for i := len(values) - 1; i >= 0; i-- {
+		v := values[i]
+		switch {
+		case v == "identity":
+		case cfg.builtinEnabled(v):
+			var body io.ReadCloser
+			var err error
+			if cfg.decodeAll(r, values) {
+				body = &zstdAllReader{cfg: cfg, src: r.Body}
+			} else {
+				body, _, err = cfg.builtinReader(v, r.Body)
 			}
-			values := splitEncodingHeader(r.Header.Get("Content-Encoding"))
-			for i := len(values) - 1; i >= 0; i-- {
-				v := values[i]
-				switch v {
-				case "br":
-					decompressBrotli(r)
-				case "gzip", "x-gzip":
-					if err := decompressGzip(r); err != nil {
-						cfg.errHandler(w, r, err)
-						return
-					}
-				case "zstd":
-					if err := decompressZstd(r, cfg.dopts...); err != nil {
-						cfg.errHandler(w, r, err)
-						return
+			if err != nil {
+				cfg.handleError(w, r, err)
+				return nil, false
+			}
+			r.Body = &layeredBody{ReadCloser: body, under: r.Body}
+		default:
+			found := false
+			for _, decoder := range cfg.decoders {
+				if v == CanonicalCoding(decoder.Encoding) {
+					found = true
+					g, err := decoder.decode(r)
+					if err != nil {
+						cfg.handleError(w, r, err)
+						return nil, false
 					}
-				case "", "identity":
-				default:
-					for _, decoder := range cfg.decoders {
-						if v == decoder.Encoding {
-							if err := decoder.Handler(w, r); err != nil {
-								cfg.errHandler(w, r, err)
-								return
-							}
-						}
+					if g != nil {
+						guards = append(guards, g)
 					}
+					break
 				}
 			}
-			next.ServeHTTP(w, r)
-		})
+			if !found {
+				undecoded = append(undecoded, v)
+			}
+		}
+	}
+	for _, g := range guards {
+		g.flush(w)
+	}
+	if len(undecoded) > 0 && cfg.undecodedHook != nil {
+		cfg.undecodedHook(r, undecoded)
 	}
+	return undecoded, true
 }
 
-func decompressBrotli(r *http.Request) {
-	r.Body = ioutil.NopCloser(brotli.NewReader(r.Body))
+// builtinReader returns a reader that decodes r with the built-in decoder for encoding.
+// ok is false if encoding is not built-in.
+func (cfg *config) builtinReader(encoding string, r io.Reader) (rc io.ReadCloser, ok bool, err error) {
+	switch encoding {
+	case "br":
+		return io.NopCloser(brotli.NewReader(cfg.limits.limitBrotliWindow(r))), true, nil
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, true, err
+		}
+		return gr, true, nil
+	case "zstd":
+		rc, err := cfg.zstdReader(r)
+		if err != nil {
+			return nil, true, cfg.limits.memoryError(err)
+		}
+		return cfg.limits.limitMemory(rc), true, nil
+	}
+	return nil, false, nil
 }
 
-func decompressGzip(r *http.Request) error {
-	gr, err := gzip.NewReader(r.Body)
+// zstdReader returns a reader that decodes r with a dictionary, a pooled decoder or a new one.
+func (cfg *config) zstdReader(r io.Reader) (io.ReadCloser, error) {
+	if cfg.dictionaries != nil {
+		rc, next, err := cfg.zstdDictReader(r)
+		if rc != nil || err != nil {
+			return rc, err
+		}
+		r = next
+	}
+	if cfg.zstdPool != nil {
+		return cfg.zstdPool.get(r)
+	}
+	zr, err := zstd.NewReader(r, cfg.zstdOptions()...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	r.Body = gr
-	return nil
+	return zr.IOReadCloser(), nil
 }
 
-func decompressZstd(r *http.Request, opts ...zstd.DOption) error {
-	zr, err := zstd.NewReader(r.Body, opts...)
+// contentCodings returns the codings of the Content-Encoding value raw parsed by ParseContentEncoding.
+func contentCodings(raw string) ([]string, error) {
+	values, err := ParseContentEncoding(raw)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	r.Body = ioutil.NopCloser(zr)
-	return nil
-}
-
-var noSpace = strings.NewReplacer(" ", "")
-
-func splitEncodingHeader(raw string) []string {
-	if raw == "" {
-		return []string{}
+	codings := make([]string, len(values))
+	for i, v := range values {
+		codings[i] = v.Coding
 	}
-	return strings.Split(noSpace.Replace(raw), ",")
+	return codings, nil
 }
 
 // Option is option for Decode.
+// Options copy their arguments, so each middleware has its own configuration
+// that is not changed by modifying the arguments later or by other instances.
 type Option func(cfg *config)
 
 type config struct {
-	errHandler ErrorHandler
-	decoders   []*Decoder
+	errHandler  ErrorHandler
+	decoders    []*Decoder
+	passthrough bool
+	methods     map[string]bool
+	encodings   map[string]bool
+	rejectEmpty bool
+	levels      map[string]int
+
+	contentRange ContentRangePolicy
+	statsHook    StatsHook
+	drainMax     int64
+	maxReadAhead int
+	limits       Limits
+
+	progressEvery int64
+	progressHook  ProgressHook
+	cpuAccounting bool
+
+	advertise       bool
+	advertiseHeader string
+	strict          bool
+	disabledStatus  int
+	statusCodes     map[ErrorCategory]int
+	replayMax       int64
+	rawMax          int64
+	maxCompressed   int64
+	oversize        OversizePolicy
+
+	zstdWindow        int
+	zstdWindowCapable ClientCapability
+
+	variantCache   VariantCache
+	maxVariantSize int
+	responseFixup  ResponseFixup
+	preference     []string
+
+	capabilityOverride CapabilityOverride
+	resolver           ConfigResolver
 
-	dopts []zstd.DOption
+	digests        []DigestAlgorithm
+	spoolEncoding  string
+	spoolMaxMemory int64
+
+	dopts        []zstd.DOption
+	eopts        []zstd.EOption
+	zstdPool     *zstdPool
+	decodePolicy DecodePolicy
+	dictionaries DictionaryStore
+
+	undecodedHook UndecodedHook
+	encoderPool   *EncoderPool
+	faults        *Faults
+
+	assumeAccept  bool
+	assumedAccept []EncodingValue
+
+	annotate         bool
+	annotationHeader string
+	rejectFieldLines bool
+	newValidator     func(r *http.Request) io.WriteCloser
+	noVary           bool
+	aggregator       *StatsAggregator
+	minSize          int
+	compressTypes    []string
+	skipTypes        []string
+	rejectNested     bool
+	brotliWindow     int
+	zstdEncoders     *zstdEncoders
+	maxSessions      int
 }
 
 // DefaultErrorHandler is ErrorHandler that will used by default.
+// It responds the StatusCode of StatusError, 413 Request Entity Too Large for LimitError, the StatusCode of
+// UnsupportedEncodingError or 415 Unsupported Media Type if it is zero, and 400 Bad Request for the others.
 func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
-	http.Error(w, err.Error(), http.StatusBadRequest)
+	http.Error(w, err.Error(), errorStatus(err))
+}
+
+// errorStatus returns the status code of the response for err.
+func errorStatus(err error) int {
+	var (
+		statusErr      *StatusError
+		limitErr       *LimitError
+		unsupportedErr *UnsupportedEncodingError
+	)
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode
+	case errors.As(err, &limitErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &unsupportedErr):
+		if unsupportedErr.StatusCode != 0 {
+			return unsupportedErr.StatusCode
+		}
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusBadRequest
+	}
 }
 
 // ErrorHandler is a type used to customize error handling.
@@ -122,29 +364,112 @@ func WithErrorHandler(eh ErrorHandler) Option {
 // WithDOptions returns a Option to customize zstd decoder with zstd.DOptions.
 // See https://pkg.go.dev/github.com/klauspost/compress/zstd?tab=doc#DOption.
 func WithDOptions(dopts ...zstd.DOption) Option {
+	dopts = append([]zstd.DOption(nil), dopts...)
 	return func(cfg *config) {
 		cfg.dopts = dopts
 	}
 }
 
 // Decoder is custom decoder for user defined Content-Encoding.
-// If the Content-Encoding matches Encoding, Handler is called.
+// If the Content-Encoding matches Encoding, NewReader or Handler is called.
 type Decoder struct {
 	// Encoding is a string used for Content-Encoding matching.
 	Encoding string
+	// NewReader returns a reader that decodes r, which is the body decoded from the codings on the right.
+	// The reader is read lazily by the handler like the built-in decoders.
+	// If NewReader is set, Handler is not used.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
 	// Handler will be called when Encoding matches the Content-Encoding.
+	// It must replace r.Body with the decoded body, and should not read it eagerly.
+	// Writes to w are held and discarded if decoding fails, otherwise they are written before calling the next handler.
+	//
+	// Deprecated: Use NewReader, since the decoder should not write the response.
 	Handler func(w http.ResponseWriter, r *http.Request) error
 }
 
+// decode wraps r.Body with the decoder.
+// It returns the writer given to Handler if it is used.
+func (d *Decoder) decode(r *http.Request) (*guardWriter, error) {
+	if d.NewReader == nil {
+		g := &guardWriter{header: make(http.Header)}
+		under := r.Body
+		err := d.Handler(g, r)
+		if err == nil && r.Body != under && r.Body != nil {
+			// the replaced body may not close the body it reads.
+			r.Body = &layeredBody{ReadCloser: r.Body, under: under}
+		}
+		return g, err
+	}
+	rc, err := d.NewReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = &layeredBody{ReadCloser: rc, under: r.Body}
+	return nil, nil
+}
+
+// guardWriter holds the response written by Decoder.Handler.
+type guardWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (g *guardWriter) Header() http.Header {
+	return g.header
+}
+
+func (g *guardWriter) Write(b []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	return g.buf.Write(b)
+}
+
+func (g *guardWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+// flush writes the held response to w.
+func (g *guardWriter) flush(w http.ResponseWriter) {
+	for k, v := range g.header {
+		w.Header()[k] = v
+	}
+	if g.status != 0 {
+		w.WriteHeader(g.status)
+	}
+	if g.buf.Len() > 0 {
+		w.Write(g.buf.Bytes())
+	}
+}
+
 // WithDecoder returns a Option to use Decode with Decoder.
+// If some decoders have the same Encoding, only the first one is used for each coding.
 func WithDecoder(decoders ...*Decoder) Option {
+	copied := make([]*Decoder, len(decoders))
+	for i, d := range decoders {
+		d := *d
+		copied[i] = &d
+	}
 	return func(cfg *config) {
-		cfg.decoders = decoders
+		cfg.decoders = copied
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{zstdEncoders: new(zstdEncoders)}
+	for _, opt := range append(defaults(), opts...) {
+		opt(cfg)
 	}
+	return cfg
 }
 
 func defaults() []Option {
 	return []Option{
 		WithErrorHandler(nil),
+		WithResponseFixup(nil),
+		WithEncodingPreference(builtinEncodings...),
 	}
 }
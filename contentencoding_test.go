@@ -1,14 +1,19 @@
 package contentencoding_test
 
 import (
+	"bytes"
+	"encoding/base64"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
 )
 
 func TestDecode_compress(t *testing.T) {
@@ -21,6 +26,8 @@ func TestDecode_compress(t *testing.T) {
 		{"gzip", "gzip", "testdata/test.txt.gz"},
 		{"zstd", "zstd", "testdata/test.txt.zst"},
 		{"gzip+zstd", "gzip, zstd", "testdata/test.txt.gz.zst"},
+		{"uppercase", "GZIP", "testdata/test.txt.gz"},
+		{"mixed case with identity", "identity, Gzip, IDENTITY, zStd", "testdata/test.txt.gz.zst"},
 	}
 
 	for _, tt := range tests {
@@ -90,6 +97,209 @@ func TestDecode_WithDecoder(t *testing.T) {
 	}
 }
 
+func TestDecode_WithDecoder_NewReader(t *testing.T) {
+	base64Decoder := &contentencoding.Decoder{
+		Encoding: "base64",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+		},
+	}
+	b64 := func(b []byte) []byte {
+		return []byte(base64.StdEncoding.EncodeToString(b))
+	}
+	gz := func(b []byte) []byte {
+		b, err := contentencodingtest.CompressBody(b, "gzip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"custom", "base64", b64([]byte("test"))},
+		{"custom+gzip", "base64, gzip", gz(b64([]byte("test")))},
+		{"gzip+custom", "gzip, base64", b64(gz([]byte("test")))},
+		{"custom+gzip+custom", "base64, gzip, base64", b64(gz(b64([]byte("test"))))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &countingReader{r: bytes.NewReader(tt.body)}
+			h := contentencoding.Decode(contentencoding.WithDecoder(base64Decoder))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding == "base64" && body.n != 0 {
+					t.Errorf("body should be read lazily but %d bytes are read", body.n)
+				}
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "test" {
+					t.Errorf("should be test but got='%s'", b)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", body)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("should be 200 but got %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestDecode_WithDecoder_FirstMatchWins(t *testing.T) {
+	var called []string
+	newDecoder := func(name string) *contentencoding.Decoder {
+		return &contentencoding.Decoder{
+			Encoding: "custom",
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				called = append(called, name)
+				return ioutil.NopCloser(r), nil
+			},
+		}
+	}
+	h := contentencoding.Decode(contentencoding.WithDecoder(newDecoder("first"), newDecoder("second")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", "custom, custom")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want := []string{"first", "first"}; !reflect.DeepEqual(called, want) {
+		t.Errorf("should be %v but got %v", want, called)
+	}
+}
+
+func TestDecode_WithDecoder_HandlerWrites(t *testing.T) {
+	writingDecoder := &contentencoding.Decoder{
+		Encoding: "custom",
+		Handler: func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("X-Custom", "decoded")
+			w.Write([]byte("partial"))
+			return nil
+		},
+	}
+	h := contentencoding.Decode(contentencoding.WithDecoder(writingDecoder))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("discarded on error", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test")) // not compressed
+		req.Header.Set("Content-Encoding", "gzip, custom")
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("should be 400 but got %d", rec.Code)
+		}
+		if rec.Header().Get("X-Custom") != "" || strings.Contains(rec.Body.String(), "partial") {
+			t.Errorf("writes of the decoder should be discarded: %v %s", rec.Header(), rec.Body)
+		}
+	})
+
+	t.Run("written on success", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+		req.Header.Set("Content-Encoding", "custom")
+		h.ServeHTTP(rec, req)
+		if rec.Header().Get("X-Custom") != "decoded" || rec.Body.String() != "partial" {
+			t.Errorf("writes of the decoder should be written: %v %s", rec.Header(), rec.Body)
+		}
+	})
+}
+
+func TestDecode_Isolation(t *testing.T) {
+	var calls int
+	decoders := []*contentencoding.Decoder{{
+		Encoding: "custom",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			calls++
+			return ioutil.NopCloser(r), nil
+		},
+	}}
+	withDecoder := contentencoding.Decode(contentencoding.WithDecoder(decoders...))
+	withoutDecoder := contentencoding.Decode()
+
+	// modifying the arguments must not change the configured middleware.
+	decoders[0].Encoding = "changed"
+	decoders[0] = &contentencoding.Decoder{Encoding: "other"}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(h http.Handler) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+		req.Header.Set("Content-Encoding", "custom")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve(withDecoder(handler))
+	if calls != 1 {
+		t.Errorf("decoder should be called once but got %d", calls)
+	}
+	serve(withoutDecoder(handler))
+	if calls != 1 {
+		t.Errorf("decoder should not be called by other instances but got %d", calls)
+	}
+}
+
+func TestDecode_Methods(t *testing.T) {
+	methods := []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+	}
+	tests := []struct {
+		name    string
+		opts    []contentencoding.Option
+		decoded map[string]bool
+	}{
+		{
+			"default",
+			nil,
+			map[string]bool{
+				http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true, http.MethodDelete: true,
+				http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+			},
+		},
+		{
+			"WithMethods",
+			[]contentencoding.Option{contentencoding.WithMethods(http.MethodGet, http.MethodPost)},
+			map[string]bool{http.MethodGet: true, http.MethodPost: true},
+		},
+		{
+			"WithMethods empty",
+			[]contentencoding.Option{contentencoding.WithMethods()},
+			map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, method := range methods {
+				h := contentencoding.Decode(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					b, err := ioutil.ReadAll(r.Body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if decoded := string(b) == "test"; decoded != tt.decoded[method] {
+						t.Errorf("%s: decoded should be %v but got %v", method, tt.decoded[method], decoded)
+					}
+				}))
+				req := contentencodingtest.NewCompressedRequest(method, "/", []byte("test"), "gzip")
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
 func TestDecode_WithErrorHandler(t *testing.T) {
 	mux := http.NewServeMux()
 	errHandler := contentencoding.ErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
@@ -106,3 +316,103 @@ func TestDecode_WithErrorHandler(t *testing.T) {
 		t.Errorf("invalid Accept-Encoding, %v", result)
 	}
 }
+
+func TestDecode_MalformedContentEncoding(t *testing.T) {
+	h := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test"))
+	req.Header.Set("Content-Encoding", "gzip;level=1")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("should be 400 but got %d", rec.Code)
+	}
+}
+
+func TestDecode_EmptyElements(t *testing.T) {
+	gz, err := ioutil.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		opts     []contentencoding.Option
+		encoding string
+		body     []byte
+		want     int
+	}{
+		{"empty", nil, "", []byte("test\n"), http.StatusOK},
+		{"identity", nil, "identity", []byte("test\n"), http.StatusOK},
+		{"trailing comma", nil, "gzip,", gz, http.StatusOK},
+		{"empty with reject", []contentencoding.Option{contentencoding.WithRejectEmptyElements()}, "", []byte("test\n"), http.StatusOK},
+		{"trailing comma with reject", []contentencoding.Option{contentencoding.WithRejectEmptyElements()}, "gzip,", gz, http.StatusBadRequest},
+		{"double comma with reject", []contentencoding.Option{contentencoding.WithRejectEmptyElements()}, "identity,,gzip", gz, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "test\n" {
+					t.Errorf("should be test but got='%s'", b)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("should be %d but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestDecode_FieldLines(t *testing.T) {
+	payload := []byte("field lines test")
+	gzbr, err := contentencodingtest.CompressBody(payload, "gzip", "br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reject := []contentencoding.Option{contentencoding.WithRejectFieldLines()}
+	tests := []struct {
+		name  string
+		opts  []contentencoding.Option
+		lines []string
+		want  int
+	}{
+		{"multiple lines", nil, []string{"gzip", "br"}, http.StatusOK},
+		{"identity first", nil, []string{"identity", "gzip, br"}, http.StatusOK},
+		{"obs-fold", nil, []string{"gzip,\r\n br"}, http.StatusOK},
+		{"bare LF", nil, []string{"gzip,\nbr"}, http.StatusBadRequest},
+		{"multiple lines with reject", reject, []string{"gzip", "br"}, http.StatusBadRequest},
+		{"obs-fold with reject", reject, []string{"gzip,\r\n\tbr"}, http.StatusBadRequest},
+		{"one line with reject", reject, []string{"gzip, br"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ce := r.Header.Values("Content-Encoding"); len(ce) != 1 {
+					t.Errorf("Content-Encoding should be combined but got %q", ce)
+				}
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b, payload) {
+					t.Errorf("should be %s but got %s", payload, b)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzbr))
+			req.Header["Content-Encoding"] = tt.lines
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("should be %d but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package contentencodingtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// Layer is a coding of a Chain.
+type Layer struct {
+	// Encoding is the content coding.
+	Encoding string
+	// NewWriter returns a writer encoding to w, the built-in encoder of Encoding is used if nil.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// Chain is a chain of codings in the order of Content-Encoding, the first one is applied first.
+// It builds multi-layer bodies mixing built-in and custom codings for tests.
+type Chain []Layer
+
+// NewChain returns a Chain of built-in encodings.
+func NewChain(encodings ...string) Chain {
+	c := make(Chain, len(encodings))
+	for i, e := range encodings {
+		c[i] = Layer{Encoding: e}
+	}
+	return c
+}
+
+// Then returns a copy of c with a coding appended, newWriter may be nil for built-in codings.
+func (c Chain) Then(encoding string, newWriter func(w io.Writer) (io.WriteCloser, error)) Chain {
+	return append(append(Chain{}, c...), Layer{Encoding: encoding, NewWriter: newWriter})
+}
+
+// ContentEncoding returns the value of Content-Encoding of c.
+func (c Chain) ContentEncoding() string {
+	encodings := make([]string, len(c))
+	for i, l := range c {
+		encodings[i] = l.Encoding
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// Compress encodes b with all codings of c.
+func (c Chain) Compress(b []byte) ([]byte, error) {
+	for _, l := range c {
+		if l.NewWriter == nil {
+			var err error
+			if b, err = CompressBody(b, l.Encoding); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var buf bytes.Buffer
+		w, err := l.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+	return b, nil
+}
+
+// NewRequest returns a new incoming server Request like httptest.NewRequest,
+// whose body is encoded by Compress and Content-Encoding is set to c.
+// It panics on error, since it is intended for tests.
+func (c Chain) NewRequest(method, url string, body []byte) *http.Request {
+	b, err := c.Compress(body)
+	if err != nil {
+		panic("contentencodingtest: " + err.Error())
+	}
+	req := httptest.NewRequest(method, url, bytes.NewReader(b))
+	if len(c) > 0 {
+		req.Header.Set("Content-Encoding", c.ContentEncoding())
+	}
+	return req
+}
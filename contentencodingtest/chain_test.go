@@ -0,0 +1,45 @@
+package contentencodingtest_test
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestChain(t *testing.T) {
+	b64 := func(w io.Writer) (io.WriteCloser, error) {
+		return base64.NewEncoder(base64.StdEncoding, w), nil
+	}
+	c := contentencodingtest.NewChain("gzip").Then("base64", b64).Then("zstd", nil)
+	if got := c.ContentEncoding(); got != "gzip, base64, zstd" {
+		t.Errorf("should be gzip, base64, zstd but got %s", got)
+	}
+
+	b, err := c.Compress([]byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = contentencodingtest.DecompressBody(b, "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = base64.StdEncoding.DecodeString(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = contentencodingtest.DecompressBody(b, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "test" {
+		t.Errorf("should be test but got='%s'", b)
+	}
+
+	req := c.NewRequest(http.MethodPost, "/", []byte("test"))
+	if req.Header.Get("Content-Encoding") != c.ContentEncoding() {
+		t.Errorf("unexpected Content-Encoding %s", req.Header.Get("Content-Encoding"))
+	}
+}
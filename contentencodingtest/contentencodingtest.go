@@ -0,0 +1,69 @@
+// Package contentencodingtest provides utilities for testing handlers behind go-content-encoding middleware.
+package contentencodingtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressBody encodes b with encodings in the order of Content-Encoding, the first one is applied first.
+// br, gzip, x-gzip, zstd and identity are supported.
+// The settings of each coding are pinned, so the result is deterministic for the same input.
+func CompressBody(b []byte, encodings ...string) ([]byte, error) {
+	for _, e := range encodings {
+		var buf bytes.Buffer
+		w, err := newWriter(strings.ToLower(strings.TrimSpace(e)), &buf)
+		if err != nil {
+			return nil, err
+		}
+		if w == nil {
+			continue
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+	return b, nil
+}
+
+// NewCompressedRequest returns a new incoming server Request like httptest.NewRequest,
+// whose body is encoded by CompressBody and Content-Encoding is set to encodings.
+// It panics on error, since it is intended for tests.
+func NewCompressedRequest(method, url string, body []byte, encodings ...string) *http.Request {
+	b, err := CompressBody(body, encodings...)
+	if err != nil {
+		panic("contentencodingtest: " + err.Error())
+	}
+	req := httptest.NewRequest(method, url, bytes.NewReader(b))
+	if len(encodings) > 0 {
+		req.Header.Set("Content-Encoding", strings.Join(encodings, ", "))
+	}
+	return req
+}
+
+func newWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case "gzip", "x-gzip":
+		// the header has neither a name nor a modification time.
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	case "zstd":
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+	case "", "identity":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported coding %q", encoding)
+}
@@ -0,0 +1,51 @@
+package contentencodingtest_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestNewCompressedRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		encodings []string
+	}{
+		{"none", nil},
+		{"brotli", []string{"br"}},
+		{"gzip", []string{"gzip"}},
+		{"zstd", []string{"zstd"}},
+		{"gzip+zstd+br", []string{"gzip", "zstd", "br"}},
+		{"identity", []string{"identity"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "test" {
+					t.Errorf("should be test but got='%s'", b)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), tt.encodings...)
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("should be 200 but got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCompressBody_unsupported(t *testing.T) {
+	if _, err := contentencodingtest.CompressBody([]byte("test"), "gzip", "custom"); err == nil {
+		t.Error("should be error")
+	}
+}
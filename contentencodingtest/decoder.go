@@ -0,0 +1,83 @@
+package contentencodingtest
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// DecoderCall is an invocation recorded by RecordingDecoder.
+type DecoderCall struct {
+	// ContentEncoding is the Content-Encoding header seen by the decoder.
+	ContentEncoding string
+	// BytesRead is the number of bytes the handler has read through the decoder so far.
+	BytesRead int64
+}
+
+// RecordingDecoder is a test double of contentencoding.Decoder.
+// It passes the body through unchanged and records its invocations, and can be scripted to fail.
+// It is safe for concurrent use.
+type RecordingDecoder struct {
+	// Encoding is the coding handled by the decoder.
+	Encoding string
+	// Err is returned by the decoder before reading the body if not nil.
+	Err error
+	// ReadErr is returned by the body read instead of io.EOF if not nil.
+	ReadErr error
+
+	mu    sync.Mutex
+	calls []DecoderCall
+}
+
+// Decoder returns the decoder to pass to contentencoding.WithDecoder.
+func (d *RecordingDecoder) Decoder() *contentencoding.Decoder {
+	return &contentencoding.Decoder{
+		Encoding: d.Encoding,
+		Handler: func(w http.ResponseWriter, r *http.Request) error {
+			d.mu.Lock()
+			i := len(d.calls)
+			d.calls = append(d.calls, DecoderCall{ContentEncoding: r.Header.Get("Content-Encoding")})
+			d.mu.Unlock()
+			if d.Err != nil {
+				return d.Err
+			}
+			r.Body = &recordingBody{ReadCloser: r.Body, d: d, i: i}
+			return nil
+		},
+	}
+}
+
+// Calls returns a copy of the recorded invocations in order.
+func (d *RecordingDecoder) Calls() []DecoderCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DecoderCall(nil), d.calls...)
+}
+
+// Reset clears the recorded invocations.
+func (d *RecordingDecoder) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = nil
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	d *RecordingDecoder
+	i int
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.d.mu.Lock()
+	if b.i < len(b.d.calls) {
+		b.d.calls[b.i].BytesRead += int64(n)
+	}
+	b.d.mu.Unlock()
+	if err == io.EOF && b.d.ReadErr != nil {
+		err = b.d.ReadErr
+	}
+	return n, err
+}
@@ -0,0 +1,74 @@
+package contentencodingtest_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestRecordingDecoder(t *testing.T) {
+	d := &contentencodingtest.RecordingDecoder{Encoding: "test"}
+	h := contentencoding.Decode(contentencoding.WithDecoder(d.Decoder()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("should be hello but got='%s'", b)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	req.Header.Set("Content-Encoding", "test")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	calls := d.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("should be called once but got %d", len(calls))
+	}
+	if calls[0].ContentEncoding != "test" || calls[0].BytesRead != 5 {
+		t.Errorf("unexpected call %+v", calls[0])
+	}
+
+	d.Reset()
+	if len(d.Calls()) != 0 {
+		t.Error("calls should be cleared")
+	}
+}
+
+func TestRecordingDecoder_Err(t *testing.T) {
+	errTest := errors.New("test")
+	tests := []struct {
+		name string
+		d    *contentencodingtest.RecordingDecoder
+	}{
+		{"Err", &contentencodingtest.RecordingDecoder{Encoding: "test", Err: errTest}},
+		{"ReadErr", &contentencodingtest.RecordingDecoder{Encoding: "test", ReadErr: errTest}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got error
+			h := contentencoding.Decode(
+				contentencoding.WithDecoder(tt.d.Decoder()),
+				contentencoding.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+					got = err
+				}),
+			)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, got = ioutil.ReadAll(r.Body)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+			req.Header.Set("Content-Encoding", "test")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !errors.Is(got, errTest) {
+				t.Errorf("should be %v but got %v", errTest, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package contentencodingtest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func ExampleNewCompressedRequest() {
+	handler := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(b))
+	}))
+
+	rec := httptest.NewRecorder()
+	req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "gzip", "zstd")
+	handler.ServeHTTP(rec, req)
+
+	// Output:
+	// test
+}
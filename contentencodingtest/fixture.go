@@ -0,0 +1,72 @@
+package contentencodingtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fixtureEncodings are the encodings of the fixtures written by WriteFixtures.
+var fixtureEncodings = [][]string{
+	{"br"},
+	{"gzip"},
+	{"zstd"},
+	{"gzip", "zstd"},
+}
+
+// FixtureEncodings returns a copy of the encodings of the fixtures written by WriteFixtures.
+func FixtureEncodings() [][]string {
+	encodings := make([][]string, len(fixtureEncodings))
+	for i, e := range fixtureEncodings {
+		encodings[i] = append([]string(nil), e...)
+	}
+	return encodings
+}
+
+var fixtureExts = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// FixtureName returns the file name of the fixture of name encoded with encodings,
+// such as "test.txt.gz.zst" for "test.txt" with gzip and zstd.
+func FixtureName(name string, encodings ...string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, e := range encodings {
+		if ext, ok := fixtureExts[e]; ok {
+			b.WriteString(ext)
+		} else {
+			b.WriteString("." + e)
+		}
+	}
+	return b.String()
+}
+
+// WriteFixtures writes the fixtures of each file for FixtureEncodings into dir, named by FixtureName of the base name.
+// dir is created if it doesn't exist. Since CompressBody is deterministic, the fixtures can be committed
+// and regenerated reproducibly. Keep dir apart from fixtures produced by other encoders,
+// so that tests decoding them don't only check data of this package.
+func WriteFixtures(dir string, paths ...string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, encodings := range fixtureEncodings {
+			compressed, err := CompressBody(b, encodings...)
+			if err != nil {
+				return err
+			}
+			name := filepath.Join(dir, FixtureName(filepath.Base(path), encodings...))
+			if err := os.WriteFile(name, compressed, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
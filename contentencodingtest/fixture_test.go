@@ -0,0 +1,67 @@
+package contentencodingtest_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWriteFixtures(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "test.txt")
+	dir := filepath.Join(t.TempDir(), "generated")
+	body := bytes.Repeat([]byte("test "), 1000)
+	if err := os.WriteFile(src, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() map[string][]byte {
+		t.Helper()
+		if err := contentencodingtest.WriteFixtures(dir, src); err != nil {
+			t.Fatal(err)
+		}
+		fixtures := make(map[string][]byte)
+		for _, encodings := range contentencodingtest.FixtureEncodings() {
+			name := filepath.Join(dir, contentencodingtest.FixtureName("test.txt", encodings...))
+			b, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := contentencodingtest.DecompressBody(b, encodings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, body) {
+				t.Errorf("%s should be decoded to the source", name)
+			}
+			fixtures[name] = b
+		}
+		return fixtures
+	}
+
+	first, second := read(), read()
+	for name, b := range first {
+		if !bytes.Equal(b, second[name]) {
+			t.Errorf("%s should be deterministic", name)
+		}
+	}
+}
+
+func TestFixtureName(t *testing.T) {
+	if got := contentencodingtest.FixtureName("test.txt", "gzip", "zstd"); got != "test.txt.gz.zst" {
+		t.Errorf("should be test.txt.gz.zst but got %s", got)
+	}
+	if got := contentencodingtest.FixtureName("test.txt", "br"); got != "test.txt.br" {
+		t.Errorf("should be test.txt.br but got %s", got)
+	}
+}
+
+func TestFixtureEncodings(t *testing.T) {
+	encodings := contentencodingtest.FixtureEncodings()
+	encodings[0][0] = "changed"
+	if got := contentencodingtest.FixtureEncodings()[0][0]; got == "changed" {
+		t.Error("should return a copy")
+	}
+}
@@ -0,0 +1,72 @@
+package contentencodingtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// FuzzMaxDecodedBytes is the maximum number of decoded bytes read by FuzzDecode for each input,
+// so that small inputs expanding to huge bodies do not slow fuzzing down.
+const FuzzMaxDecodedBytes = 1 << 20
+
+// FuzzContentEncoding is a fuzz target of contentencoding.ParseContentEncoding.
+// Call it from a fuzz test such as:
+//
+//	func FuzzContentEncoding(f *testing.F) { contentencodingtest.FuzzContentEncoding(f) }
+func FuzzContentEncoding(f *testing.F) {
+	for _, s := range []string{"", "gzip", "gzip, zstd", "br,,", "x-gzip", "identity", "gzip;q=1"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		values, _ := contentencoding.ParseContentEncoding(s)
+		for _, v := range values {
+			if v.Coding == "" || strings.ContainsAny(v.Coding, ", ;\t") {
+				t.Errorf("invalid coding %q in %q", v.Coding, s)
+			}
+		}
+	})
+}
+
+// FuzzDecode is a fuzz target of the multi-layer decoding of contentencoding.Decode configured with opts,
+// which may register custom decoders and contentencoding.WithLimits.
+// The fuzzer mutates the Content-Encoding header and the body, seeded with valid bodies of all built-in chains.
+// The target fails on panics and when the handler is called after the error handler,
+// and reads at most FuzzMaxDecodedBytes of the decoded body.
+// Call it from a fuzz test such as:
+//
+//	func FuzzDecode(f *testing.F) { contentencodingtest.FuzzDecode(f, contentencoding.WithDecoder(myDecoder)) }
+func FuzzDecode(f *testing.F, opts ...contentencoding.Option) {
+	for _, payload := range [][]byte{nil, []byte("test"), bytes.Repeat([]byte("go-content-encoding"), 100)} {
+		for _, encodings := range fixtureEncodings {
+			b, err := CompressBody(payload, encodings...)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(strings.Join(encodings, ", "), b)
+		}
+		f.Add("identity", payload)
+	}
+
+	f.Fuzz(func(t *testing.T, contentEncoding string, body []byte) {
+		var errorHandled bool
+		o := append([]contentencoding.Option{}, opts...)
+		o = append(o, contentencoding.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			errorHandled = true
+		}))
+		h := contentencoding.Decode(o...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if errorHandled {
+				t.Error("handler should not be called after the error handler")
+			}
+			io.Copy(io.Discard, io.LimitReader(r.Body, FuzzMaxDecodedBytes))
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", contentEncoding)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	})
+}
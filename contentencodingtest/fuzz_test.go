@@ -0,0 +1,15 @@
+package contentencodingtest_test
+
+import (
+	"testing"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func FuzzContentEncoding(f *testing.F) {
+	contentencodingtest.FuzzContentEncoding(f)
+}
+
+func FuzzDecode(f *testing.F) {
+	contentencodingtest.FuzzDecode(f)
+}
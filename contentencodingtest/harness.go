@@ -0,0 +1,117 @@
+package contentencodingtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// HarnessConfig is the configuration of NewHarness.
+type HarnessConfig struct {
+	// Handler is served behind contentencoding.Decode, EchoHandler() if nil.
+	Handler http.Handler
+	// Options are passed to contentencoding.Decode on the server and contentencoding.DecodeResponse on the client.
+	Options []contentencoding.Option
+	// Transport is the base transport of Client, the transport of the server's client if nil.
+	// Its responses are decoded by contentencoding.DecodeResponse.
+	Transport http.RoundTripper
+}
+
+// Harness is an end-to-end integration test environment, which consists of
+// a httptest.Server decoding requests and a client decoding responses.
+type Harness struct {
+	// Server serves the handler behind contentencoding.Decode.
+	Server *httptest.Server
+	// Client sends requests to Server and decodes the responses.
+	Client *http.Client
+}
+
+// NewHarness starts a Harness, which is closed by t.Cleanup.
+func NewHarness(t testing.TB, cfg HarnessConfig) *Harness {
+	t.Helper()
+	handler := cfg.Handler
+	if handler == nil {
+		handler = EchoHandler()
+	}
+	srv := httptest.NewServer(contentencoding.Decode(cfg.Options...)(handler))
+	t.Cleanup(srv.Close)
+
+	base := cfg.Transport
+	if base == nil {
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		// the responses must reach DecodeResponse as they are.
+		tr.DisableCompression = true
+		base = tr
+	}
+	return &Harness{
+		Server: srv,
+		Client: &http.Client{
+			Transport: &decodingTransport{base: base, decode: contentencoding.DecodeResponse(cfg.Options...)},
+		},
+	}
+}
+
+// Post sends body already encoded with contentEncoding to path of the server,
+// and returns the response and its decoded body.
+func (h *Harness) Post(t testing.TB, path, contentEncoding string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, h.Server.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, b
+}
+
+// EchoHandler returns a handler that writes back the request body encoded with encodings by CompressBody.
+func EchoHandler(encodings ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, err = CompressBody(b, encodings...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(encodings) > 0 {
+			w.Header().Set("Content-Encoding", strings.Join(encodings, ", "))
+		}
+		w.Write(b)
+	})
+}
+
+type decodingTransport struct {
+	base   http.RoundTripper
+	decode func(*http.Response) error
+}
+
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.decode(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
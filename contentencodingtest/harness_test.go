@@ -0,0 +1,52 @@
+package contentencodingtest_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestHarness(t *testing.T) {
+	tests := []struct {
+		name              string
+		requestEncodings  []string
+		responseEncodings []string
+	}{
+		{"identity", nil, nil},
+		{"gzip request", []string{"gzip"}, nil},
+		{"zstd response", nil, []string{"zstd"}},
+		{"both", []string{"gzip", "zstd"}, []string{"br", "gzip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencodingtest.NewHarness(t, contentencodingtest.HarnessConfig{
+				Handler: contentencodingtest.EchoHandler(tt.responseEncodings...),
+			})
+			body, err := contentencodingtest.CompressBody([]byte("test"), tt.requestEncodings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, got := h.Post(t, "/", strings.Join(tt.requestEncodings, ", "), body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("should be 200 but got %d: %s", resp.StatusCode, got)
+			}
+			if string(got) != "test" {
+				t.Errorf("should be test but got='%s'", got)
+			}
+			if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+				t.Errorf("response should be decoded but Content-Encoding is %s", ce)
+			}
+		})
+	}
+}
+
+func TestHarness_InvalidBody(t *testing.T) {
+	h := contentencodingtest.NewHarness(t, contentencodingtest.HarnessConfig{})
+	resp, _ := h.Post(t, "/", "gzip", []byte("test"))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("should be 400 but got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,72 @@
+package contentencodingtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ResponseRecorder is a httptest.ResponseRecorder that can decode the recorded body by its Content-Encoding,
+// to make assertions on responses of compressing handlers simple.
+type ResponseRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+// NewRecorder returns an initialized ResponseRecorder.
+func NewRecorder() *ResponseRecorder {
+	return &ResponseRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+// DecodedBody returns the recorded body decoded by Content-Encoding of the recorded response.
+func (rec *ResponseRecorder) DecodedBody() ([]byte, error) {
+	ce := rec.Result().Header.Get("Content-Encoding")
+	var encodings []string
+	if ce != "" {
+		encodings = strings.Split(ce, ",")
+	}
+	return DecompressBody(rec.Body.Bytes(), encodings...)
+}
+
+// DecompressBody decodes b encoded with encodings in the order of Content-Encoding, the last one is removed first.
+// br, gzip, x-gzip, zstd and identity are supported.
+func DecompressBody(b []byte, encodings ...string) ([]byte, error) {
+	for i := len(encodings) - 1; i >= 0; i-- {
+		r, err := newReader(strings.ToLower(strings.TrimSpace(encodings[i])), bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+		b, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func newReader(encoding string, r io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "br":
+		return brotli.NewReader(r), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case "", "identity":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported coding %q", encoding)
+}
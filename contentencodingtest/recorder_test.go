@@ -0,0 +1,52 @@
+package contentencodingtest_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestResponseRecorder_DecodedBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		encodings []string
+	}{
+		{"identity", nil},
+		{"brotli", []string{"br"}},
+		{"gzip+zstd", []string{"gzip", "zstd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := contentencodingtest.CompressBody([]byte("test"), tt.encodings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := contentencodingtest.NewRecorder()
+			if len(tt.encodings) > 0 {
+				rec.Header().Set("Content-Encoding", strings.Join(tt.encodings, ", "))
+			}
+			rec.WriteHeader(http.StatusOK)
+			rec.Write(b)
+
+			got, err := rec.DecodedBody()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "test" {
+				t.Errorf("should be test but got='%s'", got)
+			}
+		})
+	}
+}
+
+func TestDecompressBody_error(t *testing.T) {
+	if _, err := contentencodingtest.DecompressBody([]byte("test"), "gzip"); err == nil {
+		t.Error("should be error for invalid body")
+	}
+	if _, err := contentencodingtest.DecompressBody([]byte("test"), "custom"); err == nil {
+		t.Error("should be error for unsupported coding")
+	}
+}
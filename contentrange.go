@@ -0,0 +1,49 @@
+package contentencoding
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrContentRange is passed to the error handler for a request with both Content-Encoding and Content-Range
+// when ContentRangeReject is used.
+var ErrContentRange = errors.New("contentencoding: Content-Encoding with Content-Range is not supported")
+
+// ContentRangePolicy is the behavior of Decode for requests with both Content-Encoding and Content-Range,
+// such as chunks of resumable uploads.
+type ContentRangePolicy int
+
+const (
+	// ContentRangeDecode decodes each request body on its own, so each chunk must be a complete encoding.
+	// Content-Range is left as it is, so its offsets are interpreted against the encoded stream.
+	ContentRangeDecode ContentRangePolicy = iota
+	// ContentRangePassthrough leaves the body encoded, for handlers that assemble the encoded stream from chunks
+	// and decode it as a whole.
+	ContentRangePassthrough
+	// ContentRangeReject calls the error handler with ErrContentRange.
+	ContentRangeReject
+)
+
+// WithContentRangePolicy returns a Option to define the behavior for requests with both Content-Encoding and Content-Range.
+// By default, ContentRangeDecode is used.
+func WithContentRangePolicy(p ContentRangePolicy) Option {
+	return func(cfg *config) {
+		cfg.contentRange = p
+	}
+}
+
+// isEncodedRange reports whether r has Content-Range and a content coding other than identity.
+func isEncodedRange(r *http.Request) bool {
+	return r.Header.Get("Content-Range") != "" && isEncoded(r)
+}
+
+// isEncoded reports whether r has a content coding other than identity.
+func isEncoded(r *http.Request) bool {
+	values, _ := ParseContentEncoding(r.Header.Get("Content-Encoding"))
+	for _, v := range values {
+		if v.Coding != "identity" {
+			return true
+		}
+	}
+	return false
+}
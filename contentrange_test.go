@@ -0,0 +1,52 @@
+package contentencoding_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithContentRangePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       contentencoding.ContentRangePolicy
+		contentRange string
+		wantStatus   int
+		wantDecoded  bool
+	}{
+		{"decode", contentencoding.ContentRangeDecode, "bytes 0-9/100", http.StatusOK, true},
+		{"passthrough", contentencoding.ContentRangePassthrough, "bytes 0-9/100", http.StatusOK, false},
+		{"reject", contentencoding.ContentRangeReject, "bytes 0-9/100", http.StatusBadRequest, false},
+		{"reject without Content-Range", contentencoding.ContentRangeReject, "", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithContentRangePolicy(tt.policy))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decoded := string(b) == "test"; decoded != tt.wantDecoded {
+					t.Errorf("decoded should be %v but got %v", tt.wantDecoded, decoded)
+				}
+				if got := r.Header.Get("Content-Range"); got != tt.contentRange {
+					t.Errorf("Content-Range should be left as it is but got %s", got)
+				}
+			}))
+			req := contentencodingtest.NewCompressedRequest(http.MethodPatch, "/", []byte("test"), "gzip")
+			if tt.contentRange != "" {
+				req.Header.Set("Content-Range", tt.contentRange)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package contentencoding
+
+import (
+	"mime"
+	"strings"
+)
+
+// WithCompressContentTypes returns a Option to compress only responses whose Content-Type matches one of types,
+// e.g. "text/*" and "application/json". A type ending with "/*" matches all subtypes,
+// and parameters such as charset are ignored.
+// The Content-Type is sniffed from the first write as net/http does if the handler doesn't set it.
+// It applies to Encode, ServeContent and the br and zstd responses of Gzhttp.
+func WithCompressContentTypes(types ...string) Option {
+	types = normalizeMediaTypes(types)
+	return func(cfg *config) {
+		cfg.compressTypes = types
+	}
+}
+
+// WithSkipContentTypes returns a Option not to compress responses whose Content-Type matches one of types,
+// e.g. "image/*" and "application/zip" that are compressed already.
+// Types are matched as WithCompressContentTypes does, and skipped types win over it.
+func WithSkipContentTypes(types ...string) Option {
+	types = normalizeMediaTypes(types)
+	return func(cfg *config) {
+		cfg.skipTypes = types
+	}
+}
+
+func normalizeMediaTypes(types []string) []string {
+	normalized := make([]string, len(types))
+	for i, t := range types {
+		normalized[i] = mediaType(t)
+	}
+	return normalized
+}
+
+// mediaType returns the lowercased media type of ctype without parameters.
+func mediaType(ctype string) string {
+	if mt, _, err := mime.ParseMediaType(ctype); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(ctype, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// hasContentTypes reports whether responses are compressed by their Content-Type.
+func (cfg *config) hasContentTypes() bool {
+	return cfg.compressTypes != nil || len(cfg.skipTypes) > 0
+}
+
+// compressibleType reports whether the response of ctype is compressed by WithCompressContentTypes
+// and WithSkipContentTypes.
+func (cfg *config) compressibleType(ctype string) bool {
+	mt := mediaType(ctype)
+	if matchMediaType(cfg.skipTypes, mt) {
+		return false
+	}
+	return cfg.compressTypes == nil || matchMediaType(cfg.compressTypes, mt)
+}
+
+func matchMediaType(types []string, mt string) bool {
+	for _, t := range types {
+		if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
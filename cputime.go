@@ -0,0 +1,38 @@
+package contentencoding
+
+import (
+	"io"
+	"runtime"
+	"time"
+)
+
+// WithCPUAccounting returns a Option to measure the CPU time spent reading the decoded body of each request,
+// which is reported as Stats.CPUTime to the hook of WithStatsHook.
+// It is supported on Linux only, where it uses the CPU clock of the thread, and CPUTime is zero elsewhere.
+// CPU time of decoders working in other goroutines, such as zstd decoding blocks concurrently, is not included,
+// so use it with WithMaxReadAhead to measure zstd.
+func WithCPUAccounting() Option {
+	return func(cfg *config) {
+		cfg.cpuAccounting = true
+	}
+}
+
+// cpuBody measures the thread CPU time spent in reads.
+type cpuBody struct {
+	io.ReadCloser
+	d time.Duration
+}
+
+func (b *cpuBody) Read(p []byte) (int, error) {
+	// the goroutine must stay on the thread whose clock is read.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	start, ok := threadCPUTime()
+	n, err := b.ReadCloser.Read(p)
+	if ok {
+		if end, ok := threadCPUTime(); ok {
+			b.d += end - start
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,18 @@
+package contentencoding
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const clockThreadCPUTimeID = 3
+
+// threadCPUTime returns the CPU time consumed by the current thread.
+func threadCPUTime() (time.Duration, bool) {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockThreadCPUTimeID, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0, false
+	}
+	return time.Duration(ts.Nano()), true
+}
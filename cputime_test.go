@@ -0,0 +1,38 @@
+package contentencoding_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithCPUAccounting(t *testing.T) {
+	payload := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(payload)
+	var got contentencoding.Stats
+	h := contentencoding.Decode(
+		contentencoding.WithCPUAccounting(),
+		contentencoding.WithStatsHook(func(r *http.Request, s contentencoding.Stats) {
+			got = s
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", payload, "br"))
+
+	if runtime.GOOS != "linux" {
+		if got.CPUTime != 0 {
+			t.Errorf("CPUTime should be zero on %s but got %v", runtime.GOOS, got.CPUTime)
+		}
+		return
+	}
+	if got.CPUTime <= 0 {
+		t.Errorf("CPUTime should be measured but got %v", got.CPUTime)
+	}
+}
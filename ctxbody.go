@@ -0,0 +1,61 @@
+package contentencoding
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ctxBody is a decoded body whose Read returns the error of ctx once ctx is done.
+// When ctx is done, the encoded body under the decoders is closed, which unblocks a read waiting for
+// partially delivered encoded data on bodies such as HTTP/2 request bodies and pipes.
+type ctxBody struct {
+	ctx   context.Context
+	rc    io.ReadCloser
+	err   error
+	stop  chan struct{}
+	close sync.Once
+}
+
+// withContext wraps body to observe cancellation of ctx, closing under when ctx is done while body is open.
+// It returns body as it is if ctx is never done.
+func withContext(ctx context.Context, body io.ReadCloser, under io.Closer) io.ReadCloser {
+	if ctx.Done() == nil {
+		return body
+	}
+	b := &ctxBody{ctx: ctx, rc: body, stop: make(chan struct{})}
+	// a single watcher per body, as context.AfterFunc of Go 1.21 would do.
+	go func() {
+		select {
+		case <-ctx.Done():
+			under.Close()
+		case <-b.stop:
+		}
+	}()
+	return b
+}
+
+func (b *ctxBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if err := b.ctx.Err(); err != nil {
+		b.err = err
+		return 0, err
+	}
+	n, err := b.rc.Read(p)
+	if err != nil && err != io.EOF {
+		if cerr := b.ctx.Err(); cerr != nil {
+			// the error is caused by closing the encoded body.
+			b.err = cerr
+			return n, cerr
+		}
+	}
+	return n, err
+}
+
+// Close stops watching ctx and closes the underlying body.
+func (b *ctxBody) Close() error {
+	b.close.Do(func() { close(b.stop) })
+	return b.rc.Close()
+}
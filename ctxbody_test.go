@@ -0,0 +1,62 @@
+package contentencoding_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestDecode_ContextCancellation(t *testing.T) {
+	passthrough := &contentencoding.Decoder{
+		Encoding: "custom",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	}
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	h := contentencoding.Decode(contentencoding.WithDecoder(passthrough))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		done <- err
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", pr).WithContext(ctx)
+	req.Header.Set("Content-Encoding", "custom")
+	go h.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("should be %v but got %v", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read should return after cancellation")
+	}
+}
+
+func TestDecode_ContextNotDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "test" {
+			t.Errorf("should be test but got='%s'", b)
+		}
+	}))
+	req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "gzip", "zstd").WithContext(ctx)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
@@ -0,0 +1,207 @@
+package contentencoding
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecodeMode is how the middleware decodes a request body, it is chosen by DecodePolicy.
+type DecodeMode int
+
+const (
+	// DecodeStreaming decodes the body while the handler reads it.
+	DecodeStreaming DecodeMode = iota
+	// DecodeBuffered decodes the whole body in memory before the handler is called,
+	// so that errors of decoding are reported by the error handler instead of reads of the body.
+	// A body encoded only with zstd is decoded at once by zstd.Decoder.DecodeAll
+	// if its frame header declares a size within 1MB and the limits.
+	DecodeBuffered
+	// DecodeSpill decodes the whole body into a temporary file before the handler is called,
+	// which is removed after the handler returns.
+	DecodeSpill
+)
+
+// DecodePolicy chooses DecodeMode of r encoded with codings, in the order they were applied, under limits.
+type DecodePolicy func(r *http.Request, codings []string, limits Limits) DecodeMode
+
+// WithDecodePolicy returns a Option to choose DecodeMode of each request by policy, e.g. AutoDecodePolicy.
+// Requests with only identity codings are not affected.
+// By default, all bodies are streamed.
+func WithDecodePolicy(policy DecodePolicy) Option {
+	return func(cfg *config) {
+		cfg.decodePolicy = policy
+	}
+}
+
+const (
+	// autoBufferMax is the maximum decoded size buffered by AutoDecodePolicy.
+	autoBufferMax = 1 << 20
+	// autoSpillMin is the minimum Content-Length spilled by AutoDecodePolicy.
+	autoSpillMin = 32 << 20
+)
+
+// AutoDecodePolicy is a DecodePolicy for most servers.
+// Bodies whose decoded size is bounded to 1MB by Content-Length and limits are buffered,
+// bodies with Content-Length of 32MB or more are spilled, and the others are streamed.
+// Bodies with codings of WithDecoder are always streamed.
+func AutoDecodePolicy(r *http.Request, codings []string, limits Limits) DecodeMode {
+	for _, c := range codings {
+		if c != "identity" && !isBuiltin(c) {
+			return DecodeStreaming
+		}
+	}
+	if r.ContentLength <= 0 {
+		return DecodeStreaming
+	}
+	if r.ContentLength >= autoSpillMin {
+		return DecodeSpill
+	}
+	bound := limits.MaxDecodedBytes
+	if limits.MaxRatio > 0 {
+		b := int64(float64(r.ContentLength) * limits.MaxRatio)
+		if b < ratioGrace {
+			b = ratioGrace
+		}
+		if bound <= 0 || b < bound {
+			bound = b
+		}
+	}
+	if bound > 0 && bound <= autoBufferMax {
+		return DecodeBuffered
+	}
+	return DecodeStreaming
+}
+
+type decodeModeKey struct{}
+
+// decodeMode returns r with the DecodeMode chosen by WithDecodePolicy.
+func (cfg *config) decodeMode(r *http.Request) (*http.Request, DecodeMode) {
+	if cfg.decodePolicy == nil {
+		return r, DecodeStreaming
+	}
+	codings, err := contentCodings(r.Header.Get("Content-Encoding"))
+	if err != nil || !isEncoded(r) {
+		return r, DecodeStreaming
+	}
+	mode := cfg.decodePolicy(r, codings, cfg.limits)
+	if mode == DecodeStreaming {
+		return r, mode
+	}
+	return r.WithContext(context.WithValue(r.Context(), decodeModeKey{}, mode)), mode
+}
+
+// decodeAll reports whether the zstd body of r is decoded by zstd.Decoder.DecodeAll.
+func (cfg *config) decodeAll(r *http.Request, codings []string) bool {
+	mode, _ := r.Context().Value(decodeModeKey{}).(DecodeMode)
+	return mode == DecodeBuffered && len(codings) == 1 && codings[0] == "zstd" && cfg.dictionaries == nil
+}
+
+// zstdAllReader decodes the whole zstd body at the first read.
+type zstdAllReader struct {
+	cfg *config
+	src io.Reader
+	rc  io.ReadCloser
+}
+
+func (z *zstdAllReader) Read(p []byte) (int, error) {
+	if z.rc == nil {
+		rc, err := z.decode()
+		if err != nil {
+			return 0, err
+		}
+		z.rc = rc
+	}
+	return z.rc.Read(p)
+}
+
+func (z *zstdAllReader) decode() (io.ReadCloser, error) {
+	src, err := io.ReadAll(z.src)
+	if err != nil {
+		return nil, err
+	}
+	var h zstd.Header
+	if h.Decode(src) != nil || !h.HasFCS || h.FrameContentSize > uint64(z.cfg.limits.allocBound(int64(len(src)))) {
+		// the size is unknown or too large to allocate at once, the limits are checked while streaming.
+		rc, _, err := z.cfg.builtinReader("zstd", bytes.NewReader(src))
+		return rc, err
+	}
+	var d *zstd.Decoder
+	if z.cfg.zstdPool != nil {
+		if d, err = z.cfg.zstdPool.take(); err != nil {
+			return nil, err
+		}
+		defer z.cfg.zstdPool.put(d)
+	} else {
+		if d, err = zstd.NewReader(nil, z.cfg.zstdOptions()...); err != nil {
+			return nil, err
+		}
+		defer d.Close()
+	}
+	b, err := d.DecodeAll(src, make([]byte, 0, h.FrameContentSize))
+	if err != nil {
+		return nil, z.cfg.limits.memoryError(err)
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// allocBound returns the largest decoded size of n encoded bytes allocated at once,
+// which is autoBufferMax further bounded by MaxDecodedBytes and MaxRatio,
+// since the size declared by the body is not trusted until it is decoded.
+func (l Limits) allocBound(n int64) int64 {
+	bound := int64(autoBufferMax)
+	if l.MaxDecodedBytes > 0 && l.MaxDecodedBytes < bound {
+		bound = l.MaxDecodedBytes
+	}
+	if l.MaxRatio > 0 {
+		b := int64(float64(n) * l.MaxRatio)
+		if b < ratioGrace {
+			b = ratioGrace
+		}
+		if b < bound {
+			bound = b
+		}
+	}
+	return bound
+}
+
+func (z *zstdAllReader) Close() error {
+	if z.rc != nil {
+		return z.rc.Close()
+	}
+	return nil
+}
+
+// materialize decodes the whole body of r into memory or a temporary file by mode.
+// It returns the cleanup function, which removes the temporary file.
+func materialize(r *http.Request, mode DecodeMode) (func(), error) {
+	body := r.Body
+	switch mode {
+	case DecodeBuffered:
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return func() {}, err
+		}
+		r.Body = &layeredBody{ReadCloser: io.NopCloser(bytes.NewReader(b)), under: body}
+	case DecodeSpill:
+		f, err := os.CreateTemp("", "contentencoding-spill-*")
+		if err != nil {
+			return func() {}, err
+		}
+		cleanup := func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		n, err := io.Copy(f, body)
+		if err != nil {
+			return cleanup, err
+		}
+		r.Body = &layeredBody{ReadCloser: io.NopCloser(io.NewSectionReader(f, 0, n)), under: body}
+		return cleanup, nil
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,138 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithDecodePolicy(t *testing.T) {
+	payload := bytes.Repeat([]byte("decode policy test "), 1000)
+	tests := []struct {
+		name     string
+		mode     contentencoding.DecodeMode
+		encoding string
+	}{
+		{"streaming", contentencoding.DecodeStreaming, "gzip"},
+		{"buffered gzip", contentencoding.DecodeBuffered, "gzip"},
+		{"buffered zstd", contentencoding.DecodeBuffered, "zstd"},
+		{"spill", contentencoding.DecodeSpill, "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := contentencodingtest.CompressBody(payload, tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			policy := contentencoding.WithDecodePolicy(func(r *http.Request, codings []string, limits contentencoding.Limits) contentencoding.DecodeMode {
+				return tt.mode
+			})
+			var handled bool
+			h := contentencoding.Decode(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if !bytes.Equal(b, payload) {
+					t.Error("decoded body is wrong")
+				}
+			}))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("status code should be %d but got %d", http.StatusOK, rec.Code)
+			}
+
+			// a truncated body is rejected before the handler is called unless it is streamed.
+			handled = false
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded[:len(encoded)/2]))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status code should be %d but got %d", http.StatusBadRequest, rec.Code)
+			}
+			if want := tt.mode == contentencoding.DecodeStreaming; handled != want {
+				t.Errorf("handler called should be %v but got %v", want, handled)
+			}
+		})
+	}
+}
+
+func TestWithDecodePolicy_limit(t *testing.T) {
+	payload := bytes.Repeat([]byte("decode policy test "), 1000)
+	encoded, err := contentencodingtest.CompressBody(payload, "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := contentencoding.Decode(
+		contentencoding.WithDecodePolicy(contentencoding.AutoDecodePolicy),
+		contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1000}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+	req.Header.Set("Content-Encoding", "zstd")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status code should be %d but got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestWithDecodePolicy_frameContentSize(t *testing.T) {
+	// a zstd frame declaring 1TB of content with an empty last block.
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0xc0, 0x00, 0, 0, 0, 0, 0, 1, 0, 0, 0x01, 0x00, 0x00}
+	h := contentencoding.Decode(
+		contentencoding.WithDecodePolicy(contentencoding.AutoDecodePolicy),
+		contentencoding.WithLimits(contentencoding.Limits{MaxRatio: 100}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(frame))
+	req.Header.Set("Content-Encoding", "zstd")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status code should be %d but got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestAutoDecodePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength int64
+		codings       []string
+		limits        contentencoding.Limits
+		want          contentencoding.DecodeMode
+	}{
+		{"bounded", 1000, []string{"gzip"}, contentencoding.Limits{MaxDecodedBytes: 1 << 20}, contentencoding.DecodeBuffered},
+		{"bounded by ratio", 1000, []string{"zstd"}, contentencoding.Limits{MaxRatio: 100}, contentencoding.DecodeBuffered},
+		{"unbounded", 1000, []string{"gzip"}, contentencoding.Limits{}, contentencoding.DecodeStreaming},
+		{"too large to buffer", 1000, []string{"br"}, contentencoding.Limits{MaxDecodedBytes: 2 << 20}, contentencoding.DecodeStreaming},
+		{"unknown length", -1, []string{"gzip"}, contentencoding.Limits{MaxDecodedBytes: 1000}, contentencoding.DecodeStreaming},
+		{"large", 64 << 20, []string{"zstd"}, contentencoding.Limits{}, contentencoding.DecodeSpill},
+		{"custom coding", 64 << 20, []string{"custom"}, contentencoding.Limits{}, contentencoding.DecodeStreaming},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.ContentLength = tt.contentLength
+			if got := contentencoding.AutoDecodePolicy(req, tt.codings, tt.limits); got != tt.want {
+				t.Errorf("should be %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
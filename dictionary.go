@@ -0,0 +1,193 @@
+package contentencoding
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	zstddict "github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrDictionaryNotFound is returned by DictionaryStore when it has no such dictionary.
+var ErrDictionaryNotFound = errors.New("contentencoding: dictionary not found")
+
+// DictionaryStore looks up compression dictionaries.
+// Implementations must be safe for concurrent use.
+type DictionaryStore interface {
+	// Dictionary returns the zstd dictionary with the dictionary ID.
+	Dictionary(id uint32) ([]byte, error)
+	// DictionaryByHash returns the dictionary whose SHA-256 is hash, as used by dictionary transports of HTTP.
+	DictionaryByHash(hash [sha256.Size]byte) ([]byte, error)
+}
+
+// DictionaryLister is implemented by a DictionaryStore that can list its dictionaries,
+// they are listed by Middleware.Capabilities so that clients can tell which dictionaries are accepted.
+type DictionaryLister interface {
+	// DictionaryIDs returns the IDs of the zstd dictionaries.
+	DictionaryIDs() []uint32
+	// DictionaryHashes returns the SHA-256 of all dictionaries.
+	DictionaryHashes() [][sha256.Size]byte
+}
+
+// MemoryDictionaryStore is a DictionaryStore that holds dictionaries in memory.
+// The zero value is an empty store ready to use.
+type MemoryDictionaryStore struct {
+	mu     sync.RWMutex
+	byID   map[uint32][]byte
+	byHash map[[sha256.Size]byte][]byte
+}
+
+// NewMemoryDictionaryStore returns a MemoryDictionaryStore with dicts.
+func NewMemoryDictionaryStore(dicts ...[]byte) *MemoryDictionaryStore {
+	s := new(MemoryDictionaryStore)
+	for _, d := range dicts {
+		s.Add(d)
+	}
+	return s
+}
+
+// NewFSDictionaryStore returns a MemoryDictionaryStore with the files in fsys matching pattern as fs.Glob does,
+// e.g. an embed.FS or os.DirFS for a directory of dictionaries.
+// The files are read once, a new store must be created to see changes of them.
+func NewFSDictionaryStore(fsys fs.FS, pattern string) (*MemoryDictionaryStore, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	s := new(MemoryDictionaryStore)
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("contentencoding: read dictionary %s: %w", name, err)
+		}
+		s.Add(b)
+	}
+	return s, nil
+}
+
+// Add adds dict to the store, it is copied.
+// Dictionaries in the zstd format are found by their ID and hash, raw content dictionaries only by hash.
+func (s *MemoryDictionaryStore) Add(dict []byte) {
+	dict = append([]byte(nil), dict...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byID == nil {
+		s.byID = make(map[uint32][]byte)
+		s.byHash = make(map[[sha256.Size]byte][]byte)
+	}
+	if id, ok := zstdDictID(dict); ok {
+		s.byID[id] = dict
+	}
+	s.byHash[sha256.Sum256(dict)] = dict
+}
+
+// Dictionary implements DictionaryStore.
+func (s *MemoryDictionaryStore) Dictionary(id uint32) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if d, ok := s.byID[id]; ok {
+		return d, nil
+	}
+	return nil, ErrDictionaryNotFound
+}
+
+// DictionaryByHash implements DictionaryStore.
+func (s *MemoryDictionaryStore) DictionaryByHash(hash [sha256.Size]byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if d, ok := s.byHash[hash]; ok {
+		return d, nil
+	}
+	return nil, ErrDictionaryNotFound
+}
+
+// DictionaryIDs implements DictionaryLister.
+func (s *MemoryDictionaryStore) DictionaryIDs() []uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]uint32, 0, len(s.byID))
+	for id := range s.byID {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// DictionaryHashes implements DictionaryLister.
+func (s *MemoryDictionaryStore) DictionaryHashes() [][sha256.Size]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hashes := make([][sha256.Size]byte, 0, len(s.byHash))
+	for h := range s.byHash {
+		hashes = append(hashes, h)
+	}
+	return hashes
+}
+
+// zstdDictID returns the ID of dict in the zstd dictionary format.
+func zstdDictID(dict []byte) (uint32, bool) {
+	if len(dict) < 8 || binary.LittleEndian.Uint32(dict) != 0xEC30A437 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(dict[4:]), true
+}
+
+// TrainDictionary builds a zstd dictionary of at most maxSize bytes from samples, e.g. typical request payloads.
+// The dictionary can be added to DictionaryStore or given to zstd.WithDecoderDicts and zstd.WithEncoderDict.
+// id is the dictionary ID written to the frames compressed with it, a random one is chosen if it is 0.
+// maxSize <= 0 means 112KB, the default of the zstd command.
+func TrainDictionary(samples [][]byte, id uint32, maxSize int) (dict []byte, err error) {
+	defer func() {
+		// the trainer panics if samples have too few sequences to build the entropy tables.
+		if recover() != nil {
+			dict, err = nil, errors.New("contentencoding: samples are too small to train a dictionary")
+		}
+	}()
+	if maxSize <= 0 {
+		maxSize = 112 << 10
+	}
+	return zstddict.BuildZstdDict(samples, zstddict.Options{
+		MaxDictSize:    maxSize,
+		HashBytes:      6,
+		ZstdDictID:     id,
+		ZstdDictCompat: true,
+	})
+}
+
+// WithDictionaryStore returns a Option to decode zstd bodies compressed with a dictionary,
+// which is looked up from store by the dictionary ID in the frame header.
+// Requests with an unknown dictionary are handled as decode errors.
+func WithDictionaryStore(store DictionaryStore) Option {
+	return func(cfg *config) {
+		cfg.dictionaries = store
+	}
+}
+
+// zstdFrameHeaderMaxSize is the maximum size of a zstd frame header.
+const zstdFrameHeaderMaxSize = 18
+
+// zstdDictReader returns a zstd reader of r with the dictionary of the first frame if it has one.
+// If the frame has no dictionary, rc is nil and next must be decoded instead of r.
+func (cfg *config) zstdDictReader(r io.Reader) (rc io.ReadCloser, next io.Reader, err error) {
+	buf := bufio.NewReaderSize(r, zstdFrameHeaderMaxSize)
+	b, _ := buf.Peek(zstdFrameHeaderMaxSize)
+	var h zstd.Header
+	if h.Decode(b) != nil || h.DictionaryID == 0 {
+		return nil, buf, nil
+	}
+	dict, err := cfg.dictionaries.Dictionary(h.DictionaryID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("contentencoding: zstd dictionary %d: %w", h.DictionaryID, err)
+	}
+	dopts := append(append([]zstd.DOption{}, cfg.zstdOptions()...), zstd.WithDecoderDicts(dict))
+	zr, err := zstd.NewReader(buf, dopts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zr.IOReadCloser(), nil, nil
+}
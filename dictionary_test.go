@@ -0,0 +1,142 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func compressWithDict(t *testing.T, dict, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf, zstd.WithEncoderDict(dict))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewFSDictionaryStore(t *testing.T) {
+	dict, err := os.ReadFile("testdata/dict.zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := contentencoding.NewFSDictionaryStore(os.DirFS("testdata"), "*.zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Dictionary(42); err != nil || !bytes.Equal(got, dict) {
+		t.Errorf("dictionary 42 should be found but got %d bytes, %v", len(got), err)
+	}
+	if got, err := store.DictionaryByHash(sha256.Sum256(dict)); err != nil || !bytes.Equal(got, dict) {
+		t.Errorf("dictionary by hash should be found but got %d bytes, %v", len(got), err)
+	}
+	if _, err := store.Dictionary(1); !errors.Is(err, contentencoding.ErrDictionaryNotFound) {
+		t.Errorf("error should be ErrDictionaryNotFound but got %v", err)
+	}
+
+	raw := []byte("raw content dictionary")
+	store = contentencoding.NewMemoryDictionaryStore(raw)
+	if got, err := store.DictionaryByHash(sha256.Sum256(raw)); err != nil || !bytes.Equal(got, raw) {
+		t.Errorf("raw dictionary by hash should be found but got %q, %v", got, err)
+	}
+}
+
+func TestWithDictionaryStore(t *testing.T) {
+	dict, err := os.ReadFile("testdata/dict.zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte(`{"id":1000,"user":"user-7000","event":"request","status":"ok","tags":["alpha","beta","gamma"]}`)
+	plain, err := contentencoding.EncodeBytes("zstd", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		store  contentencoding.DictionaryStore
+		body   []byte
+		status int
+	}{
+		{"dictionary", contentencoding.NewMemoryDictionaryStore(dict), compressWithDict(t, dict, want), http.StatusOK},
+		{"no dictionary", contentencoding.NewMemoryDictionaryStore(dict), plain, http.StatusOK},
+		{"unknown dictionary", contentencoding.NewMemoryDictionaryStore(), compressWithDict(t, dict, want), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithDictionaryStore(tt.store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("body should be %s but got %s", want, got)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", "zstd")
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status code should be %d but got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTrainDictionary(t *testing.T) {
+	var samples [][]byte
+	for i := 0; i < 200; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"id":%d,"user":"user-%d","event":"request","status":"ok"}`, i, i*7)))
+	}
+	dict, err := contentencoding.TrainDictionary(samples, 100, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dict) > 2048+1024 {
+		t.Errorf("dictionary should be about 2048 bytes but got %d", len(dict))
+	}
+	store := contentencoding.NewMemoryDictionaryStore(dict)
+	if _, err := store.Dictionary(100); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte(`{"id":1000,"user":"user-7000","event":"request","status":"ok"}`)
+	b, err := contentencoding.DecodeBytes("zstd", compressWithDict(t, dict, want), contentencoding.Limits{})
+	if err == nil {
+		t.Errorf("decoding without dictionary should be error but got %s", b)
+	}
+	h := contentencoding.Decode(contentencoding.WithDictionaryStore(store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("body should be %s but got %s", want, got)
+		}
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(compressWithDict(t, dict, want)))
+	req.Header.Set("Content-Encoding", "zstd")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status code should be %d but got %d", http.StatusOK, rec.Code)
+	}
+}
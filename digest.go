@@ -0,0 +1,145 @@
+package contentencoding
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+)
+
+// DigestAlgorithm is an algorithm of digests computed by WithDigests.
+type DigestAlgorithm int
+
+const (
+	// CRC32C is CRC-32 with the Castagnoli polynomial, as used by Google Cloud Storage.
+	CRC32C DigestAlgorithm = iota + 1
+	// SHA256 is SHA-256.
+	SHA256
+)
+
+func (a DigestAlgorithm) String() string {
+	switch a {
+	case CRC32C:
+		return "crc32c"
+	case SHA256:
+		return "sha-256"
+	}
+	return "unknown"
+}
+
+func (a DigestAlgorithm) new() hash.Hash {
+	switch a {
+	case CRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case SHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// WithDigests returns a Option to compute digests of the request body with algs while it is read,
+// so that upload handlers can record the integrity of the payload without a second pass.
+// The digests of the decoded body and of the encoded body as received are both computed,
+// they are the same for requests without decoded codings.
+// They are returned by DigestsFromRequest, it applies to Decode and Middleware.
+func WithDigests(algs ...DigestAlgorithm) Option {
+	algs = append([]DigestAlgorithm(nil), algs...)
+	return func(cfg *config) {
+		cfg.digests = algs
+	}
+}
+
+// Digests are the digests of a request body computed by WithDigests.
+// They cover the bytes read so far, so they are the digests of the whole body after it is read to the end.
+// They must not be used concurrently with reads of the body.
+type Digests struct {
+	encoded, decoded map[DigestAlgorithm]hash.Hash
+}
+
+type digestsKey struct{}
+
+// DigestsFromRequest returns the Digests of r, or nil if they are not computed.
+func DigestsFromRequest(r *http.Request) *Digests {
+	d, _ := r.Context().Value(digestsKey{}).(*Digests)
+	return d
+}
+
+// Decoded returns the digest of the decoded body with alg, or nil if alg is not computed.
+func (d *Digests) Decoded(alg DigestAlgorithm) []byte {
+	if h, ok := d.decoded[alg]; ok {
+		return h.Sum(nil)
+	}
+	return nil
+}
+
+// Encoded returns the digest of the encoded body with alg, or nil if alg is not computed.
+func (d *Digests) Encoded(alg DigestAlgorithm) []byte {
+	if h, ok := d.encoded[alg]; ok {
+		return h.Sum(nil)
+	}
+	return nil
+}
+
+// newDigests returns Digests to compute if WithDigests is used, otherwise nil.
+func (cfg *config) newDigests() *Digests {
+	if len(cfg.digests) == 0 {
+		return nil
+	}
+	return &Digests{encoded: newHashes(cfg.digests), decoded: newHashes(cfg.digests)}
+}
+
+// hashEncoded returns body that computes the digests of the encoded body.
+func (d *Digests) hashEncoded(body io.ReadCloser) io.ReadCloser {
+	if d == nil {
+		return body
+	}
+	return &hashingBody{ReadCloser: body, hashes: d.encoded}
+}
+
+// hashDecoded returns body that computes the digests of the decoded body.
+func (d *Digests) hashDecoded(body io.ReadCloser) io.ReadCloser {
+	if d == nil {
+		return body
+	}
+	return &hashingBody{ReadCloser: body, hashes: d.decoded}
+}
+
+// identity makes the digests of the decoded body those of the encoded body, for bodies without decoded codings.
+func (d *Digests) identity() {
+	if d != nil {
+		d.decoded = d.encoded
+	}
+}
+
+// request returns r with d in its context.
+func (d *Digests) request(r *http.Request) *http.Request {
+	if d == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), digestsKey{}, d))
+}
+
+func newHashes(algs []DigestAlgorithm) map[DigestAlgorithm]hash.Hash {
+	hashes := make(map[DigestAlgorithm]hash.Hash, len(algs))
+	for _, a := range algs {
+		if h := a.new(); h != nil {
+			hashes[a] = h
+		}
+	}
+	return hashes
+}
+
+type hashingBody struct {
+	io.ReadCloser
+	hashes map[DigestAlgorithm]hash.Hash
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	for _, h := range b.hashes {
+		h.Write(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithDigests(t *testing.T) {
+	payload := []byte("digest test")
+	crc := func(b []byte) []byte {
+		sum := make([]byte, 4)
+		binary.BigEndian.PutUint32(sum, crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)))
+		return sum
+	}
+	sha := func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:]
+	}
+	tests := []struct {
+		name     string
+		encoding string
+	}{
+		{"gzip", "gzip"},
+		{"identity", "identity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", payload, tt.encoding)
+			encoded, err := contentencodingtest.CompressBody(payload, tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var d *contentencoding.Digests
+			h := contentencoding.Decode(contentencoding.WithDigests(contentencoding.CRC32C, contentencoding.SHA256))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := ioutil.ReadAll(r.Body); err != nil {
+					t.Fatal(err)
+				}
+				d = contentencoding.DigestsFromRequest(r)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if d == nil {
+				t.Fatal("digests should be found")
+			}
+			for _, c := range []struct {
+				name      string
+				got, want []byte
+			}{
+				{"decoded crc32c", d.Decoded(contentencoding.CRC32C), crc(payload)},
+				{"decoded sha-256", d.Decoded(contentencoding.SHA256), sha(payload)},
+				{"encoded crc32c", d.Encoded(contentencoding.CRC32C), crc(encoded)},
+				{"encoded sha-256", d.Encoded(contentencoding.SHA256), sha(encoded)},
+			} {
+				if !bytes.Equal(c.got, c.want) {
+					t.Errorf("%s should be %x but got %x", c.name, c.want, c.got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package contentencoding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Encode returns net/http compatible middleware that compresses responses with the coding negotiated
+// with Accept-Encoding among WithEncodingPreference, br, zstd and gzip by default, as the counterpart of Decode.
+// Only complete successful responses are encoded, and responses that already have Content-Encoding,
+// gRPC and gRPC-web responses and responses to requests with Range are left as they are.
+// Vary: Accept-Encoding is added to the responses when they are written, after the Vary of the handler,
+// unless WithoutVary is used. Responses that are not negotiated to be encoded are written to w as it is,
+// with Vary added before the handler is called, so handlers should add to Vary rather than set it.
+// The coding is the one with the highest q-value in Accept-Encoding, and ties are broken by the preference.
+// The handler may call ResponseEncodingFromRequest, SetSizeHint and SetCompressionHint with the request it receives.
+// Options other than WithEncodingPreference, WithCapabilityOverride, WithAssumedAcceptEncoding, WithEncoderPool,
+// WithoutVary, WithMinSize, WithCompressContentTypes, WithSkipContentTypes, compression levels and encoder options are ignored.
+func Encode(opts ...Option) func(next http.Handler) http.Handler {
+	cfg := newConfig(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			coding, ok := cfg.negotiateRequest(r, cfg.preference)
+			if !ok || r.Header.Get("Range") != "" {
+				coding = "identity"
+			}
+			r = r.WithContext(context.WithValue(r.Context(), responseEncodingKey{}, coding))
+			if coding == "identity" {
+				cfg.varyAcceptEncoding(w.Header())
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := cfg.newCompressWriter(w, r, coding)
+			defer cw.Close()
+			next.ServeHTTP(cw, cw.req)
+		})
+	}
+}
+
+// WithoutVary returns a Option not to add Vary: Accept-Encoding to the responses of Encode, ServeContent,
+// TranscodeResponse and the br and zstd responses of Gzhttp, for APIs behind caches whose keys
+// already include the negotiated coding. Vary set by handlers is kept as it is.
+func WithoutVary() Option {
+	return func(cfg *config) {
+		cfg.noVary = true
+	}
+}
+
+// WithMinSize returns a Option to send responses smaller than n bytes as they are, since compressing them wastes CPU
+// and often makes them larger. Responses whose size is unknown, without SetSizeHint or Content-Length,
+// are held until n bytes are written, and they are compressed if the handler writes more or flushes.
+// It applies to Encode, ServeContent and the br and zstd responses of Gzhttp. It panics if n is negative.
+func WithMinSize(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("contentencoding: invalid minimum size %d", n))
+	}
+	return func(cfg *config) {
+		cfg.minSize = n
+	}
+}
+
+// varyAcceptEncoding adds Accept-Encoding to the Vary of h unless WithoutVary is used.
+func (cfg *config) varyAcceptEncoding(h http.Header) {
+	if !cfg.noVary {
+		addVary(h, "Accept-Encoding")
+	}
+}
+
+type responseEncodingKey struct{}
+
+// ResponseEncodingFromRequest returns the coding negotiated by Encode for the response to r, e.g. br,
+// or identity if the response is not encoded. It returns an empty string if r was not passed by Encode.
+// A negotiated response is still sent as it is if the handler responds an error or sets Content-Encoding.
+func ResponseEncodingFromRequest(r *http.Request) string {
+	coding, _ := r.Context().Value(responseEncodingKey{}).(string)
+	return coding
+}
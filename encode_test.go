@@ -0,0 +1,377 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestEncode(t *testing.T) {
+	payload := bytes.Repeat([]byte("encode test "), 1000)
+	h := contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/encoded" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		if r.URL.Path == "/notfound" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write(payload)
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		want   string
+	}{
+		{"br", "/", http.Header{"Accept-Encoding": {"br"}}, "br"},
+		{"gzip", "/", http.Header{"Accept-Encoding": {"gzip"}}, "gzip"},
+		{"zstd", "/", http.Header{"Accept-Encoding": {"gzip;q=0.5, zstd"}}, "zstd"},
+		{"preference", "/", http.Header{"Accept-Encoding": {"gzip, zstd, br"}}, "br"},
+		{"identity", "/", http.Header{"Accept-Encoding": {"identity"}}, ""},
+		{"no Accept-Encoding", "/", http.Header{}, ""},
+		{"Range", "/", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}}, ""},
+		{"error", "/notfound", http.Header{"Accept-Encoding": {"gzip"}}, ""},
+		{"already encoded", "/encoded", http.Header{"Accept-Encoding": {"br"}}, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header = tt.header
+			h.ServeHTTP(rec, req)
+			if ce := rec.Header().Get("Content-Encoding"); ce != tt.want {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.want, ce)
+			}
+			if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Vary should be Accept-Encoding but got %q", vary)
+			}
+			if tt.path == "/encoded" {
+				return
+			}
+			rc, err := contentencoding.NewReader(rec.Body, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, payload) {
+				t.Error("decoded body is wrong")
+			}
+		})
+	}
+}
+
+func TestResponseEncodingFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"q-values", "gzip;q=0.5, br;q=1.0", "br"},
+		{"highest q-value first", "gzip;q=1, br;q=0.5", "gzip"},
+		{"ties by preference", "gzip, zstd", "zstd"},
+		{"wildcard", "br;q=0, *", "zstd"},
+		{"unsupported", "compress", "identity"},
+		{"missing", "", "identity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = contentencoding.ResponseEncodingFromRequest(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("should be %q but got %q", tt.want, got)
+			}
+		})
+	}
+	if got := contentencoding.ResponseEncodingFromRequest(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+		t.Errorf("should be empty but got %q", got)
+	}
+}
+
+func TestEncode_Vary(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []contentencoding.Option
+		accept string
+		vary   []string
+		empty  bool
+		want   []string
+	}{
+		{"added", nil, "gzip", nil, false, []string{"Accept-Encoding"}},
+		{"identity", nil, "", nil, false, []string{"Accept-Encoding"}},
+		{"identity added by handler", nil, "", []string{"Origin"}, false, []string{"Accept-Encoding", "Origin"}},
+		{"empty response", nil, "gzip", nil, true, []string{"Accept-Encoding"}},
+		{"appended to handler", nil, "gzip", []string{"Origin"}, false, []string{"Origin", "Accept-Encoding"}},
+		{"no duplicate", nil, "br", []string{"Origin, accept-encoding"}, false, []string{"Origin, accept-encoding"}},
+		{"wildcard", nil, "br", []string{"*"}, false, []string{"*"}},
+		{"without vary", []contentencoding.Option{contentencoding.WithoutVary()}, "gzip", []string{"Origin"}, false, []string{"Origin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Encode(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, v := range tt.vary {
+					w.Header().Add("Vary", v)
+				}
+				if !tt.empty {
+					w.Write([]byte("vary test"))
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Values("Vary"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Vary should be %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEncode_emptyBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		writeHeader bool
+		want        string
+	}{
+		{"nothing written", false, ""},
+		{"header written", true, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.writeHeader {
+					w.WriteHeader(http.StatusOK)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding should be %q but got %q", tt.want, got)
+			}
+			if tt.want == "" {
+				if rec.Body.Len() != 0 {
+					t.Errorf("body should be empty but got %q", rec.Body.String())
+				}
+				return
+			}
+			body, err := contentencoding.DecodeBody("gzip", rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b) != 0 {
+				t.Errorf("decoded body should be empty but got %q", b)
+			}
+		})
+	}
+}
+
+func TestEncode_Hijack(t *testing.T) {
+	for _, accept := range []string{"identity", "gzip"} {
+		t.Run(accept, func(t *testing.T) {
+			var original http.ResponseWriter
+			h := contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if accept == "identity" && w != original {
+					t.Error("identity response should be written to the original writer")
+				}
+				conn, rw, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+				rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 6\r\nConnection: close\r\n\r\nhijack")
+				rw.Flush()
+			}))
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				original = w
+				h.ServeHTTP(w, r)
+			}))
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Encoding", accept)
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "hijack" || resp.Header.Get("Content-Encoding") != "" {
+				t.Errorf("response should be hijack without Content-Encoding but got %q %q", b, resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestWithMinSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		length bool
+		flush  bool
+		want   string
+	}{
+		{"small", 100, false, false, ""},
+		{"large", 2000, false, false, "gzip"},
+		{"just over", 1030, false, false, "gzip"},
+		{"small with Content-Length", 100, true, false, ""},
+		{"large with Content-Length", 2000, true, false, "gzip"},
+		{"flushed", 100, false, true, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := bytes.Repeat([]byte("a"), tt.size)
+			h := contentencoding.Encode(contentencoding.WithMinSize(1024))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.length {
+					w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+				}
+				for i := 0; i < len(payload); i += 10 {
+					w.Write(payload[i : i+10])
+				}
+				if tt.flush {
+					w.(http.Flusher).Flush()
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			h.ServeHTTP(rec, req)
+			if ce := rec.Header().Get("Content-Encoding"); ce != tt.want {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.want, ce)
+			}
+			if cl := rec.Header().Get("Content-Length"); tt.want == "" && cl != strconv.Itoa(tt.size) {
+				t.Errorf("Content-Length should be %d but got %q", tt.size, cl)
+			}
+			rc, err := contentencoding.NewReader(rec.Body, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, payload) {
+				t.Error("decoded body is wrong")
+			}
+		})
+	}
+}
+
+func TestEncode_ContentTypes(t *testing.T) {
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), bytes.Repeat([]byte{0}, 100)...)
+	opts := []contentencoding.Option{
+		contentencoding.WithCompressContentTypes("text/*", "application/json", "image/*"),
+		contentencoding.WithSkipContentTypes("image/png", "image/jpeg"),
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		writeHeader bool
+		want        string
+	}{
+		{"allowed", "text/html; charset=utf-8", []byte("<p>test</p>"), false, "gzip"},
+		{"allowed case-insensitive", "Application/JSON", []byte(`{}`), false, "gzip"},
+		{"not allowed", "application/zip", []byte("PK"), false, ""},
+		{"skipped", "image/jpeg", []byte("jpeg"), false, ""},
+		{"allowed but not skipped", "image/svg+xml", []byte("<svg/>"), false, "gzip"},
+		{"sniffed", "", []byte("<html><p>test</p></html>"), false, "gzip"},
+		{"sniffed skipped", "", png, false, ""},
+		{"sniffed after WriteHeader", "", png, true, ""},
+		{"sniffed text after WriteHeader", "", []byte("plain text"), true, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Encode(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.writeHeader {
+					w.WriteHeader(http.StatusOK)
+				}
+				w.Write(tt.body)
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			h.ServeHTTP(rec, req)
+			if ce := rec.Header().Get("Content-Encoding"); ce != tt.want {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.want, ce)
+			}
+			if tt.contentType == "" && rec.Header().Get("Content-Type") != http.DetectContentType(tt.body) {
+				t.Errorf("Content-Type should be sniffed but got %q", rec.Header().Get("Content-Type"))
+			}
+			rc, err := contentencoding.NewReader(rec.Body, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, tt.body) {
+				t.Error("decoded body is wrong")
+			}
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, size := range []int{4 << 10, 256 << 10} {
+		content := bytes.Repeat([]byte("<p>encode benchmark</p>"), size/23)
+		h := contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content)
+		}))
+		for _, encoding := range []string{"br", "gzip", "zstd"} {
+			b.Run(fmt.Sprintf("%s/%d", encoding, size), func(b *testing.B) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept-Encoding", encoding)
+				b.ReportAllocs()
+				b.SetBytes(int64(len(content)))
+				for i := 0; i < b.N; i++ {
+					h.ServeHTTP(httptest.NewRecorder(), req)
+				}
+			})
+		}
+	}
+}
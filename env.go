@@ -0,0 +1,85 @@
+package contentencoding
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromEnv returns Options configured by the following environment variables, which are ignored if empty.
+//
+//	CONTENTENCODING_ENCODINGS          comma-separated built-in codings to decode, see WithEncodings
+//	CONTENTENCODING_GZIP_LEVEL         gzip compression level, -2 to 9
+//	CONTENTENCODING_BROTLI_QUALITY     brotli quality, 0 to 11
+//	CONTENTENCODING_ZSTD_LEVEL         zstd compression level, 1 to 22 as the zstd command
+//	CONTENTENCODING_MAX_DECODED_BYTES  Limits.MaxDecodedBytes
+//	CONTENTENCODING_MAX_RATIO          Limits.MaxRatio
+//	CONTENTENCODING_MAX_LAYERS         Limits.MaxLayers
+//	CONTENTENCODING_MAX_DECODER_MEMORY Limits.MaxDecoderMemory
+//
+// Compression levels apply to the encoders of ServeContent, Transcode and TranscodeResponse, see WithGzipLevel.
+// It returns an error naming the variable if a value is invalid.
+// Options given after the returned ones override them, e.g. Decode(append(opts, WithStrict())...).
+func FromEnv() ([]Option, error) {
+	var opts []Option
+	if v := os.Getenv("CONTENTENCODING_ENCODINGS"); v != "" {
+		opts = append(opts, WithEncodings(strings.Split(v, ",")...))
+	}
+
+	for _, e := range []struct {
+		key, encoding string
+		min, max      int
+	}{
+		{"CONTENTENCODING_GZIP_LEVEL", "gzip", -2, 9},
+		{"CONTENTENCODING_BROTLI_QUALITY", "br", 0, 11},
+		{"CONTENTENCODING_ZSTD_LEVEL", "zstd", 1, 22},
+	} {
+		v := os.Getenv(e.key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < e.min || n > e.max {
+			return nil, fmt.Errorf("contentencoding: %s should be an integer from %d to %d but got %q", e.key, e.min, e.max, v)
+		}
+		encoding := e.encoding
+		opts = append(opts, func(cfg *config) {
+			cfg.setLevel(encoding, n)
+		})
+	}
+
+	var limits Limits
+	if v := os.Getenv("CONTENTENCODING_MAX_DECODED_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("contentencoding: CONTENTENCODING_MAX_DECODED_BYTES should be a non-negative integer but got %q", v)
+		}
+		limits.MaxDecodedBytes = n
+	}
+	if v := os.Getenv("CONTENTENCODING_MAX_RATIO"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("contentencoding: CONTENTENCODING_MAX_RATIO should be a non-negative number but got %q", v)
+		}
+		limits.MaxRatio = f
+	}
+	if v := os.Getenv("CONTENTENCODING_MAX_LAYERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("contentencoding: CONTENTENCODING_MAX_LAYERS should be a non-negative integer but got %q", v)
+		}
+		limits.MaxLayers = n
+	}
+	if v := os.Getenv("CONTENTENCODING_MAX_DECODER_MEMORY"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("contentencoding: CONTENTENCODING_MAX_DECODER_MEMORY should be a non-negative integer but got %q", v)
+		}
+		limits.MaxDecoderMemory = n
+	}
+	if limits != (Limits{}) {
+		opts = append(opts, WithLimits(limits))
+	}
+	return opts, nil
+}
@@ -0,0 +1,90 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("CONTENTENCODING_ENCODINGS", "gzip, zstd")
+	t.Setenv("CONTENTENCODING_MAX_DECODED_BYTES", "8")
+	opts, err := contentencoding.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := contentencoding.Decode(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+		w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+	}))
+
+	tests := []struct {
+		name     string
+		body     string
+		encoding string
+		want     int
+	}{
+		{"enabled", "test", "gzip", http.StatusOK},
+		{"limit", "test-test-test", "zstd", http.StatusRequestEntityTooLarge},
+		{"disabled", "test", "br", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte(tt.body), tt.encoding))
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "br"))
+	if got := rec.Header().Get("X-Content-Encoding"); got != "br" {
+		t.Errorf("disabled br should be left but got %q", got)
+	}
+}
+
+func TestFromEnv_level(t *testing.T) {
+	t.Setenv("CONTENTENCODING_GZIP_LEVEL", "0")
+	opts, err := contentencoding.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Repeat([]byte("a"), 10000)
+	h := contentencoding.Transcode("gzip", opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) <= len(want) {
+			t.Errorf("gzip level 0 should store the body but got %d bytes", len(b))
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", want, "zstd"))
+}
+
+func TestFromEnv_invalid(t *testing.T) {
+	for _, kv := range [][2]string{
+		{"CONTENTENCODING_GZIP_LEVEL", "10"},
+		{"CONTENTENCODING_BROTLI_QUALITY", "high"},
+		{"CONTENTENCODING_ZSTD_LEVEL", "0"},
+		{"CONTENTENCODING_MAX_DECODED_BYTES", "-1"},
+		{"CONTENTENCODING_MAX_RATIO", "x"},
+		{"CONTENTENCODING_MAX_LAYERS", "1.5"},
+	} {
+		t.Run(kv[0], func(t *testing.T) {
+			t.Setenv(kv[0], kv[1])
+			if _, err := contentencoding.FromEnv(); err == nil {
+				t.Errorf("%s=%s should be error", kv[0], kv[1])
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package contentencoding_test
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -28,13 +29,8 @@ func ExampleDecode() {
 func ExampleWithDecoder() {
 	customDecoder := &contentencoding.Decoder{
 		Encoding: "custom",
-		Handler: func(w http.ResponseWriter, r *http.Request) error {
-			b, err := ioutil.ReadAll(r.Body)
-			if err != nil {
-				return err
-			}
-			r.Body = ioutil.NopCloser(strings.NewReader(string(b) + "-custom"))
-			return nil
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(io.MultiReader(r, strings.NewReader("-custom"))), nil
 		},
 	}
 	mux := http.NewServeMux()
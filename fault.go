@@ -0,0 +1,87 @@
+package contentencoding
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ErrInjectedFault is the error injected by WithFaultInjection.
+// DefaultErrorHandler responds 400 Bad Request for it as for corrupted bodies.
+var ErrInjectedFault = errors.New("contentencoding: injected fault")
+
+// Faults are the failures injected by WithFaultInjection, each rate is a probability from 0 to 1.
+type Faults struct {
+	// ErrorRate is the rate of requests whose decoding fails with ErrInjectedFault before the handler is called.
+	ErrorRate float64
+	// ReadErrorRate is the rate of requests whose decoded body fails with ErrInjectedFault
+	// after the first read, as a body corrupted in the middle does.
+	ReadErrorRate float64
+	// DelayRate is the rate of requests whose decoding is delayed by Delay.
+	DelayRate float64
+	// Delay is the delay of decoding, the request fails with the error of its context if it is canceled first.
+	Delay time.Duration
+	// Rand returns a pseudo-random number in [0, 1). If nil, math/rand.Float64 is used.
+	Rand func() float64
+}
+
+// WithFaultInjection returns a Option to inject failures and delays into decoding encoded requests at random,
+// so that error handlers, retries of clients and alerts can be tested against failures of decoding.
+// It must only be used in tests and chaos experiments. It applies to Decode and Middleware.
+func WithFaultInjection(f Faults) Option {
+	if f.Rand == nil {
+		f.Rand = rand.Float64
+	}
+	return func(cfg *config) {
+		cfg.faults = &f
+	}
+}
+
+// injectFault delays decoding r and returns the error to inject by WithFaultInjection.
+func (cfg *config) injectFault(r *http.Request) error {
+	f := cfg.faults
+	if f == nil || !isEncoded(r) {
+		return nil
+	}
+	if f.DelayRate > 0 && f.Rand() < f.DelayRate {
+		t := time.NewTimer(f.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+	if f.ErrorRate > 0 && f.Rand() < f.ErrorRate {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// faultBody returns body that fails after the first read if a read error is injected.
+func (cfg *config) faultBody(body io.ReadCloser) io.ReadCloser {
+	f := cfg.faults
+	if f == nil || f.ReadErrorRate <= 0 || f.Rand() >= f.ReadErrorRate {
+		return body
+	}
+	return &faultyBody{ReadCloser: body}
+}
+
+type faultyBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *faultyBody) Read(p []byte) (int, error) {
+	if b.read {
+		return 0, ErrInjectedFault
+	}
+	b.read = true
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		err = ErrInjectedFault
+	}
+	return n, err
+}
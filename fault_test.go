@@ -0,0 +1,63 @@
+package contentencoding_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithFaultInjection(t *testing.T) {
+	tests := []struct {
+		name      string
+		faults    contentencoding.Faults
+		want      int
+		called    bool
+		wantDelay time.Duration
+	}{
+		{"none", contentencoding.Faults{}, http.StatusOK, true, 0},
+		{"error", contentencoding.Faults{ErrorRate: 1}, http.StatusBadRequest, false, 0},
+		{"read error", contentencoding.Faults{ReadErrorRate: 1}, http.StatusInternalServerError, true, 0},
+		{"delay", contentencoding.Faults{DelayRate: 1, Delay: 20 * time.Millisecond}, http.StatusOK, true, 20 * time.Millisecond},
+		{"not chosen", contentencoding.Faults{ErrorRate: 0.5, Rand: func() float64 { return 0.5 }}, http.StatusOK, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := contentencoding.Decode(contentencoding.WithFaultInjection(tt.faults))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if _, err := ioutil.ReadAll(r.Body); errors.Is(err, contentencoding.ErrInjectedFault) {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			h.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "gzip"))
+			if d := time.Since(start); d < tt.wantDelay {
+				t.Errorf("should be delayed by %v but got %v", tt.wantDelay, d)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+			if called != tt.called {
+				t.Errorf("handler called should be %v but got %v", tt.called, called)
+			}
+		})
+	}
+
+	t.Run("identity", func(t *testing.T) {
+		h := contentencoding.Decode(contentencoding.WithFaultInjection(contentencoding.Faults{ErrorRate: 1}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test")))
+		if rec.Code != http.StatusOK {
+			t.Errorf("status code should be %d but got %d", http.StatusOK, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,3 @@
+package contentencoding
+
+//go:generate go run ./internal/genfixtures testdata/generated testdata/test.txt
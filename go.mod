@@ -4,5 +4,5 @@ go 1.18
 
 require (
 	github.com/andybalholm/brotli v1.0.4
-	github.com/klauspost/compress v1.15.9
+	github.com/klauspost/compress v1.17.0
 )
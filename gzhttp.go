@@ -0,0 +1,36 @@
+package contentencoding
+
+import (
+	"net/http"
+
+	"github.com/klauspost/compress/gzhttp"
+)
+
+// Gzhttp returns net/http compatible middleware that adds br and zstd to the gzip compression of gzhttp,
+// so that servers already using gzhttp don't need another compression middleware.
+// The coding is negotiated with Accept-Encoding among WithEncodingPreference as ServeContent does.
+// Responses of br and zstd are compressed by this package, and the others are left to wrapper,
+// e.g. the result of gzhttp.NewWrapper, which decides whether to use gzip by its own options.
+// If wrapper is nil, gzhttp.GzipHandler is used.
+// Options other than WithEncodingPreference, WithCapabilityOverride, WithAssumedAcceptEncoding, WithoutVary,
+// WithMinSize, WithCompressContentTypes, WithSkipContentTypes, compression levels and encoder options are ignored.
+func Gzhttp(wrapper func(http.Handler) http.HandlerFunc, opts ...Option) func(next http.Handler) http.Handler {
+	if wrapper == nil {
+		wrapper = gzhttp.GzipHandler
+	}
+	cfg := newConfig(opts)
+
+	return func(next http.Handler) http.Handler {
+		gz := wrapper(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			coding, ok := cfg.negotiateRequest(r, cfg.preference)
+			if !ok || (coding != "br" && coding != "zstd") || r.Header.Get("Range") != "" {
+				gz.ServeHTTP(w, r)
+				return
+			}
+			cw := cfg.newCompressWriter(w, r, coding)
+			defer cw.Close()
+			next.ServeHTTP(cw, cw.req)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/gzhttp"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestGzhttp(t *testing.T) {
+	payload := bytes.Repeat([]byte("gzhttp test "), 1000)
+	wrapper, err := gzhttp.NewWrapper(gzhttp.MinSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := contentencoding.Gzhttp(wrapper)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(payload)
+	}))
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"br", "br", "br"},
+		{"zstd", "gzip;q=0.5, zstd", "zstd"},
+		{"gzip", "gzip", "gzip"},
+		{"preference", "gzip, br, zstd", "br"},
+		{"identity", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			h.ServeHTTP(rec, req)
+			if ce := rec.Header().Get("Content-Encoding"); ce != tt.want {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.want, ce)
+			}
+			if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Vary should be Accept-Encoding but got %q", vary)
+			}
+			rc, err := contentencoding.NewReader(rec.Body, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, payload) {
+				t.Error("decoded body is wrong")
+			}
+		})
+	}
+}
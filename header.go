@@ -0,0 +1,310 @@
+package contentencoding
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EncodingValue is an element of Accept-Encoding or Content-Encoding.
+type EncodingValue struct {
+	// Coding is the content coding, "identity" or "*".
+	Coding string
+	// Weight is the q-value of Accept-Encoding, 1 if it is absent.
+	// It is always 1 for Content-Encoding.
+	Weight float64
+	// Params are the parameters other than q with lower-cased names.
+	Params map[string]string
+}
+
+// CanonicalCoding returns coding lower-cased, with the aliases x-gzip and x-compress replaced by gzip and compress.
+// Codings parsed by this package are always canonical, so decoders, strict mode, statistics
+// and negotiation see one token for each coding.
+func CanonicalCoding(coding string) string {
+	coding = strings.ToLower(strings.TrimSpace(coding))
+	switch coding {
+	case "x-gzip":
+		return "gzip"
+	case "x-compress":
+		return "compress"
+	}
+	return coding
+}
+
+// ParseAcceptEncoding parses the value of Accept-Encoding.
+// Codings are canonicalized by CanonicalCoding and empty list elements are skipped.
+// On a malformed element it returns an error, and values still holds the well-formed elements.
+func ParseAcceptEncoding(s string) ([]EncodingValue, error) {
+	return parseEncodingList(s, true)
+}
+
+// ParseContentEncoding parses the value of Content-Encoding.
+// Codings are canonicalized by CanonicalCoding and returned in the order they appear, so the last one is applied last.
+// Empty list elements are skipped, and repeated identity codings are reduced to the first one.
+// On a malformed element it returns an error, and values still holds the well-formed elements.
+func ParseContentEncoding(s string) ([]EncodingValue, error) {
+	values, err := parseEncodingList(s, false)
+	identity := false
+	n := 0
+	for _, v := range values {
+		if v.Coding == "identity" {
+			if identity {
+				continue
+			}
+			identity = true
+		}
+		values[n] = v
+		n++
+	}
+	return values[:n], err
+}
+
+// FormatAcceptEncoding formats values as the value of Accept-Encoding, in the given order.
+// q is omitted for weight 1, and weights are clamped to [0, 1] with three decimal places.
+// Parameters are written in the order of their names and quoted when needed.
+func FormatAcceptEncoding(values ...EncodingValue) string {
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(v.Coding)
+		keys := make([]string, 0, len(v.Params))
+		for k := range v.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteByte(';')
+			b.WriteString(k)
+			b.WriteByte('=')
+			if val := v.Params[k]; isToken(val) {
+				b.WriteString(val)
+			} else {
+				b.WriteString(quote(val))
+			}
+		}
+		if q := formatQValue(v.Weight); q != "1" {
+			b.WriteString(";q=")
+			b.WriteString(q)
+		}
+	}
+	return b.String()
+}
+
+func formatQValue(q float64) string {
+	switch {
+	case q >= 1 || q != q: // NaN
+		return "1"
+	case q <= 0:
+		return "0"
+	}
+	s := strconv.FormatFloat(q, 'f', 3, 64)
+	s = strings.TrimRight(s, "0")
+	if s == "0." {
+		// the weight is rounded to zero, but it should stay acceptable.
+		return "0.001"
+	}
+	return s
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func parseEncodingList(s string, accept bool) ([]EncodingValue, error) {
+	var (
+		values   []EncodingValue
+		firstErr error
+	)
+	for _, elem := range splitQuoted(s, ',') {
+		if strings.TrimSpace(elem) == "" {
+			continue
+		}
+		v, err := parseEncodingElement(elem, accept)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		values = append(values, v)
+	}
+	return values, firstErr
+}
+
+func parseEncodingElement(elem string, accept bool) (EncodingValue, error) {
+	parts := splitQuoted(elem, ';')
+	coding := strings.TrimSpace(parts[0])
+	if !isToken(coding) && !(accept && coding == "*") {
+		return EncodingValue{}, fmt.Errorf("contentencoding: invalid coding %q", coding)
+	}
+	// codings are case-insensitive.
+	v := EncodingValue{Coding: CanonicalCoding(coding), Weight: 1}
+	if len(parts) > 1 && !accept {
+		return EncodingValue{}, fmt.Errorf("contentencoding: unexpected parameter in %q", elem)
+	}
+	for _, param := range parts[1:] {
+		k, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+		k = strings.ToLower(strings.TrimSpace(k))
+		val = strings.TrimSpace(val)
+		if !ok || !isToken(k) {
+			return EncodingValue{}, fmt.Errorf("contentencoding: invalid parameter %q", param)
+		}
+		if k == "q" {
+			q, ok := parseQValue(val)
+			if !ok {
+				return EncodingValue{}, fmt.Errorf("contentencoding: invalid q-value %q", val)
+			}
+			v.Weight = q
+			continue
+		}
+		if strings.HasPrefix(val, `"`) {
+			uq, ok := unquote(val)
+			if !ok {
+				return EncodingValue{}, fmt.Errorf("contentencoding: invalid parameter %q", param)
+			}
+			val = uq
+		} else if !isToken(val) {
+			return EncodingValue{}, fmt.Errorf("contentencoding: invalid parameter %q", param)
+		}
+		if v.Params == nil {
+			v.Params = make(map[string]string)
+		}
+		v.Params[k] = val
+	}
+	return v, nil
+}
+
+// parseQValue parses qvalue = ( "0" [ "." 0*3DIGIT ] ) / ( "1" [ "." 0*3("0") ] ).
+func parseQValue(s string) (float64, bool) {
+	if s == "" || len(s) > 5 || (s[0] != '0' && s[0] != '1') {
+		return 0, false
+	}
+	if len(s) > 1 {
+		if s[1] != '.' {
+			return 0, false
+		}
+		for _, c := range s[2:] {
+			if c < '0' || c > '9' || (s[0] == '1' && c != '0') {
+				return 0, false
+			}
+		}
+	}
+	q, err := strconv.ParseFloat(s, 64)
+	return q, err == nil
+}
+
+// unquote unquotes a quoted-string of RFC 9110.
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		switch c := s[i]; c {
+		case '"':
+			return "", false
+		case '\\':
+			i++
+			if i == len(s)-1 {
+				return "", false
+			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
+
+// splitQuoted splits s by sep outside of quoted-strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts   []string
+		start   int
+		quoted  bool
+		escaped bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// combineFieldLines returns lines combined into one field value with commas as RFC 9110 defines,
+// and obs-fold replaced with a space as RFC 9112 requires of recipients,
+// so that every reader of the header sees the same list rather than only the first line.
+// It returns ErrFieldLines for CR, LF or NUL that are not obs-fold,
+// and for multiple lines or obs-fold if reject is true.
+func combineFieldLines(lines []string, reject bool) (string, error) {
+	if len(lines) > 1 && reject {
+		return "", ErrFieldLines
+	}
+	values := make([]string, len(lines))
+	for i, line := range lines {
+		values[i] = line
+		if !strings.ContainsAny(line, "\r\n\x00") {
+			continue
+		}
+		var b strings.Builder
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			if c == '\r' && j+1 < len(line) && line[j+1] == '\n' {
+				j++
+				c = '\n'
+			}
+			if c == '\n' && j+1 < len(line) && (line[j+1] == ' ' || line[j+1] == '\t') && !reject {
+				b.WriteByte(' ')
+				continue
+			}
+			if c == '\r' || c == '\n' || c == 0 {
+				return "", ErrFieldLines
+			}
+			b.WriteByte(c)
+		}
+		values[i] = b.String()
+	}
+	return strings.Join(values, ", "), nil
+}
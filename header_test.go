@@ -0,0 +1,174 @@
+package contentencoding_test
+
+import (
+	"reflect"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestParseAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []contentencoding.EncodingValue
+		err   bool
+	}{
+		{"empty", "", nil, false},
+		{"single", "gzip", []contentencoding.EncodingValue{{Coding: "gzip", Weight: 1}}, false},
+		{
+			"weights",
+			"GZIP;q=0.5, br ; Q=1.0,*;q=0",
+			[]contentencoding.EncodingValue{
+				{Coding: "gzip", Weight: 0.5},
+				{Coding: "br", Weight: 1},
+				{Coding: "*", Weight: 0},
+			},
+			false,
+		},
+		{
+			"params",
+			`zstd;dict="a,b;c\"";q=0.1`,
+			[]contentencoding.EncodingValue{
+				{Coding: "zstd", Weight: 0.1, Params: map[string]string{"dict": `a,b;c"`}},
+			},
+			false,
+		},
+		{"empty elements", " , gzip,,", []contentencoding.EncodingValue{{Coding: "gzip", Weight: 1}}, false},
+		{"invalid q-value", "br;q=1.5, gzip", []contentencoding.EncodingValue{{Coding: "gzip", Weight: 1}}, true},
+		{"too precise q-value", "br;q=0.1234", nil, true},
+		{"invalid coding", "g zip", nil, true},
+		{"invalid param", "gzip;q", nil, true},
+		{"unterminated quote", `gzip;a="b`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := contentencoding.ParseAcceptEncoding(tt.value)
+			if (err != nil) != tt.err {
+				t.Errorf("err should be %v but got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("should be %+v but got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseContentEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+		err   bool
+	}{
+		{"empty", "", nil, false},
+		{"single", "gzip", []string{"gzip"}, false},
+		{"chain", "gzip, zstd", []string{"gzip", "zstd"}, false},
+		{"empty elements", "gzip,, zstd,", []string{"gzip", "zstd"}, false},
+		{"uppercase", "GZIP, Zstd", []string{"gzip", "zstd"}, false},
+		{"aliases", "X-GZIP, x-compress", []string{"gzip", "compress"}, false},
+		{"repeated identity", "identity, gzip, IDENTITY", []string{"identity", "gzip"}, false},
+		{"params", "gzip;q=1", nil, true},
+		{"invalid coding", "gz(ip)", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := contentencoding.ParseContentEncoding(tt.value)
+			if (err != nil) != tt.err {
+				t.Errorf("err should be %v but got %v", tt.err, err)
+			}
+			var got []string
+			for _, v := range values {
+				if v.Weight != 1 || v.Params != nil {
+					t.Errorf("invalid value %+v", v)
+				}
+				got = append(got, v.Coding)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("should be %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []contentencoding.EncodingValue
+		want   string
+	}{
+		{"empty", nil, ""},
+		{
+			"weights",
+			[]contentencoding.EncodingValue{
+				{Coding: "br", Weight: 1},
+				{Coding: "zstd", Weight: 0.75},
+				{Coding: "gzip", Weight: 0.1234},
+				{Coding: "identity", Weight: 0.0001},
+				{Coding: "*", Weight: 0},
+			},
+			"br, zstd;q=0.75, gzip;q=0.123, identity;q=0.001, *;q=0",
+		},
+		{
+			"params",
+			[]contentencoding.EncodingValue{
+				{Coding: "zstd", Weight: 0.5, Params: map[string]string{"dict": `a "b"`, "level": "3"}},
+			},
+			`zstd;dict="a \"b\"";level=3;q=0.5`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentencoding.FormatAcceptEncoding(tt.values...); got != tt.want {
+				t.Errorf("should be '%s' but got='%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func FuzzParseAcceptEncoding(f *testing.F) {
+	for _, s := range []string{"", "gzip", "br;q=0.5, *;q=0", `zstd;a="b\"c"`, "identity;q=0", ",,"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		values, _ := contentencoding.ParseAcceptEncoding(s)
+		for _, v := range values {
+			if v.Coding == "" {
+				t.Errorf("empty coding in %q", s)
+			}
+			if v.Weight < 0 || v.Weight > 1 {
+				t.Errorf("invalid weight %v in %q", v.Weight, s)
+			}
+		}
+		formatted := contentencoding.FormatAcceptEncoding(values...)
+		reparsed, err := contentencoding.ParseAcceptEncoding(formatted)
+		if err != nil {
+			t.Fatalf("formatted %q is invalid: %v", formatted, err)
+		}
+		if len(values) != len(reparsed) {
+			t.Fatalf("round trip of %q failed: %q", s, formatted)
+		}
+		for i := range values {
+			if !reflect.DeepEqual(values[i], reparsed[i]) {
+				t.Errorf("round trip of %q failed: %+v != %+v", s, values[i], reparsed[i])
+			}
+		}
+	})
+}
+
+func FuzzParseContentEncoding(f *testing.F) {
+	for _, s := range []string{"", "gzip", "gzip, zstd", "br,,", "x-gzip"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		values, _ := contentencoding.ParseContentEncoding(s)
+		for _, v := range values {
+			if v.Coding == "" {
+				t.Errorf("invalid coding %q in %q", v.Coding, s)
+			}
+		}
+	})
+}
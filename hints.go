@@ -0,0 +1,94 @@
+package contentencoding
+
+import (
+	"context"
+	"math/bits"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+)
+
+// bufferedResponseSize is the largest hinted response that is buffered and encoded at once.
+const bufferedResponseSize = 64 << 10
+
+// SetSizeHint hints that the response to r will be about n bytes before it is encoded,
+// so that the responses encoded by Encode, ServeContent and Gzhttp are encoded for the size.
+// It must be called by the handler before the first write, and has no effect on requests not passed by them.
+// Content-Length set by the handler is used as the hint if SetSizeHint is not called, and a negative n removes the hint.
+//
+// Responses hinted up to 64KB are buffered in a buffer allocated for the size and encoded at once when the handler returns,
+// with Content-Length of the encoded body, unless the handler writes more or flushes.
+// Larger responses are streamed with the zstd or brotli window narrowed to the size, which saves the memory of encoders.
+func SetSizeHint(r *http.Request, n int64) {
+	if hints, ok := r.Context().Value(responseHintsKey{}).(*responseHints); ok {
+		if n < 0 {
+			n = -1
+		}
+		hints.size = n
+	}
+}
+
+// CompressionHint is the compression of a response chosen by its handler, see SetCompressionHint.
+type CompressionHint int
+
+const (
+	// CompressDefault compresses the response as configured.
+	CompressDefault CompressionHint = iota
+	// CompressNone sends the response as it is, e.g. for already compressed or pre-minified blobs
+	// that don't pay for the CPU of compression.
+	CompressNone
+	// CompressBest compresses the response at the best ratio levels of the coding,
+	// e.g. for responses cached and downloaded many times. It is slow for large responses, especially with br.
+	CompressBest
+)
+
+// SetCompressionHint overrides the compression of the response to r by the configuration,
+// for the responses encoded by Encode, ServeContent and Gzhttp.
+// It must be called by the handler before the first write, and has no effect on requests not passed by them.
+// The negotiated coding and Vary are not changed.
+func SetCompressionHint(r *http.Request, hint CompressionHint) {
+	if hints, ok := r.Context().Value(responseHintsKey{}).(*responseHints); ok {
+		hints.compression = hint
+	}
+}
+
+// responseHints are the hints given by the handler with SetSizeHint and SetCompressionHint.
+type responseHints struct {
+	size        int64
+	compression CompressionHint
+}
+
+type responseHintsKey struct{}
+
+// withResponseHints returns r accepting SetSizeHint and SetCompressionHint, and the hints to be set.
+func withResponseHints(r *http.Request) (*http.Request, *responseHints) {
+	hints := &responseHints{size: -1}
+	return r.WithContext(context.WithValue(r.Context(), responseHintsKey{}, hints)), hints
+}
+
+// bestLevels returns a copy of cfg with the best ratio levels of the built-in codings.
+func (cfg *config) bestLevels() *config {
+	c := *cfg
+	c.levels = map[string]int{"br": brotli.BestCompression, "gzip": gzip.BestCompression, "zstd": 22}
+	return &c
+}
+
+// hintedWindow returns the smallest power of 2 of at least min that covers size,
+// or 0 if size is unknown or the window would not be smaller than max.
+func hintedWindow(size int64, min, max int) int {
+	if size < 0 || size >= int64(max) {
+		return 0
+	}
+	w := 1 << bits.Len64(uint64(size))
+	if int64(w)/2 == size {
+		w = int(size)
+	}
+	if w < min {
+		w = min
+	}
+	if w >= max {
+		return 0
+	}
+	return w
+}
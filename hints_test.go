@@ -0,0 +1,104 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestSetSizeHint(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		hint       int64
+		wantLength bool
+	}{
+		{"no hint", 1000, -1, false},
+		{"small", 1000, 1000, true},
+		{"underestimated", 100 << 10, 1000, false},
+		{"large", 100 << 10, 100 << 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := bytes.Repeat([]byte("size hint "), tt.size/10)
+			h := contentencoding.Gzhttp(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentencoding.SetSizeHint(r, tt.hint)
+				w.Header().Set("Content-Type", "text/plain")
+				for i := 0; i < len(payload); i += 100 {
+					w.Write(payload[i : i+100])
+				}
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "zstd")
+			h.ServeHTTP(rec, req)
+			if ce := rec.Header().Get("Content-Encoding"); ce != "zstd" {
+				t.Fatalf("Content-Encoding should be zstd but got %q", ce)
+			}
+			cl := rec.Header().Get("Content-Length")
+			if tt.wantLength && cl != strconv.Itoa(rec.Body.Len()) {
+				t.Errorf("Content-Length should be %d but got %q", rec.Body.Len(), cl)
+			}
+			if !tt.wantLength && cl != "" {
+				t.Errorf("Content-Length should be empty but got %q", cl)
+			}
+			rc, err := contentencoding.NewReader(rec.Body, "zstd")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, payload) {
+				t.Error("decoded body is wrong")
+			}
+		})
+	}
+}
+
+func TestSetCompressionHint(t *testing.T) {
+	payload := bytes.Repeat([]byte("compression hint test, "), 1000)
+	sizes := make(map[contentencoding.CompressionHint]int)
+	for _, hint := range []contentencoding.CompressionHint{contentencoding.CompressDefault, contentencoding.CompressNone, contentencoding.CompressBest} {
+		h := contentencoding.Encode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentencoding.SetCompressionHint(r, hint)
+			w.Write(payload)
+		}))
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "zstd")
+		h.ServeHTTP(rec, req)
+		want := "zstd"
+		if hint == contentencoding.CompressNone {
+			want = ""
+		}
+		if ce := rec.Header().Get("Content-Encoding"); ce != want {
+			t.Errorf("Content-Encoding should be %q but got %q", want, ce)
+		}
+		if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("Vary should be Accept-Encoding but got %q", vary)
+		}
+		sizes[hint] = rec.Body.Len()
+		rc, err := contentencoding.NewReader(rec.Body, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, payload) {
+			t.Error("decoded body is wrong")
+		}
+	}
+	if sizes[contentencoding.CompressBest] > sizes[contentencoding.CompressDefault] {
+		t.Errorf("best should be smaller than %d but got %d", sizes[contentencoding.CompressDefault], sizes[contentencoding.CompressBest])
+	}
+}
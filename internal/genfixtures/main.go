@@ -0,0 +1,21 @@
+// Command genfixtures regenerates the compressed fixtures of the files given after the output directory
+// with contentencodingtest.WriteFixtures, e.g. genfixtures testdata/generated testdata/test.txt.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: genfixtures dir [file...]")
+		os.Exit(2)
+	}
+	if err := contentencodingtest.WriteFixtures(os.Args[1], os.Args[2:]...); err != nil {
+		fmt.Fprintln(os.Stderr, "genfixtures:", err)
+		os.Exit(1)
+	}
+}
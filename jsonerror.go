@@ -0,0 +1,52 @@
+package contentencoding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorResponse is the JSON body written by JSONErrorHandler.
+type ErrorResponse struct {
+	// Error is the error message.
+	Error string `json:"error"`
+	// Coding is the rejected coding of UnsupportedEncodingError.
+	Coding string `json:"coding,omitempty"`
+	// Disabled reports whether the rejected coding is disabled by configuration.
+	Disabled bool `json:"disabled,omitempty"`
+	// Limit is the name of the exceeded limit of LimitError.
+	Limit string `json:"limit,omitempty"`
+	// Max is the value of the exceeded limit.
+	Max float64 `json:"max,omitempty"`
+	// Capabilities are the codings and limits the middleware accepts, set for UnsupportedEncodingError.
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+}
+
+// JSONErrorHandler is ErrorHandler that writes ErrorResponse as JSON with the same status codes as DefaultErrorHandler.
+// Responses for UnsupportedEncodingError list the supported codings and the limits,
+// so that client developers can fix their requests without reading the server configuration.
+func JSONErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	resp := ErrorResponse{Error: err.Error()}
+	var (
+		limitErr       *LimitError
+		unsupportedErr *UnsupportedEncodingError
+	)
+	switch {
+	case errors.As(err, &limitErr):
+		resp.Limit = limitErr.Limit
+		resp.Max = limitErr.Max
+	case errors.As(err, &unsupportedErr):
+		resp.Coding = unsupportedErr.Coding
+		resp.Disabled = unsupportedErr.Disabled
+		resp.Capabilities = &unsupportedErr.Capabilities
+	}
+	b, merr := json.Marshal(resp)
+	if merr != nil {
+		http.Error(w, merr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(errorStatus(err))
+	w.Write(b)
+}
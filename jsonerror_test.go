@@ -0,0 +1,74 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestJSONErrorHandler(t *testing.T) {
+	h := contentencoding.Decode(
+		contentencoding.WithStrict(),
+		contentencoding.WithEncodings("gzip", "zstd"),
+		contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1 << 20, MaxLayers: 2}),
+		contentencoding.WithErrorHandler(contentencoding.JSONErrorHandler),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		encoding string
+		want     int
+		resp     contentencoding.ErrorResponse
+	}{
+		{
+			name:     "unsupported",
+			encoding: "deflate",
+			want:     http.StatusUnsupportedMediaType,
+			resp: contentencoding.ErrorResponse{
+				Error:  `contentencoding: unsupported content coding "deflate"`,
+				Coding: "deflate",
+				Capabilities: &contentencoding.Capabilities{
+					Codings: []string{"gzip", "zstd"},
+					Limits:  contentencoding.CapabilityLimits{MaxDecodedBytes: 1 << 20, MaxLayers: 2},
+				},
+			},
+		},
+		{
+			name:     "too many layers",
+			encoding: "gzip, gzip, gzip",
+			want:     http.StatusRequestEntityTooLarge,
+			resp: contentencoding.ErrorResponse{
+				Error: "contentencoding: MaxLayers of 2 exceeded",
+				Limit: "MaxLayers",
+				Max:   2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("test")))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type should be application/json but got %q", got)
+			}
+			var got contentencoding.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.resp) {
+				t.Errorf("response should be %+v but got %+v", tt.resp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package contentencoding
+
+import (
+	"fmt"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+)
+
+// WithGzipLevel returns a Option to encode gzip with level, from gzip.HuffmanOnly (-2) to gzip.BestCompression (9),
+// instead of gzip.DefaultCompression. It applies to the encoders of responses, spools and transcoded bodies
+// except responses with CompressBest. It panics if level is out of the range.
+func WithGzipLevel(level int) Option {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic(fmt.Sprintf("contentencoding: invalid gzip level %d", level))
+	}
+	return func(cfg *config) {
+		cfg.setLevel("gzip", level)
+	}
+}
+
+// WithBrotliQuality returns a Option to encode brotli with quality, from brotli.BestSpeed (0)
+// to brotli.BestCompression (11), instead of brotli.DefaultCompression.
+// It applies as WithGzipLevel does, and it panics if quality is out of the range.
+// zstd encoders are customized by WithEOptions, e.g. WithEOptions(zstd.WithEncoderLevel(zstd.SpeedFastest)).
+func WithBrotliQuality(quality int) Option {
+	if quality < brotli.BestSpeed || quality > brotli.BestCompression {
+		panic(fmt.Sprintf("contentencoding: invalid brotli quality %d", quality))
+	}
+	return func(cfg *config) {
+		cfg.setLevel("br", quality)
+	}
+}
+
+// WithBrotliWindow returns a Option to encode brotli with the window of 2^lgwin bytes instead of 4MB,
+// which bounds the memory of the encoder and of the clients decoding it. lgwin must be from 10 to 24.
+// A smaller window is still used for responses whose size is known. It panics if lgwin is out of the range.
+func WithBrotliWindow(lgwin int) Option {
+	if lgwin < 10 || lgwin > 24 {
+		panic(fmt.Sprintf("contentencoding: invalid brotli window %d", lgwin))
+	}
+	return func(cfg *config) {
+		cfg.brotliWindow = lgwin
+	}
+}
+
+// setLevel sets the level of encoding on a copy of the levels, since the map may be shared by copies of the config.
+func (cfg *config) setLevel(encoding string, level int) {
+	levels := make(map[string]int, len(cfg.levels)+1)
+	for k, v := range cfg.levels {
+		levels[k] = v
+	}
+	levels[encoding] = level
+	cfg.levels = levels
+}
@@ -0,0 +1,90 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestWithGzipLevel(t *testing.T) {
+	content := []byte(strings.Repeat("<p>level test</p>", 1000))
+	tests := []struct {
+		name   string
+		opts   []contentencoding.Option
+		stored bool
+	}{
+		{"default", nil, false},
+		{"level 0", []contentencoding.Option{contentencoding.WithGzipLevel(0)}, true},
+		{"overridden", []contentencoding.Option{contentencoding.WithGzipLevel(0), contentencoding.WithGzipLevel(9)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			contentencoding.ServeContent(rec, req, "index.html", time.Time{}, bytes.NewReader(content), nil, tt.opts...)
+			if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+				t.Fatalf("Content-Encoding should be gzip but got %q", got)
+			}
+			if stored := rec.Body.Len() > len(content); stored != tt.stored {
+				t.Errorf("stored should be %v but got %d bytes", tt.stored, rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestWithBrotliWindow(t *testing.T) {
+	content := []byte(strings.Repeat("<p>window test</p>", 10000))
+	tests := []struct {
+		name     string
+		lgwin    int
+		wantBits int
+	}{
+		{"narrower than the content", 16, 16},
+		{"wider than the content", 24, 18}, // narrowed to the content of 180KB
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "br")
+			rec := httptest.NewRecorder()
+			opts := []contentencoding.Option{contentencoding.WithBrotliQuality(5), contentencoding.WithBrotliWindow(tt.lgwin)}
+			contentencoding.ServeContent(rec, req, "index.html", time.Time{}, bytes.NewReader(content), nil, opts...)
+			if got := rec.Header().Get("Content-Encoding"); got != "br" {
+				t.Fatalf("Content-Encoding should be br but got %q", got)
+			}
+			if got := brotliWindowBits(rec.Body.Bytes()[0]); got != tt.wantBits {
+				t.Errorf("window bits should be %d but got %d", tt.wantBits, got)
+			}
+			b, err := contentencoding.DecodeBytes("br", rec.Body.Bytes(), contentencoding.Limits{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, content) {
+				t.Error("decoded response should be the content")
+			}
+		})
+	}
+}
+
+func TestLevels_Invalid(t *testing.T) {
+	for name, f := range map[string]func(){
+		"gzip level":     func() { contentencoding.WithGzipLevel(10) },
+		"brotli quality": func() { contentencoding.WithBrotliQuality(-1) },
+		"brotli window":  func() { contentencoding.WithBrotliWindow(25) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("should panic")
+				}
+			}()
+			f()
+		})
+	}
+}
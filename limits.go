@@ -0,0 +1,241 @@
+package contentencoding
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Limits are the limits of decoding to protect from decompression bombs.
+// Zero values mean no limit.
+type Limits struct {
+	// MaxDecodedBytes is the maximum size of the decoded body.
+	MaxDecodedBytes int64
+	// MaxRatio is the maximum ratio of the decoded size to the encoded size.
+	// It is checked once more than 64KB are decoded, so small bodies are never rejected by it.
+	MaxRatio float64
+	// MaxLayers is the maximum number of codings other than identity.
+	MaxLayers int
+	// MaxDecoderMemory is the maximum window of each brotli and zstd stream, which bounds the memory
+	// of the decoder regardless of the decoded size. Windows are at least 1KB, so smaller values are treated as 1KB.
+	// zstd is decoded synchronously with the low memory option when it is set.
+	MaxDecoderMemory int64
+}
+
+// ratioGrace is the decoded size under which MaxRatio is not checked.
+const ratioGrace = 64 << 10
+
+// LimitError is the error when decoding exceeds Limits.
+// DefaultErrorHandler responds 413 Request Entity Too Large for it.
+type LimitError struct {
+	// Limit is the name of the exceeded field of Limits, or MaxCompressedBytes for WithMaxCompressedBytes.
+	Limit string
+	// Max is the value of the limit.
+	Max float64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("contentencoding: %s of %g exceeded", e.Limit, e.Max)
+}
+
+// WithLimits returns a Option to limit decoding.
+// MaxLayers is checked before decoding and reported to the error handler,
+// the others are reported by reads of the decoded body.
+func WithLimits(l Limits) Option {
+	return func(cfg *config) {
+		cfg.limits = l
+	}
+}
+
+// OversizePolicy is the behavior of Decode for encoded requests larger than WithMaxCompressedBytes.
+type OversizePolicy int
+
+const (
+	// OversizeReject calls the error handler with LimitError, which DefaultErrorHandler responds 413 for.
+	// Bodies without Content-Length fail with LimitError when more than the maximum is read.
+	OversizeReject OversizePolicy = iota
+	// OversizePassthrough leaves the body encoded, so that the handler can store or forward it without decoding.
+	// Only Content-Length is checked, since a body can't be passed through after decoding started.
+	OversizePassthrough
+)
+
+// WithMaxCompressedBytes returns a Option to define the behavior for encoded requests whose Content-Length exceeds n,
+// which is checked before decoding starts, as a cheap first line of defense ahead of Limits.
+// Requests with only identity codings are not affected.
+func WithMaxCompressedBytes(n int64, p OversizePolicy) Option {
+	return func(cfg *config) {
+		cfg.maxCompressed = n
+		cfg.oversize = p
+	}
+}
+
+// checkCompressed returns a LimitError if the encoded body of r is too large to decode,
+// and whether the body should be passed through instead.
+func (cfg *config) checkCompressed(r *http.Request) (passthrough bool, err error) {
+	if cfg.maxCompressed <= 0 || r.ContentLength <= cfg.maxCompressed || !isEncoded(r) {
+		return false, nil
+	}
+	if cfg.oversize == OversizePassthrough {
+		return true, nil
+	}
+	return false, &LimitError{Limit: "MaxCompressedBytes", Max: float64(cfg.maxCompressed)}
+}
+
+// limitCompressed returns body that fails with LimitError when more than WithMaxCompressedBytes is read.
+func (cfg *config) limitCompressed(body io.ReadCloser) io.ReadCloser {
+	if cfg.maxCompressed <= 0 || cfg.oversize != OversizeReject {
+		return body
+	}
+	return &compressedLimit{ReadCloser: body, remaining: cfg.maxCompressed, max: cfg.maxCompressed}
+}
+
+type compressedLimit struct {
+	io.ReadCloser
+	remaining int64
+	max       int64
+}
+
+func (b *compressedLimit) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &LimitError{Limit: "MaxCompressedBytes", Max: float64(b.max)}
+	}
+	if int64(len(p)) > b.remaining+1 {
+		// read one more byte than allowed to detect the excess.
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), &LimitError{Limit: "MaxCompressedBytes", Max: float64(b.max)}
+	}
+	return n, err
+}
+
+// ErrBodyTooShort is passed to the error handler for a request whose Content-Length is smaller than
+// the smallest valid encoding of its outermost coding, e.g. 3 bytes of zstd.
+// DefaultErrorHandler responds 400 Bad Request for it.
+var ErrBodyTooShort = errors.New("contentencoding: body is too short for the content coding")
+
+// minEncodedSize are the sizes of the smallest valid encodings of built-in codings:
+// the gzip header, an empty deflate block and the trailer, and the zstd magic number,
+// frame header and a block header. Any brotli stream has at least one byte.
+var minEncodedSize = map[string]int64{
+	"gzip": 20,
+	"zstd": 9,
+}
+
+// checkMinimum returns ErrBodyTooShort if contentLength can't be a valid encoding of the outermost coding.
+// Empty bodies are not checked, they are decoded as they have always been.
+func (cfg *config) checkMinimum(codings []string, contentLength int64) error {
+	if contentLength <= 0 {
+		return nil
+	}
+	for i := len(codings) - 1; i >= 0; i-- {
+		c := codings[i]
+		if c == "identity" {
+			continue
+		}
+		if min, ok := minEncodedSize[c]; ok && cfg.builtinEnabled(c) && contentLength < min {
+			return fmt.Errorf("%w: %d bytes of %s", ErrBodyTooShort, contentLength, c)
+		}
+		return nil
+	}
+	return nil
+}
+
+// checkLayers returns a LimitError if codings has more layers than allowed.
+func (l Limits) checkLayers(codings []string) error {
+	if l.MaxLayers <= 0 {
+		return nil
+	}
+	n := 0
+	for _, c := range codings {
+		if c != "identity" {
+			n++
+		}
+	}
+	if n > l.MaxLayers {
+		return &LimitError{Limit: "MaxLayers", Max: float64(l.MaxLayers)}
+	}
+	return nil
+}
+
+// limitBody returns body that fails with LimitError when it exceeds l,
+// encoded reports the number of encoded bytes read so far.
+func (l Limits) limitBody(body io.ReadCloser, encoded func() int64) io.ReadCloser {
+	if l.MaxDecodedBytes <= 0 && l.MaxRatio <= 0 {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, limits: l, encoded: encoded}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limits  Limits
+	encoded func() int64
+	n       int64
+	err     error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if max := b.limits.MaxDecodedBytes; max > 0 && int64(len(p)) > max-b.n+1 {
+		// read one more byte than allowed to detect the excess.
+		p = p[:max-b.n+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if max := b.limits.MaxDecodedBytes; max > 0 && b.n > max {
+		b.err = &LimitError{Limit: "MaxDecodedBytes", Max: float64(max)}
+		return n - int(b.n-max), b.err
+	}
+	if ratio := b.limits.MaxRatio; ratio > 0 && b.n > ratioGrace {
+		if enc := b.encoded(); enc > 0 && float64(b.n)/float64(enc) > ratio {
+			b.err = &LimitError{Limit: "MaxRatio", Max: ratio}
+			return n, b.err
+		}
+	}
+	return n, err
+}
+
+// DecodeBytes decodes data encoded with encoding, a comma-separated list of codings in the same form as the
+// Content-Encoding header, enforcing limits as the middleware does.
+// Only built-in codings and identity are supported.
+func DecodeBytes(encoding string, data []byte, limits Limits) ([]byte, error) {
+	codings, err := contentCodings(encoding)
+	if err != nil {
+		return nil, err
+	}
+	if err := limits.checkLayers(codings); err != nil {
+		return nil, err
+	}
+	r, err := NewReader(bytes.NewReader(data), encoding, WithLimits(limits))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	encoded := int64(len(data))
+	return io.ReadAll(limits.limitBody(r, func() int64 { return encoded }))
+}
+
+// EncodeBytes encodes data with encoding, a comma-separated list of codings in the same form as the
+// Content-Encoding header, the first coding is applied first.
+// Only built-in codings and identity are supported.
+func EncodeBytes(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,203 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithLimits(t *testing.T) {
+	zeros := make([]byte, 1<<20)
+	tests := []struct {
+		name       string
+		limits     contentencoding.Limits
+		encodings  []string
+		wantStatus int
+		wantLimit  string
+	}{
+		{"no limit", contentencoding.Limits{}, []string{"gzip"}, http.StatusOK, ""},
+		{"MaxDecodedBytes", contentencoding.Limits{MaxDecodedBytes: 1 << 19}, []string{"gzip"}, http.StatusOK, "MaxDecodedBytes"},
+		{"MaxDecodedBytes not exceeded", contentencoding.Limits{MaxDecodedBytes: 1 << 20}, []string{"gzip"}, http.StatusOK, ""},
+		{"MaxRatio", contentencoding.Limits{MaxRatio: 100}, []string{"gzip"}, http.StatusOK, "MaxRatio"},
+		{"MaxLayers", contentencoding.Limits{MaxLayers: 1}, []string{"gzip", "zstd"}, http.StatusRequestEntityTooLarge, ""},
+		{"MaxLayers not exceeded", contentencoding.Limits{MaxLayers: 2}, []string{"identity", "gzip", "zstd"}, http.StatusOK, ""},
+		{"MaxDecoderMemory", contentencoding.Limits{MaxDecoderMemory: 64 << 10}, []string{"zstd"}, http.StatusOK, "MaxDecoderMemory"},
+		{"MaxDecoderMemory not exceeded", contentencoding.Limits{MaxDecoderMemory: 8 << 20}, []string{"br"}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithLimits(tt.limits))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				var limitErr *contentencoding.LimitError
+				if tt.wantLimit == "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(b, zeros) {
+						t.Error("decoded body differs")
+					}
+					return
+				}
+				if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit {
+					t.Errorf("should be %s LimitError but got %v", tt.wantLimit, err)
+				}
+				if max := tt.limits.MaxDecodedBytes; max > 0 && int64(len(b)) != max {
+					t.Errorf("should read %d bytes but got %d", max, len(b))
+				}
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, "/", zeros, tt.encodings...))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	data := bytes.Repeat([]byte("test"), 1000)
+	encoded, err := contentencoding.EncodeBytes("gzip, br", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := contentencoding.DecodeBytes("gzip, br", encoded, contentencoding.Limits{MaxDecodedBytes: int64(len(data))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("decoded data differs")
+	}
+
+	var limitErr *contentencoding.LimitError
+	if _, err := contentencoding.DecodeBytes("gzip, br", encoded, contentencoding.Limits{MaxDecodedBytes: 100}); !errors.As(err, &limitErr) {
+		t.Errorf("should be LimitError but got %v", err)
+	}
+	if _, err := contentencoding.DecodeBytes("gzip, br", encoded, contentencoding.Limits{MaxLayers: 1}); !errors.As(err, &limitErr) {
+		t.Errorf("should be LimitError but got %v", err)
+	}
+}
+
+func TestWithMaxCompressedBytes(t *testing.T) {
+	payload := bytes.Repeat([]byte("max compressed bytes test "), 1000)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	max := int64(len(encoded) - 1)
+	tests := []struct {
+		name          string
+		policy        contentencoding.OversizePolicy
+		encoding      string
+		contentLength bool
+		wantStatus    int
+		wantBody      []byte
+		wantLimitErr  bool
+	}{
+		{"reject", contentencoding.OversizeReject, "gzip", true, http.StatusRequestEntityTooLarge, nil, false},
+		{"passthrough", contentencoding.OversizePassthrough, "gzip", true, http.StatusOK, encoded, false},
+		{"identity", contentencoding.OversizeReject, "identity", true, http.StatusOK, encoded, false},
+		{"reject chunked", contentencoding.OversizeReject, "gzip", false, http.StatusOK, nil, true},
+		{"passthrough chunked", contentencoding.OversizePassthrough, "gzip", false, http.StatusOK, payload, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(contentencoding.WithMaxCompressedBytes(max, tt.policy))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if tt.wantLimitErr {
+					var limitErr *contentencoding.LimitError
+					if !errors.As(err, &limitErr) || limitErr.Limit != "MaxCompressedBytes" {
+						t.Errorf("should be MaxCompressedBytes LimitError but got %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b, tt.wantBody) {
+					t.Error("body differs")
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			if !tt.contentLength {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestDecode_BodyTooShort(t *testing.T) {
+	emptyZstd := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x20, 0x00, 0x01, 0x00, 0x00}
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		wantStatus int
+		wantErr    bool
+	}{
+		{"zstd", "zstd", []byte{0x28, 0xb5, 0x2f}, http.StatusBadRequest, true},
+		{"smallest zstd", "zstd", emptyZstd, http.StatusOK, false},
+		{"gzip", "gzip", []byte("short gzip"), http.StatusBadRequest, true},
+		{"outermost", "gzip, zstd", emptyZstd, http.StatusBadRequest, false},
+		{"identity", "zstd, identity", []byte{0x28}, http.StatusBadRequest, true},
+		{"empty", "zstd", nil, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotErr error
+			h := contentencoding.Decode(contentencoding.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				gotErr = err
+				contentencoding.DefaultErrorHandler(w, r, err)
+			}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := ioutil.ReadAll(r.Body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, rec.Code)
+			}
+			if got := errors.Is(gotErr, contentencoding.ErrBodyTooShort); got != tt.wantErr {
+				t.Errorf("ErrBodyTooShort should be %v but got %v", tt.wantErr, gotErr)
+			}
+		})
+	}
+}
+
+func TestLimits_MaxDecoderMemory(t *testing.T) {
+	data := bytes.Repeat([]byte("memory test"), 100000)
+	for _, encoding := range []string{"br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			encoded, err := contentencoding.EncodeBytes(encoding, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var limitErr *contentencoding.LimitError
+			if _, err := contentencoding.DecodeBytes(encoding, encoded, contentencoding.Limits{MaxDecoderMemory: 64 << 10}); !errors.As(err, &limitErr) || limitErr.Limit != "MaxDecoderMemory" {
+				t.Errorf("should be MaxDecoderMemory LimitError but got %v", err)
+			}
+			got, err := contentencoding.DecodeBytes(encoding, encoded, contentencoding.Limits{MaxDecoderMemory: 8 << 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("decoded data differs")
+			}
+		})
+	}
+}
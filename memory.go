@@ -0,0 +1,99 @@
+package contentencoding
+
+import (
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decoderWindow returns the largest window allowed by MaxDecoderMemory, or 0 if it is not limited.
+func (l Limits) decoderWindow() int64 {
+	if l.MaxDecoderMemory <= 0 {
+		return 0
+	}
+	if l.MaxDecoderMemory < zstd.MinWindowSize {
+		return zstd.MinWindowSize
+	}
+	return l.MaxDecoderMemory
+}
+
+// zstdOptions returns the DOptions to decode zstd within MaxDecoderMemory,
+// synchronously without the buffers of concurrent decoding.
+func (l Limits) zstdOptions() []zstd.DOption {
+	window := l.decoderWindow()
+	if window == 0 {
+		return nil
+	}
+	dopts := []zstd.DOption{zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true)}
+	if window < zstd.MaxWindowSize {
+		dopts = append(dopts, zstd.WithDecoderMaxWindow(uint64(window)))
+	}
+	return dopts
+}
+
+// memoryError returns LimitError for err of the zstd decoder for a window exceeding MaxDecoderMemory.
+func (l Limits) memoryError(err error) error {
+	if l.MaxDecoderMemory > 0 && (errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded)) {
+		return &LimitError{Limit: "MaxDecoderMemory", Max: float64(l.MaxDecoderMemory)}
+	}
+	return err
+}
+
+// limitMemory returns rc whose errors are translated by memoryError.
+func (l Limits) limitMemory(rc io.ReadCloser) io.ReadCloser {
+	if l.MaxDecoderMemory <= 0 {
+		return rc
+	}
+	return &memoryLimitedBody{ReadCloser: rc, limits: l}
+}
+
+type memoryLimitedBody struct {
+	io.ReadCloser
+	limits Limits
+}
+
+func (b *memoryLimitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	return n, b.limits.memoryError(err)
+}
+
+// limitBrotliWindow returns r that fails with LimitError if the window of the brotli stream in it,
+// declared by its first byte, exceeds MaxDecoderMemory, before the decoder allocates it.
+func (l Limits) limitBrotliWindow(r io.Reader) io.Reader {
+	if l.MaxDecoderMemory <= 0 {
+		return r
+	}
+	return &brotliWindowLimit{r: r, limits: l}
+}
+
+type brotliWindowLimit struct {
+	r       io.Reader
+	limits  Limits
+	checked bool
+}
+
+func (b *brotliWindowLimit) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if n > 0 && !b.checked {
+		b.checked = true
+		if window := int64(1)<<brotliWindowBits(p[0]) - 16; window > b.limits.decoderWindow() {
+			return 0, &LimitError{Limit: "MaxDecoderMemory", Max: float64(b.limits.MaxDecoderMemory)}
+		}
+	}
+	return n, err
+}
+
+// brotliWindowBits returns WBITS of the brotli stream header in b as RFC 7932 describes.
+func brotliWindowBits(b byte) int {
+	if b&1 == 0 {
+		return 16
+	}
+	if n := (b >> 1) & 7; n != 0 {
+		return 17 + int(n)
+	}
+	if n := (b >> 4) & 7; n != 0 {
+		return 8 + int(n)
+	}
+	return 17
+}
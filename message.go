@@ -0,0 +1,33 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Message is a message of transports other than HTTP, such as AMQP or Kafka, whose metadata carries Content-Encoding.
+type Message interface {
+	// Headers returns the metadata of the message.
+	Headers() http.Header
+	// Body returns the encoded body of the message.
+	Body() io.Reader
+}
+
+// DecodeMessage returns the body of m decoded by its Content-Encoding header as DecodeBody does,
+// so that message consumers share the decoders and limits of the HTTP middleware.
+// The header name is matched case-insensitively since message headers are often not canonicalized,
+// and multiple values are combined as a comma-separated list.
+func DecodeMessage(m Message, opts ...Option) (io.ReadCloser, error) {
+	return DecodeBody(messageEncoding(m.Headers()), m.Body(), opts...)
+}
+
+func messageEncoding(h http.Header) string {
+	var values []string
+	for k, v := range h {
+		if strings.EqualFold(k, "Content-Encoding") {
+			values = append(values, v...)
+		}
+	}
+	return strings.Join(values, ", ")
+}
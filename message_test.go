@@ -0,0 +1,59 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+type testMessage struct {
+	headers http.Header
+	body    []byte
+}
+
+func (m testMessage) Headers() http.Header {
+	return m.headers
+}
+
+func (m testMessage) Body() io.Reader {
+	return bytes.NewReader(m.body)
+}
+
+func TestDecodeMessage(t *testing.T) {
+	payload := bytes.Repeat([]byte("message test "), 1000)
+	tests := []struct {
+		name    string
+		headers http.Header
+		codings []string
+	}{
+		{"canonical", http.Header{"Content-Encoding": {"gzip"}}, []string{"gzip"}},
+		{"lower case", http.Header{"content-encoding": {"zstd"}}, []string{"zstd"}},
+		{"multiple values", http.Header{"Content-Encoding": {"br", "gzip"}}, []string{"br", "gzip"}},
+		{"no header", http.Header{"Content-Type": {"text/plain"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := contentencodingtest.CompressBody(payload, tt.codings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := contentencoding.DecodeMessage(testMessage{headers: tt.headers, body: encoded})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, payload) {
+				t.Error("decoded body should be the payload")
+			}
+		})
+	}
+}
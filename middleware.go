@@ -0,0 +1,388 @@
+package contentencoding
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Middleware is the decoding middleware of Decode with managed resources.
+// It reuses zstd decoders across requests, and Shutdown releases them.
+// Its configuration can be replaced at runtime by Update, and codings can be turned off by SetEnabled.
+type Middleware struct {
+	cfg      atomic.Value // *config with the switches of SetEnabled
+	disabled atomic.Value // map[string]bool
+	route    string
+	scoped   bool
+	// internal is set for the middleware of helpers such as ValidationHandler and CopyBody,
+	// which decode the body they are given even if the request has been handled by another Middleware.
+	internal bool
+
+	mu       sync.Mutex
+	base     *config
+	inflight int
+	idle     chan struct{}
+}
+
+// New returns a Middleware configured with opts.
+func New(opts ...Option) *Middleware {
+	cfg := newConfig(opts)
+	cfg.zstdPool = &zstdPool{dopts: cfg.zstdOptions()}
+	return newMiddleware(cfg)
+}
+
+func newMiddleware(cfg *config) *Middleware {
+	m := &Middleware{base: cfg}
+	m.cfg.Store(cfg)
+	m.disabled.Store(map[string]bool(nil))
+	return m
+}
+
+// internalMiddleware returns the Middleware of a helper decoding the body it is given, see Middleware.internal.
+func internalMiddleware(cfg *config) *Middleware {
+	m := newMiddleware(cfg)
+	m.internal = true
+	return m
+}
+
+// config returns the current configuration.
+func (m *Middleware) config() *config {
+	return m.cfg.Load().(*config)
+}
+
+// Update replaces the configuration of m with the one configured with opts, as New does,
+// so that limits, codings and other options can be changed without rebuilding the handler chain.
+// The options given to New are not kept, opts must contain all options to use.
+// Requests in flight keep the configuration they started with, later requests use the new one.
+// Codings turned off by SetEnabled stay off.
+// It is safe to call Update concurrently with requests.
+func (m *Middleware) Update(opts ...Option) {
+	cfg := newConfig(opts)
+	cfg.zstdPool = &zstdPool{dopts: cfg.zstdOptions()}
+	m.mu.Lock()
+	old := m.base
+	m.base = cfg
+	m.cfg.Store(applySwitches(cfg, m.switches()))
+	m.mu.Unlock()
+	if old.zstdPool != nil {
+		// decoders used by requests in flight are closed when they are returned.
+		old.zstdPool.close()
+	}
+}
+
+// SetEnabled turns decoding of coding on or off for the requests that start after it returns,
+// e.g. to stop decoding br at once when a vulnerability of its decoder is found.
+// It applies to built-in codings and decoders given by WithDecoder, including those of configurations
+// resolved by WithConfigResolver, and a disabled coding is handled as a coding without decoder, see WithEncodings.
+// Turning on a coding only reverts SetEnabled, it doesn't enable a coding the configuration doesn't decode.
+// It is safe to call SetEnabled concurrently with requests.
+func (m *Middleware) SetEnabled(coding string, enabled bool) {
+	coding = CanonicalCoding(coding)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	disabled := make(map[string]bool)
+	for c := range m.switches() {
+		disabled[c] = true
+	}
+	if enabled {
+		delete(disabled, coding)
+	} else {
+		disabled[coding] = true
+	}
+	m.disabled.Store(disabled)
+	m.cfg.Store(applySwitches(m.base, disabled))
+}
+
+// switches returns the codings turned off by SetEnabled.
+func (m *Middleware) switches() map[string]bool {
+	return m.disabled.Load().(map[string]bool)
+}
+
+// resolve returns the configuration for r with the switches of SetEnabled.
+func (m *Middleware) resolve(r *http.Request) *config {
+	cfg := m.config()
+	if c := cfg.resolve(r); c != cfg {
+		return applySwitches(c, m.switches())
+	}
+	return cfg
+}
+
+// applySwitches returns a copy of cfg without the disabled codings, or cfg if nothing is disabled.
+func applySwitches(cfg *config, disabled map[string]bool) *config {
+	if len(disabled) == 0 {
+		return cfg
+	}
+	c := *cfg
+	c.encodings = make(map[string]bool)
+	for _, e := range builtinEncodings {
+		if cfg.builtinEnabled(e) && !disabled[e] {
+			c.encodings[e] = true
+		}
+	}
+	c.decoders = nil
+	for _, d := range cfg.decoders {
+		if !disabled[CanonicalCoding(d.Encoding)] {
+			c.decoders = append(c.decoders, d)
+		}
+	}
+	return &c
+}
+
+// Handler returns next wrapped by the middleware, it behaves as Decode.
+// Requests whose bodies are decoded by another Middleware, e.g. of ScopedDecode, are passed to next as they are,
+// so that nested middleware never decodes a body twice, see WithRejectNested.
+// Requests that the other Middleware passes on, e.g. of methods it does not decode, are handled as usual.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if outer, ok := r.Context().Value(handledKey{}).(*Middleware); ok && !m.internal {
+			if cfg := m.config(); cfg.rejectNested && !outer.scoped {
+				cfg.handleError(w, r, ErrNestedDecode)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		cfg := m.resolve(r)
+		if cfg.annotationHeader != "" {
+			r.Header.Del(cfg.annotationHeader)
+		}
+		cfg.advertiseCodings(w, r)
+		if cfg.skipMethod(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := r.Header["Content-Encoding"]; ok {
+			// the handler and the other readers of the header must see what is decoded.
+			contentEncoding, err := cfg.contentEncoding(r.Header)
+			if err != nil {
+				cfg.handleError(w, r, err)
+				return
+			}
+			r.Header["Content-Encoding"] = []string{contentEncoding}
+		}
+		if cfg.contentRange != ContentRangeDecode && isEncodedRange(r) {
+			if cfg.contentRange == ContentRangeReject {
+				cfg.handleError(w, r, ErrContentRange)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.passthrough {
+			cfg.validateRequest(r)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		m.begin()
+		defer m.end()
+		if r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		passthrough, err := cfg.checkCompressed(r)
+		if err != nil {
+			cfg.handleError(w, r, err)
+			return
+		}
+		if passthrough {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// only requests whose bodies are decoded here are marked, nested middleware decodes the others.
+		r = r.WithContext(context.WithValue(r.Context(), handledKey{}, m))
+		if err := cfg.injectFault(r); err != nil {
+			cfg.handleError(w, r, err)
+			return
+		}
+		r = cfg.rawBody(r)
+		r, mode := cfg.decodeMode(r)
+		raw := r.Body
+		var replay *replayBuffer
+		if cfg.replayMax > 0 {
+			// the copy is read again by RewindBody, so the decoders must not close raw.
+			replay = &replayBuffer{r: raw, max: cfg.replayMax}
+			r.Body = io.NopCloser(replay)
+			defer raw.Close()
+		}
+		digests := cfg.newDigests()
+		encoded := &countingBody{ReadCloser: digests.hashEncoded(capReads(cfg.limitCompressed(r.Body), cfg.maxReadAhead))}
+		r.Body = encoded
+		contentEncoding := r.Header.Get("Content-Encoding")
+		undecoded, ok := cfg.decodeRequest(w, r)
+		if !ok {
+			return
+		}
+		r = digests.request(r)
+		if r.Body == encoded {
+			// nothing is decoded.
+			digests.identity()
+			r.Body = digests.hashEncoded(raw)
+			defer raw.Close()
+			if len(undecoded) == 0 {
+				var cleanup func()
+				r, cleanup = cfg.spool(r)
+				defer cleanup()
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		var cpu *cpuBody
+		if cfg.cpuAccounting {
+			cpu = &cpuBody{ReadCloser: r.Body}
+			r.Body = cpu
+		}
+		decoded := &countingBody{ReadCloser: digests.hashDecoded(cfg.limits.limitBody(r.Body, func() int64 { return encoded.n }))}
+		body := withContext(r.Context(), cfg.progressBody(r, encoded, decoded), raw)
+		r.Body = cfg.faultBody(body)
+		w, r = cfg.validate(w, r)
+		defer cfg.finish(r, m.route, encoded, decoded, cpu, body)
+		r, annotated := cfg.annotation(r, contentEncoding, encoded, decoded)
+		defer annotated()
+		if cfg.statusCodes != nil {
+			r.Body = &statusBody{ReadCloser: r.Body, cfg: cfg}
+		}
+		cleanup, err := materialize(r, mode)
+		defer cleanup()
+		if err != nil {
+			cfg.handleReadError(w, r, err)
+			return
+		}
+		if len(undecoded) == 0 {
+			var cleanup func()
+			r, cleanup = cfg.spool(r)
+			defer cleanup()
+		}
+		if replay != nil {
+			r = cfg.replay(r, replay)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown waits for in-flight decodes to complete, then closes the pooled zstd decoders.
+// If ctx is done first, it returns the error of ctx and the decoders are left to be released by the garbage collector.
+// Requests served after Shutdown use a new decoder each.
+func (m *Middleware) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	var idle chan struct{}
+	if m.inflight > 0 {
+		if m.idle == nil {
+			m.idle = make(chan struct{})
+		}
+		idle = m.idle
+	}
+	m.mu.Unlock()
+
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if cfg := m.config(); cfg.zstdPool != nil {
+		cfg.zstdPool.close()
+	}
+	return nil
+}
+
+func (m *Middleware) begin() {
+	m.mu.Lock()
+	m.inflight++
+	m.mu.Unlock()
+}
+
+func (m *Middleware) end() {
+	m.mu.Lock()
+	m.inflight--
+	if m.inflight == 0 && m.idle != nil {
+		close(m.idle)
+		m.idle = nil
+	}
+	m.mu.Unlock()
+}
+
+// zstdPool is a pool of zstd decoders that can be closed, unlike sync.Pool.
+type zstdPool struct {
+	dopts []zstd.DOption
+
+	mu     sync.Mutex
+	free   []*zstd.Decoder
+	closed bool
+}
+
+// get returns a pooled decoder reading r, which is returned to the pool by Close.
+func (p *zstdPool) get(r io.Reader) (io.ReadCloser, error) {
+	d, err := p.take()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Reset(r); err != nil {
+		p.put(d)
+		return nil, err
+	}
+	return &pooledDecoder{d: d, p: p}, nil
+}
+
+// take returns a pooled decoder or a new one, which must be returned by put.
+func (p *zstdPool) take() (*zstd.Decoder, error) {
+	var d *zstd.Decoder
+	p.mu.Lock()
+	if n := len(p.free); n > 0 {
+		d = p.free[n-1]
+		p.free = p.free[:n-1]
+	}
+	p.mu.Unlock()
+
+	if d == nil {
+		return zstd.NewReader(nil, p.dopts...)
+	}
+	return d, nil
+}
+
+func (p *zstdPool) put(d *zstd.Decoder) {
+	// release the reference to the body.
+	d.Reset(nil)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		d.Close()
+		return
+	}
+	p.free = append(p.free, d)
+}
+
+func (p *zstdPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, d := range p.free {
+		d.Close()
+	}
+	p.free = nil
+}
+
+type pooledDecoder struct {
+	d *zstd.Decoder
+	p *zstdPool
+}
+
+func (pd *pooledDecoder) Read(b []byte) (int, error) {
+	if pd.d == nil {
+		return 0, zstd.ErrDecoderClosed
+	}
+	return pd.d.Read(b)
+}
+
+func (pd *pooledDecoder) Close() error {
+	if pd.d != nil {
+		pd.p.put(pd.d)
+		pd.d = nil
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package contentencoding_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := contentencoding.New()
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(b) != "test" {
+			t.Errorf("should be test but got='%s'", b)
+		}
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "zstd", "gzip", "zstd")
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// requests after Shutdown are still decoded.
+	req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "zstd")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestMiddleware_Shutdown(t *testing.T) {
+	m := contentencoding.New()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		ioutil.ReadAll(r.Body)
+	}))
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "zstd")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("should be %v but got %v", context.DeadlineExceeded, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- m.Shutdown(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("Shutdown should wait for the in-flight request")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-served
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiddleware_Update(t *testing.T) {
+	m := contentencoding.New()
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}))
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "zstd"))
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("status code should be %d but got %d", http.StatusOK, code)
+	}
+	m.Update(contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1}))
+	if code := serve(); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status code should be %d but got %d", http.StatusRequestEntityTooLarge, code)
+	}
+	if got := m.Capabilities().Limits.MaxDecodedBytes; got != 1 {
+		t.Errorf("MaxDecodedBytes should be 1 but got %d", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			serve()
+		}()
+		go func() {
+			defer wg.Done()
+			m.Update()
+		}()
+	}
+	wg.Wait()
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("status code should be %d but got %d", http.StatusOK, code)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiddleware_SetEnabled(t *testing.T) {
+	tenant := contentencoding.NewConfig(contentencoding.WithStrict())
+	m := contentencoding.New(
+		contentencoding.WithStrict(),
+		contentencoding.WithConfigResolver(func(r *http.Request) *contentencoding.Config {
+			if r.URL.Path == "/tenant" {
+				return tenant
+			}
+			return nil
+		}),
+	)
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	serve := func(path, coding string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, path, []byte("test"), coding))
+		return rec.Code
+	}
+
+	m.SetEnabled("BR", false)
+	for _, path := range []string{"/", "/tenant"} {
+		if code := serve(path, "br"); code != http.StatusUnsupportedMediaType {
+			t.Errorf("status code of %s should be %d but got %d", path, http.StatusUnsupportedMediaType, code)
+		}
+		if code := serve(path, "gzip"); code != http.StatusOK {
+			t.Errorf("status code of %s should be %d but got %d", path, http.StatusOK, code)
+		}
+	}
+	m.Update(contentencoding.WithStrict())
+	if code := serve("/", "br"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("br should stay disabled after Update but got %d", code)
+	}
+	if got := m.Capabilities().Codings; len(got) != 2 {
+		t.Errorf("Capabilities should not contain br but got %v", got)
+	}
+
+	m.SetEnabled("br", true)
+	if code := serve("/", "br"); code != http.StatusOK {
+		t.Errorf("status code should be %d but got %d", http.StatusOK, code)
+	}
+}
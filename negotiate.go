@@ -0,0 +1,139 @@
+package contentencoding
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrNotAcceptable is returned by Negotiate when no coding is acceptable, not even identity.
+var ErrNotAcceptable = errors.New("contentencoding: no acceptable content coding")
+
+// Negotiate chooses a content coding from offered by acceptEncoding, the value of Accept-Encoding,
+// following the q-value semantics of RFC 9110.
+// The coding with the highest weight wins and ties are broken by the order of offered.
+// identity is acceptable unless it is excluded by "identity;q=0" or "*;q=0",
+// and it is returned when no offered coding is acceptable.
+// An empty acceptEncoding means that only identity is acceptable,
+// callers must handle a missing header themselves since then any coding is acceptable.
+func Negotiate(acceptEncoding string, offered []string) (string, error) {
+	accepted, _ := ParseAcceptEncoding(acceptEncoding) // malformed elements are ignored
+	coding, ok := negotiate(accepted, offered)
+	if !ok {
+		return "", ErrNotAcceptable
+	}
+	return coding, nil
+}
+
+// negotiate chooses the coding from offered and identity with the highest weight in accepted,
+// ties are broken by the order of offered and identity comes last.
+// identity is acceptable unless it is excluded explicitly or by "*;q=0".
+func negotiate(accepted []EncodingValue, offered []string) (string, bool) {
+	best, bestQ := "", 0.0
+	for _, coding := range append(offered[:len(offered):len(offered)], "identity") {
+		if q := weight(accepted, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best, best != ""
+}
+
+// weight returns the weight of coding, the first element wins if it appears more than once.
+func weight(accepted []EncodingValue, coding string) float64 {
+	coding = CanonicalCoding(coding)
+	wildcard := -1.0
+	for _, a := range accepted {
+		switch CanonicalCoding(a.Coding) {
+		case coding:
+			return a.Weight
+		case "*":
+			wildcard = a.Weight
+		}
+	}
+	if wildcard >= 0 {
+		return wildcard
+	}
+	if coding == "identity" {
+		return 0.001 // lowest acceptable weight
+	}
+	return 0
+}
+
+// WithAssumedAcceptEncoding returns a Option to negotiate response codings for requests without Accept-Encoding
+// as if they sent acceptEncoding, and for HTTP/1.0 requests by their Accept-Encoding,
+// e.g. "gzip" for internal networks whose clients are all known to decode it.
+// By default, both receive identity, since a missing Accept-Encoding doesn't tell which codings the client decodes
+// and HTTP/1.0 clients and caches may mishandle encoded responses and Vary.
+func WithAssumedAcceptEncoding(acceptEncoding string) Option {
+	accepted, _ := ParseAcceptEncoding(acceptEncoding) // malformed elements are ignored
+	return func(cfg *config) {
+		cfg.assumeAccept = true
+		cfg.assumedAccept = accepted
+	}
+}
+
+// CapabilityOverride adjusts the parsed Accept-Encoding of r before negotiation,
+// for clients known to mis-advertise support.
+type CapabilityOverride func(r *http.Request, accepted []EncodingValue) []EncodingValue
+
+// WithCapabilityOverride returns a Option to adjust the Accept-Encoding of requests before negotiation
+// of response codings.
+func WithCapabilityOverride(f CapabilityOverride) Option {
+	return func(cfg *config) {
+		cfg.capabilityOverride = f
+	}
+}
+
+// UserAgentRule disables codings for clients whose User-Agent contains Contains.
+type UserAgentRule struct {
+	// Contains is a case-sensitive substring of User-Agent.
+	Contains string
+	// Disable are the codings that matching clients must not receive.
+	Disable []string
+}
+
+// UserAgentRules returns a CapabilityOverride that applies all matching rules,
+// e.g. UserAgentRule{Contains: "BrokenProxy/1.", Disable: []string{"br"}} forces such clients to fall back to other codings.
+func UserAgentRules(rules ...UserAgentRule) CapabilityOverride {
+	copied := make([]UserAgentRule, len(rules))
+	for i, rule := range rules {
+		copied[i] = UserAgentRule{Contains: rule.Contains, Disable: append([]string(nil), rule.Disable...)}
+	}
+	rules = copied
+	return func(r *http.Request, accepted []EncodingValue) []EncodingValue {
+		ua := r.Header.Get("User-Agent")
+		var disabled []EncodingValue
+		for _, rule := range rules {
+			if !strings.Contains(ua, rule.Contains) {
+				continue
+			}
+			for _, coding := range rule.Disable {
+				disabled = append(disabled, EncodingValue{Coding: CanonicalCoding(coding), Weight: 0})
+			}
+		}
+		if len(disabled) == 0 {
+			return accepted
+		}
+		// the first element for a coding wins in negotiation.
+		return append(disabled, accepted...)
+	}
+}
+
+// negotiateRequest negotiates the response coding for r from offered.
+// Requests without Accept-Encoding and HTTP/1.0 requests receive identity unless WithAssumedAcceptEncoding is used.
+func (cfg *config) negotiateRequest(r *http.Request, offered []string) (string, bool) {
+	var accepted []EncodingValue
+	values, ok := r.Header["Accept-Encoding"]
+	switch {
+	case !r.ProtoAtLeast(1, 1) && !cfg.assumeAccept:
+		// HTTP/1.0 clients and caches may mishandle encoded responses and Vary.
+	case !ok:
+		accepted = cfg.assumedAccept
+	default:
+		accepted, _ = ParseAcceptEncoding(strings.Join(values, ","))
+	}
+	if cfg.capabilityOverride != nil {
+		accepted = cfg.capabilityOverride(r, accepted)
+	}
+	return negotiate(accepted, offered)
+}
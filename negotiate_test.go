@@ -0,0 +1,92 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestNegotiate(t *testing.T) {
+	offered := []string{"br", "zstd", "gzip"}
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+		err    error
+	}{
+		{"single", "gzip", "gzip", nil},
+		{"server order", "gzip, br", "br", nil},
+		{"q-value", "br;q=0.5, gzip;q=1.0", "gzip", nil},
+		{"q-value with spaces", "br ; q=0.5 , gzip ; q=0.8", "gzip", nil},
+		{"case-insensitive", "GZIP;Q=0.9, Br;q=0.1", "gzip", nil},
+		{"x-gzip", "x-gzip", "gzip", nil},
+		{"wildcard", "*", "br", nil},
+		{"wildcard with exclusion", "*, br;q=0", "zstd", nil},
+		{"unknown", "compress", "identity", nil},
+		{"empty", "", "identity", nil},
+		{"identity preferred", "gzip;q=0.5, identity", "identity", nil},
+		{"identity excluded", "compress, identity;q=0", "", contentencoding.ErrNotAcceptable},
+		{"wildcard excluded", "*;q=0", "", contentencoding.ErrNotAcceptable},
+		{"wildcard excluded but identity", "*;q=0, identity", "identity", nil},
+		{"invalid q-value", "br;q=2, gzip", "gzip", nil},
+		{"other params", "gzip;level=1;q=0.5, zstd;q=0.4", "gzip", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := contentencoding.Negotiate(tt.accept, offered)
+			if err != tt.err {
+				t.Fatalf("err should be %v but got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("should be '%s' but got='%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUserAgentRules(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	override := contentencoding.UserAgentRules(
+		contentencoding.UserAgentRule{Contains: "BrokenProxy/1.", Disable: []string{"br"}},
+		contentencoding.UserAgentRule{Contains: "NoZstd", Disable: []string{"ZSTD"}},
+	)
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"not matched", "Mozilla/5.0", "br"},
+		{"br disabled", "BrokenProxy/1.2", "zstd"},
+		{"all disabled", "BrokenProxy/1.2 NoZstd", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Encoding": {"gzip"}},
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+				Request:    httptest.NewRequest(http.MethodGet, "/", nil),
+			}
+			resp.Request.Header.Set("Accept-Encoding", "br, zstd, gzip;q=0.5")
+			resp.Request.Header.Set("User-Agent", tt.userAgent)
+			mr := contentencoding.TranscodeResponse(contentencoding.WithCapabilityOverride(override))
+			if err := mr(resp); err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Encoding"); got != tt.want {
+				t.Errorf("should be %s but got='%s'", tt.want, got)
+			}
+		})
+	}
+}
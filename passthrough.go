@@ -0,0 +1,98 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+)
+
+// WithPassthrough returns a Option that makes Decode validate body instead of decoding it,
+// for proxies that must forward the original encoded bytes.
+// The next handler reads the untouched body while a copy of it is decoded and discarded within WithLimits.
+// Decoding errors are returned from Read of the body, at the latest when it reaches EOF,
+// so the handler must read the body to the end before trusting it.
+func WithPassthrough() Option {
+	return func(cfg *config) {
+		cfg.passthrough = true
+	}
+}
+
+// validatingBody is a body that feeds what is read into a decoding goroutine.
+type validatingBody struct {
+	body io.ReadCloser
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func (cfg *config) validateRequest(r *http.Request) {
+	pr, pw := io.Pipe()
+	b := &validatingBody{body: r.Body, pw: pw, done: make(chan struct{})}
+
+	vcfg := *cfg
+	vcfg.errHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		b.err = err
+	}
+	vr := r.Clone(r.Context())
+	encoded := &countingBody{ReadCloser: pr}
+	vr.Body = encoded
+	go func() {
+		defer close(b.done)
+		defer pr.Close()
+		if _, ok := vcfg.decodeRequest(discardWriter{}, vr); !ok {
+			return
+		}
+		decoded := io.Reader(vr.Body)
+		if vr.Body != encoded {
+			decoded = vcfg.limits.limitBody(vr.Body, func() int64 { return encoded.n })
+		}
+		_, err := io.Copy(io.Discard, decoded)
+		if cerr := vr.Body.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			b.err = err
+		}
+	}()
+	r.Body = b
+}
+
+func (b *validatingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		if _, werr := b.pw.Write(p[:n]); werr != nil {
+			<-b.done // decoding has finished early
+			if b.err != nil {
+				return n, b.err
+			}
+		}
+	}
+	switch err {
+	case nil:
+	case io.EOF:
+		b.pw.Close()
+		<-b.done
+		if b.err != nil {
+			return n, b.err
+		}
+	default:
+		b.pw.CloseWithError(err)
+	}
+	return n, err
+}
+
+func (b *validatingBody) Close() error {
+	b.pw.CloseWithError(io.ErrUnexpectedEOF)
+	return b.body.Close()
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Header() http.Header {
+	return make(http.Header)
+}
+
+func (discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (discardWriter) WriteHeader(int) {}
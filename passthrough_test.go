@@ -0,0 +1,107 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestWithPassthrough(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		data     string
+		ok       bool
+	}{
+		{"brotli", "br", "testdata/test.txt.br", true},
+		{"gzip", "gzip", "testdata/test.txt.gz", true},
+		{"zstd", "zstd", "testdata/test.txt.zst", true},
+		{"gzip+zstd", "gzip, zstd", "testdata/test.txt.gz.zst", true},
+		{"invalid gzip", "gzip", "testdata/test.txt", false},
+		{"invalid chain", "zstd, gzip", "testdata/test.txt.gz.zst", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := os.ReadFile(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dm := contentencoding.Decode(contentencoding.WithPassthrough())
+			h := dm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if tt.ok && err != nil {
+					t.Errorf("should not be error but got %v", err)
+				}
+				if !tt.ok && err == nil {
+					t.Error("should be error")
+				}
+				if tt.ok && !bytes.Equal(b, want) {
+					t.Error("body should be untouched")
+				}
+			}))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(want))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+		})
+	}
+}
+
+func TestWithPassthrough_truncated(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm := contentencoding.Decode(contentencoding.WithPassthrough())
+	h := dm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err == nil {
+			t.Error("should be error")
+		}
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b[:len(b)-2]))
+	req.Header.Set("Content-Encoding", "zstd")
+	h.ServeHTTP(rec, req)
+}
+
+func TestWithPassthrough_earlyClose(t *testing.T) {
+	dm := contentencoding.Decode(contentencoding.WithPassthrough())
+	h := dm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.Body.Close(); err != nil {
+			t.Error(err)
+		}
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("test", 1024)))
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(rec, req)
+}
+
+func TestWithPassthrough_limits(t *testing.T) {
+	want, err := contentencoding.EncodeBytes("gzip", make([]byte, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm := contentencoding.Decode(contentencoding.WithPassthrough(), contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 100}))
+	h := dm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		var limitErr *contentencoding.LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != "MaxDecodedBytes" {
+			t.Errorf("should be MaxDecodedBytes LimitError but got %v", err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(want))
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(rec, req)
+}
@@ -0,0 +1,87 @@
+package contentencoding
+
+import (
+	"context"
+	"io"
+)
+
+// EncoderPool bounds the number of encoders working at the same time across the middleware and transports sharing it,
+// so that the CPU and memory spent on compression by a process are controlled from one place.
+// An encoder holds a slot of the pool from its creation until it is closed, and creating one waits for a free slot.
+type EncoderPool struct {
+	slots chan struct{}
+}
+
+// NewEncoderPool returns an EncoderPool of size slots. It panics if size is not positive.
+func NewEncoderPool(size int) *EncoderPool {
+	if size <= 0 {
+		panic("contentencoding: EncoderPool size must be positive")
+	}
+	return &EncoderPool{slots: make(chan struct{}, size)}
+}
+
+// InUse returns the number of slots in use.
+func (p *EncoderPool) InUse() int {
+	return len(p.slots)
+}
+
+// WithEncoderPool returns a Option to create encoders within p, it applies to Encode, ServeContent, Gzhttp, Transcode,
+// TranscodeResponse, Transcoder and WithSpool. Waiting for a slot of a response is canceled with the request.
+func WithEncoderPool(p *EncoderPool) Option {
+	return func(cfg *config) {
+		cfg.encoderPool = p
+	}
+}
+
+// encoder returns the encoder by newEncoder holding a slot of p, or without p if it is nil.
+// A chain of encoders is created by one newEncoder so that it holds only one slot.
+func (p *EncoderPool) encoder(ctx context.Context, newEncoder func() (io.WriteCloser, error)) (io.WriteCloser, error) {
+	if p == nil {
+		return newEncoder()
+	}
+	if _, err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	wc, err := newEncoder()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return &slotEncoder{WriteCloser: wc, p: p}, nil
+}
+
+// acquire waits for a free slot of p, which is released by release, or returns immediately if p is nil.
+func (p *EncoderPool) acquire(ctx context.Context) (release func(), err error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return func() { <-p.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// slotEncoder releases the slot of the pool when it is closed.
+type slotEncoder struct {
+	io.WriteCloser
+	p        *EncoderPool
+	released bool
+}
+
+func (e *slotEncoder) Flush() error {
+	if f, ok := e.WriteCloser.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (e *slotEncoder) Close() error {
+	err := e.WriteCloser.Close()
+	if !e.released {
+		e.released = true
+		<-e.p.slots
+	}
+	return err
+}
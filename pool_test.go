@@ -0,0 +1,74 @@
+package contentencoding_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestEncoderPool(t *testing.T) {
+	p := contentencoding.NewEncoderPool(1)
+	tc, err := contentencoding.NewTranscoder("identity", "gzip, zstd", contentencoding.WithEncoderPool(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := tc.Writer(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := p.InUse(); n != 1 {
+		t.Errorf("a chain of encoders should hold 1 slot but got %d", n)
+	}
+
+	// a response waits for the slot until the request is canceled.
+	var writeErr error
+	h := contentencoding.Gzhttp(nil, contentencoding.WithEncoderPool(p))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, writeErr = w.Write([]byte("test"))
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("Accept-Encoding", "zstd")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !errors.Is(writeErr, context.DeadlineExceeded) {
+		t.Errorf("should be context.DeadlineExceeded but got %v", writeErr)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w, err := tc.Writer(io.Discard)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Close()
+	}()
+	select {
+	case <-done:
+		t.Fatal("encoder should wait for the slot")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if n := p.InUse(); n != 0 {
+		t.Errorf("slots should be released but %d are in use", n)
+	}
+}
+
+func TestNewEncoderPool_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic")
+		}
+	}()
+	contentencoding.NewEncoderPool(0)
+}
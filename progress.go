@@ -0,0 +1,79 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+)
+
+// Progress is the progress of decoding a request body.
+type Progress struct {
+	// EncodedBytes is the number of encoded bytes read from the request body so far.
+	EncodedBytes int64
+	// DecodedBytes is the number of decoded bytes read by the handler so far.
+	DecodedBytes int64
+	// Done reports whether the decoded body reached the end.
+	Done bool
+}
+
+// ProgressHook is called with the progress of decoding during reads of the decoded body.
+// A non-nil error is returned from the read, so the hook can abort the request,
+// and the hook can pace the request by blocking.
+type ProgressHook func(r *http.Request, p Progress) error
+
+// WithProgressHook returns a Option to report the progress of decoding each request,
+// every time another every bytes are decoded and when the end is reached.
+// If every is not positive, the progress is reported only at the end.
+func WithProgressHook(every int64, hook ProgressHook) Option {
+	return func(cfg *config) {
+		cfg.progressEvery = every
+		cfg.progressHook = hook
+	}
+}
+
+// progressBody reports the progress of decoded, which reads from encoded.
+type progressBody struct {
+	io.ReadCloser
+	r                *http.Request
+	hook             ProgressHook
+	every            int64
+	next             int64
+	encoded, decoded *countingBody
+	done             bool
+	err              error
+}
+
+func (cfg *config) progressBody(r *http.Request, encoded, decoded *countingBody) io.ReadCloser {
+	if cfg.progressHook == nil {
+		return decoded
+	}
+	return &progressBody{
+		ReadCloser: decoded,
+		r:          r,
+		hook:       cfg.progressHook,
+		every:      cfg.progressEvery,
+		next:       cfg.progressEvery,
+		encoded:    encoded,
+		decoded:    decoded,
+	}
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err
+	}
+	if b.decoded.eof || (b.every > 0 && b.decoded.n >= b.next) {
+		for b.every > 0 && b.next <= b.decoded.n {
+			b.next += b.every
+		}
+		b.done = b.decoded.eof
+		if herr := b.hook(b.r, Progress{EncodedBytes: b.encoded.n, DecodedBytes: b.decoded.n, Done: b.done}); herr != nil {
+			b.err = herr
+			return n, herr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,55 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithProgressHook(t *testing.T) {
+	payload := bytes.Repeat([]byte("test"), 1<<16)
+	var reports []contentencoding.Progress
+	h := contentencoding.Decode(contentencoding.WithProgressHook(64<<10, func(r *http.Request, p contentencoding.Progress) error {
+		reports = append(reports, p)
+		return nil
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", payload, "gzip"))
+
+	if len(reports) < 4 {
+		t.Fatalf("should be reported every 64KB of 256KB but got %+v", reports)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].DecodedBytes < reports[i-1].DecodedBytes || reports[i].EncodedBytes < reports[i-1].EncodedBytes {
+			t.Errorf("progress should not go back %+v", reports)
+		}
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.DecodedBytes != int64(len(payload)) {
+		t.Errorf("the end should be reported %+v", last)
+	}
+	for _, p := range reports[:len(reports)-1] {
+		if p.Done {
+			t.Errorf("the end should be reported once %+v", reports)
+		}
+	}
+}
+
+func TestWithProgressHook_Abort(t *testing.T) {
+	errAbort := errors.New("abort")
+	h := contentencoding.Decode(contentencoding.WithProgressHook(1024, func(r *http.Request, p contentencoding.Progress) error {
+		return errAbort
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); !errors.Is(err, errAbort) {
+			t.Errorf("should be %v but got %v", errAbort, err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", make([]byte, 1<<20), "zstd"))
+}
@@ -0,0 +1,292 @@
+package contentencoding
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DecodeResponse returns a function for httputil.ReverseProxy.ModifyResponse
+// that decodes the upstream response body detected by Content-Encoding.
+// br, gzip and zstd are decoded from the outermost coding, decoding stops at the first other coding
+// and the remaining codings are left in Content-Encoding.
+// Content-Length is removed because the length of the decoded body is unknown.
+// WithLimits applies to the decoding: MaxLayers is returned as the error, and the others by reads of the body.
+// Decoders given by WithDecoder are not used since they work on requests.
+// DecodeTransport decodes responses of clients in the same way.
+func DecodeResponse(opts ...Option) func(resp *http.Response) error {
+	cfg := newConfig(opts)
+
+	return func(resp *http.Response) error {
+		if !hasBody(resp) {
+			return nil
+		}
+		contentEncoding, err := cfg.contentEncoding(resp.Header)
+		if err != nil {
+			return nil
+		}
+		values, err := contentCodings(contentEncoding)
+		if err != nil {
+			// a malformed header is left as it is.
+			return nil
+		}
+		if err := cfg.limits.checkLayers(values); err != nil {
+			return err
+		}
+		encoded := &countingBody{ReadCloser: resp.Body}
+		body := io.ReadCloser(encoded)
+		decoded := false
+		i := len(values) - 1
+		for ; i >= 0; i-- {
+			v := values[i]
+			if v == "identity" {
+				continue
+			}
+			rc, ok, err := cfg.builtinReader(v, body)
+			if !ok {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			body = &layeredBody{ReadCloser: rc, under: body}
+			decoded = true
+		}
+		if !decoded {
+			return nil
+		}
+
+		resp.Body = cfg.limits.limitBody(body, func() int64 { return encoded.n })
+		to := "identity"
+		if rest := values[:i+1]; len(rest) > 0 {
+			to = strings.Join(rest, ", ")
+			resp.Header.Set("Content-Encoding", to)
+		} else {
+			resp.Header.Del("Content-Encoding")
+			resp.Uncompressed = true
+		}
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		cfg.responseFixup(resp, strings.Join(values, ", "), to)
+		return nil
+	}
+}
+
+// ResponseFixup is called after DecodeResponse or TranscodeResponse has changed the coding of resp
+// from from to to, which are Content-Encoding values or identity.
+// Content-Length is fixed before it is called.
+type ResponseFixup func(resp *http.Response, from, to string)
+
+// DefaultResponseFixup is ResponseFixup that will used by default.
+// It weakens a strong ETag, since the body is no longer byte-for-byte identical,
+// and removes Digest, Content-MD5, Content-Digest and Repr-Digest, which no longer match the body.
+func DefaultResponseFixup(resp *http.Response, from, to string) {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+	for _, k := range []string{"Digest", "Content-Md5", "Content-Digest", "Repr-Digest"} {
+		resp.Header.Del(k)
+	}
+}
+
+// WithResponseFixup returns a Option to customize how DecodeResponse and TranscodeResponse fix response headers.
+func WithResponseFixup(f ResponseFixup) Option {
+	if f == nil {
+		f = DefaultResponseFixup
+	}
+	return func(cfg *config) {
+		cfg.responseFixup = f
+	}
+}
+
+// builtinEncodings are the built-in codings in the default order of server preference.
+var builtinEncodings = []string{"br", "zstd", "gzip"}
+
+// WithEncodingPreference returns a Option to define the order of server preference among codings
+// that the client weights equally, e.g. zstd, br, gzip to save CPU.
+// Only br, gzip and zstd are used and codings not listed are never chosen.
+// By default, br, zstd and gzip are preferred in this order.
+func WithEncodingPreference(encodings ...string) Option {
+	var preference []string
+	for _, e := range encodings {
+		e = CanonicalCoding(e)
+		if _, ok, _ := builtinWriter(e, io.Discard); ok {
+			preference = append(preference, e)
+		}
+	}
+	return func(cfg *config) {
+		cfg.preference = preference
+	}
+}
+
+// TranscodeResponse returns a function for httputil.ReverseProxy.ModifyResponse
+// that re-encodes the upstream response body to the coding the client prefers most, e.g. from gzip to br.
+// The client preference is read from Accept-Encoding of the proxied request.
+// The body is transcoded while it is read, so it is never buffered as a whole.
+// Only 200 OK responses are transcoded, since Content-Range and ETag of the others, e.g. 206 Partial Content,
+// would no longer describe the body. Responses with no, unknown or multiple codings, gRPC and gRPC-web responses
+// and requests without Accept-Encoding are left as they are too. WithLimits applies to the decoding
+// of the upstream body, and errors of the transcoded body are returned by its reads.
+func TranscodeResponse(opts ...Option) func(resp *http.Response) error {
+	cfg := newConfig(opts)
+
+	return func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK || !hasBody(resp) || resp.Request == nil || isGRPC(resp.Header.Get("Content-Type")) {
+			return nil
+		}
+		if _, ok := resp.Request.Header["Accept-Encoding"]; !ok {
+			return nil
+		}
+		contentEncoding, err := cfg.contentEncoding(resp.Header)
+		if err != nil {
+			return nil
+		}
+		values, err := contentCodings(contentEncoding)
+		if err != nil || len(values) != 1 {
+			return nil
+		}
+		from := values[0]
+		// the current coding is offered first so that it wins ties.
+		offered := append([]string{from}, cfg.preference...)
+		to, ok := cfg.negotiateRequest(resp.Request, offered)
+		if !ok || to == from {
+			return nil
+		}
+
+		key, cacheable := cfg.variantKey(resp, to)
+		// variants with the large window must not be served to other clients.
+		cacheable = cacheable && !cfg.largeWindow(resp.Request, to)
+		if cacheable {
+			if b, ok := cfg.variantCache.Get(key); ok {
+				resp.Body.Close()
+				resp.Body = io.NopCloser(bytes.NewReader(b))
+				resp.ContentLength = int64(len(b))
+				resp.Header.Set("Content-Length", strconv.Itoa(len(b)))
+				resp.Header.Set("Content-Encoding", to)
+				cfg.varyAcceptEncoding(resp.Header)
+				cfg.responseFixup(resp, from, to)
+				return nil
+			}
+		}
+
+		encoded := &countingBody{ReadCloser: resp.Body}
+		rc, ok, err := cfg.builtinReader(from, encoded)
+		if !ok {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dec := cfg.limits.limitBody(rc, func() int64 { return encoded.n })
+		if to == "identity" {
+			resp.Body = &layeredBody{ReadCloser: dec, under: resp.Body}
+			resp.Header.Del("Content-Encoding")
+		} else {
+			var capture *limitedBuffer
+			if cacheable {
+				capture = &limitedBuffer{max: cfg.maxVariantSize}
+			}
+			pr := encodePipe(dec, func(w io.Writer) (io.WriteCloser, error) {
+				if capture != nil {
+					w = io.MultiWriter(w, capture)
+				}
+				return cfg.responseEncoder(resp.Request, to, w, -1)
+			}, func(err error) {
+				dec.Close()
+				if err == nil && capture != nil && !capture.overflow {
+					cfg.variantCache.Set(key, capture.Bytes())
+				}
+			})
+			resp.Body = &layeredBody{ReadCloser: pr, under: resp.Body}
+			resp.Header.Set("Content-Encoding", to)
+		}
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		cfg.varyAcceptEncoding(resp.Header)
+		cfg.responseFixup(resp, from, to)
+		return nil
+	}
+}
+
+// variantKey returns the key of the variant of resp in the variant cache.
+// ok is false if the variant should not be cached.
+func (cfg *config) variantKey(resp *http.Response, encoding string) (key VariantKey, ok bool) {
+	if cfg.variantCache == nil || encoding == "identity" || resp.StatusCode != http.StatusOK {
+		return VariantKey{}, false
+	}
+	for _, v := range resp.Header.Values("Cache-Control") {
+		if strings.Contains(strings.ToLower(v), "no-store") {
+			return VariantKey{}, false
+		}
+	}
+	for _, v := range resp.Header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" && !strings.EqualFold(f, "Accept-Encoding") {
+				// the variant may differ by the other fields, including *.
+				return VariantKey{}, false
+			}
+		}
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		return VariantKey{}, false
+	}
+	return VariantKey{URL: resp.Request.URL.String(), Encoding: encoding, Validator: validator}, true
+}
+
+// limitedBuffer is a buffer that stops storing once it exceeds max bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > b.max {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// addVary adds field to Vary unless it is already listed.
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch {
+	case resp.StatusCode >= 100 && resp.StatusCode < 200,
+		resp.StatusCode == http.StatusNoContent,
+		resp.StatusCode == http.StatusNotModified:
+		return false
+	}
+	return resp.Body != nil && resp.Body != http.NoBody
+}
+
+// layeredBody is a decoded body that also closes the body under it.
+type layeredBody struct {
+	io.ReadCloser
+	under io.Closer
+}
+
+func (b *layeredBody) Close() error {
+	err := b.ReadCloser.Close()
+	if uerr := b.under.Close(); err == nil {
+		err = uerr
+	}
+	return err
+}
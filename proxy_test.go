@@ -0,0 +1,379 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func newProxy(t *testing.T, encoding string, body []byte) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(body)
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.ModifyResponse = contentencoding.DecodeResponse()
+	srv := httptest.NewServer(proxy)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		data     string
+		want     string
+	}{
+		{"brotli", "br", "testdata/test.txt.br", ""},
+		{"gzip", "gzip", "testdata/test.txt.gz", ""},
+		{"zstd", "zstd", "testdata/test.txt.zst", ""},
+		{"gzip+zstd", "gzip, zstd", "testdata/test.txt.gz.zst", ""},
+		{"custom+zstd", "custom, zstd", "testdata/test.txt.zst", "custom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := newProxy(t, tt.encoding, b)
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Encoding", "identity") // disable transparent decoding of http.Transport
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Encoding"); got != tt.want {
+				t.Errorf("Content-Encoding should be '%s' but got='%s'", tt.want, got)
+			}
+			if tt.want != "" {
+				return
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if txt := strings.TrimSpace(string(body)); txt != "test" {
+				t.Errorf("should be test but got='%s'", txt)
+			}
+		})
+	}
+}
+
+func TestDecodeResponse_error(t *testing.T) {
+	srv := newProxy(t, "gzip", []byte("test")) // not compressed
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("should be 502 but got %d", resp.StatusCode)
+	}
+}
+
+func TestTranscodeResponse(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Vary", "accept-encoding")
+		w.Write(b)
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.ModifyResponse = contentencoding.TranscodeResponse()
+	srv := httptest.NewServer(proxy)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"br", "br", "br"},
+		{"zstd", "gzip;q=0.5, zstd", "zstd"},
+		{"server preference", "*", "gzip"},
+		{"keep", "br, gzip", "gzip"},
+		{"identity", "identity", ""},
+		{"not acceptable", "identity;q=0", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Encoding", tt.accept)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding should be '%s' but got='%s'", tt.want, got)
+			}
+			if got := resp.Header.Values("Vary"); len(got) != 1 {
+				t.Errorf("Vary should not be duplicated, %v", got)
+			}
+
+			rec := httptest.NewRecorder()
+			dreq := httptest.NewRequest(http.MethodPost, "/", resp.Body)
+			dreq.Header.Set("Content-Encoding", tt.want)
+			contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if txt := strings.TrimSpace(string(body)); txt != "test" {
+					t.Errorf("should be test but got='%s'", txt)
+				}
+			})).ServeHTTP(rec, dreq)
+		})
+	}
+}
+
+func TestDecodeResponse_fixup(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{
+		"Content-Encoding": {"gzip"},
+		"Content-Length":   {strconv.Itoa(len(b))},
+		"Etag":             {`"abc"`},
+		"Digest":           {"sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="},
+		"Repr-Digest":      {"sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"},
+	}
+	newResponse := func() *http.Response {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(b)),
+			ContentLength: int64(len(b)),
+			Request:       httptest.NewRequest(http.MethodGet, "/", nil),
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		resp := newResponse()
+		if err := contentencoding.DecodeResponse()(resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.ContentLength != -1 || resp.Header.Get("Content-Length") != "" {
+			t.Errorf("Content-Length should be removed, %v", resp.Header)
+		}
+		if etag := resp.Header.Get("ETag"); etag != `W/"abc"` {
+			t.Errorf("ETag should be weakened but got='%s'", etag)
+		}
+		if resp.Header.Get("Digest") != "" || resp.Header.Get("Repr-Digest") != "" {
+			t.Errorf("digests should be removed, %v", resp.Header)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		var from, to string
+		resp := newResponse()
+		mr := contentencoding.DecodeResponse(contentencoding.WithResponseFixup(func(resp *http.Response, f, t string) {
+			from, to = f, t
+		}))
+		if err := mr(resp); err != nil {
+			t.Fatal(err)
+		}
+		if from != "gzip" || to != "identity" {
+			t.Errorf("from=%s, to=%s", from, to)
+		}
+		if etag := resp.Header.Get("ETag"); etag != `"abc"` {
+			t.Errorf("ETag should be kept but got='%s'", etag)
+		}
+	})
+}
+
+func TestTranscodeResponse_WithEncodingPreference(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		preference []string
+		accept     string
+		want       string
+	}{
+		{"default", nil, "br, zstd", "br"},
+		{"zstd first", []string{"zstd", "br"}, "br, zstd", "zstd"},
+		{"client weight wins", []string{"zstd", "br"}, "br, zstd;q=0.5", "br"},
+		{"not listed", []string{"zstd"}, "br, gzip;q=0.1", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []contentencoding.Option
+			if tt.preference != nil {
+				opts = append(opts, contentencoding.WithEncodingPreference(tt.preference...))
+			}
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Encoding": {"gzip"}},
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+				Request:    httptest.NewRequest(http.MethodGet, "/", nil),
+			}
+			resp.Request.Header.Set("Accept-Encoding", tt.accept)
+			if err := contentencoding.TranscodeResponse(opts...)(resp); err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Encoding"); got != tt.want {
+				t.Errorf("should be %s but got='%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTranscodeResponse_GRPC(t *testing.T) {
+	for _, ctype := range []string{"application/grpc", "application/grpc+proto", "application/grpc-web+proto", "application/grpc-web-text"} {
+		t.Run(ctype, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Accept-Encoding", "br")
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": {ctype}, "Content-Encoding": {"gzip"}},
+				Body:          ioutil.NopCloser(strings.NewReader("frames")),
+				ContentLength: 6,
+				Request:       req,
+			}
+			if err := contentencoding.TranscodeResponse()(resp); err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+				t.Errorf("Content-Encoding should be gzip but got %q", got)
+			}
+		})
+	}
+}
+
+func TestTranscodeResponse_status(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		status int
+		want   string
+	}{
+		{"OK", http.StatusOK, "br"},
+		{"Partial Content", http.StatusPartialContent, "gzip"},
+		{"Not Found", http.StatusNotFound, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "br")
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header: http.Header{
+					"Content-Encoding": {"gzip"},
+					"Content-Range":    {fmt.Sprintf("bytes 0-%d/%d", len(b)-1, len(b))},
+					"Etag":             {`"v1"`},
+				},
+				Body:    io.NopCloser(bytes.NewReader(b)),
+				Request: req,
+			}
+			if err := contentencoding.TranscodeResponse()(resp); err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.want {
+				t.Errorf("Content-Encoding should be '%s' but got='%s'", tt.want, got)
+			}
+			if tt.want == "gzip" && resp.Header.Get("ETag") != `"v1"` {
+				t.Errorf("ETag should be kept but got %q", resp.Header.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestDecodeResponse_limits(t *testing.T) {
+	b, err := contentencoding.EncodeBytes("gzip, zstd", make([]byte, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newResponse := func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": {"gzip, zstd"}},
+			Body:       io.NopCloser(bytes.NewReader(b)),
+		}
+	}
+	var limitErr *contentencoding.LimitError
+
+	resp := newResponse()
+	if err := contentencoding.DecodeResponse(contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 100}))(resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(resp.Body); !errors.As(err, &limitErr) || limitErr.Limit != "MaxDecodedBytes" {
+		t.Errorf("should be MaxDecodedBytes LimitError but got %v", err)
+	}
+
+	err = contentencoding.DecodeResponse(contentencoding.WithLimits(contentencoding.Limits{MaxLayers: 1}))(newResponse())
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxLayers" {
+		t.Errorf("should be MaxLayers LimitError but got %v", err)
+	}
+}
+
+func TestTranscodeResponse_limits(t *testing.T) {
+	b, err := contentencoding.EncodeBytes("gzip", make([]byte, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Encoding": {"gzip"}},
+		Body:       io.NopCloser(bytes.NewReader(b)),
+		Request:    req,
+	}
+	if err := contentencoding.TranscodeResponse(contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 100}))(resp); err != nil {
+		t.Fatal(err)
+	}
+	var limitErr *contentencoding.LimitError
+	if _, err := ioutil.ReadAll(resp.Body); !errors.As(err, &limitErr) {
+		t.Errorf("should be LimitError but got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package contentencoding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrRawBodyTooLarge is the error of RawBody.Bytes when the encoded body exceeds the size given to WithRawBody.
+var ErrRawBodyTooLarge = errors.New("contentencoding: raw body too large")
+
+// WithRawBody returns a Option to keep the exact encoded request body of up to maxBytes,
+// for handlers that verify HTTP message signatures or HMACs computed over the body as sent,
+// while they read the decoded body as usual. The kept body is available by RawBodyFromRequest.
+func WithRawBody(maxBytes int64) Option {
+	return func(cfg *config) {
+		cfg.rawMax = maxBytes
+	}
+}
+
+// RawBody is the encoded request body kept by WithRawBody.
+type RawBody struct {
+	mu       sync.Mutex
+	r        io.ReadCloser
+	max      int64
+	buf      []byte
+	pos      int
+	eof      bool
+	err      error
+	tooLarge bool
+}
+
+type rawBodyKey struct{}
+
+// RawBodyFromRequest returns the encoded body of r kept by WithRawBody, or nil if the option is not used.
+func RawBodyFromRequest(r *http.Request) *RawBody {
+	b, _ := r.Context().Value(rawBodyKey{}).(*RawBody)
+	return b
+}
+
+// Bytes returns the whole encoded body.
+// It can be called before the decoded body is read, the rest of the encoded body is read ahead
+// and decoded later, so a signature can be verified before the handler consumes the body.
+// It returns ErrRawBodyTooLarge if the encoded body exceeds the size given to WithRawBody.
+func (b *RawBody) Bytes() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var chunk [32 << 10]byte
+	for !b.tooLarge && !b.eof && b.err == nil {
+		n, err := b.r.Read(chunk[:])
+		b.buf = append(b.buf, chunk[:n]...)
+		b.tooLarge = int64(len(b.buf)) > b.max
+		b.setErr(err)
+	}
+	if b.tooLarge {
+		return nil, ErrRawBodyTooLarge
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.buf, nil
+}
+
+func (b *RawBody) setErr(err error) {
+	if err == io.EOF {
+		b.eof = true
+	} else if err != nil {
+		b.err = err
+	}
+}
+
+// rawReader reads the encoded body through RawBody.
+type rawReader struct {
+	b *RawBody
+}
+
+func (r rawReader) Read(p []byte) (int, error) {
+	return r.b.read(p)
+}
+
+func (r rawReader) Close() error {
+	return r.b.r.Close()
+}
+
+func (b *RawBody) read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pos < len(b.buf) {
+		n := copy(p, b.buf[b.pos:])
+		b.pos += n
+		return n, nil
+	}
+	if b.eof {
+		return 0, io.EOF
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.r.Read(p)
+	if !b.tooLarge {
+		if int64(len(b.buf)+n) > b.max {
+			// everything kept has been read, so it is released.
+			b.tooLarge = true
+			b.buf = nil
+			b.pos = 0
+		} else {
+			b.buf = append(b.buf, p[:n]...)
+			b.pos = len(b.buf)
+		}
+	}
+	b.setErr(err)
+	return n, err
+}
+
+// rawBody replaces the body of r to be kept as RawBody.
+func (cfg *config) rawBody(r *http.Request) *http.Request {
+	if cfg.rawMax <= 0 {
+		return r
+	}
+	b := &RawBody{r: r.Body, max: cfg.rawMax}
+	r = r.WithContext(context.WithValue(r.Context(), rawBodyKey{}, b))
+	r.Body = rawReader{b: b}
+	return r
+}
@@ -0,0 +1,72 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithRawBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("raw body test "), 1000)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		maxBytes int64
+		before   bool
+		wantErr  error
+	}{
+		{"before decoding", 1 << 20, true, nil},
+		{"after decoding", 1 << 20, false, nil},
+		{"too large before decoding", 16, true, contentencoding.ErrRawBodyTooLarge},
+		{"too large after decoding", 16, false, contentencoding.ErrRawBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := contentencoding.Decode(contentencoding.WithRawBody(tt.maxBytes))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				rb := contentencoding.RawBodyFromRequest(r)
+				if rb == nil {
+					t.Fatal("raw body should be found")
+				}
+				verify := func() {
+					raw, err := rb.Bytes()
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("error should be %v but got %v", tt.wantErr, err)
+					}
+					if err == nil && !bytes.Equal(raw, encoded) {
+						t.Error("raw body should be the encoded body")
+					}
+				}
+				if tt.before {
+					verify()
+				}
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b, payload) {
+					t.Error("decoded body should be the payload")
+				}
+				if !tt.before {
+					verify()
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+			req.Header.Set("Content-Encoding", "gzip")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if !called {
+				t.Error("handler should be called")
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package contentencoding
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// WithMaxReadAhead returns a Option to cap how far decoders read the request body ahead of the handler,
+// for multiplexed HTTP/2 and HTTP/3 connections where unread data should stay in the flow-control window.
+// Each read from the request body is limited to n bytes, and zstd decodes blocks synchronously
+// instead of decoding several blocks ahead concurrently.
+// Decoders still read what they need to produce output, e.g. about 4KB for gzip and a whole block of up to 128KB for zstd.
+// n <= 0 means no limit, which is the default.
+func WithMaxReadAhead(n int) Option {
+	return func(cfg *config) {
+		cfg.maxReadAhead = n
+	}
+}
+
+// zstdOptions returns the options of zstd decoders.
+func (cfg *config) zstdOptions() []zstd.DOption {
+	limited := cfg.limits.zstdOptions()
+	if cfg.maxReadAhead <= 0 && limited == nil {
+		return cfg.dopts
+	}
+	dopts := append([]zstd.DOption{}, cfg.dopts...)
+	if cfg.maxReadAhead > 0 {
+		dopts = append(dopts, zstd.WithDecoderConcurrency(1))
+	}
+	return append(dopts, limited...)
+}
+
+// capReads returns body whose each read is limited to n bytes if n is positive.
+func capReads(body io.ReadCloser, n int) io.ReadCloser {
+	if n <= 0 {
+		return body
+	}
+	return &cappedBody{ReadCloser: body, n: n}
+}
+
+type cappedBody struct {
+	io.ReadCloser
+	n int
+}
+
+func (b *cappedBody) Read(p []byte) (int, error) {
+	if len(p) > b.n {
+		p = p[:b.n]
+	}
+	return b.ReadCloser.Read(p)
+}
@@ -0,0 +1,61 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+type readSizeRecorder struct {
+	r       io.Reader
+	maxRead int
+	total   int
+}
+
+func (rec *readSizeRecorder) Read(p []byte) (int, error) {
+	if len(p) > rec.maxRead {
+		rec.maxRead = len(p)
+	}
+	n, err := rec.r.Read(p)
+	rec.total += n
+	return n, err
+}
+
+func TestWithMaxReadAhead(t *testing.T) {
+	payload := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(payload)
+
+	// zstd needs a whole block, which is up to 128KB.
+	for encoding, maxReadAhead := range map[string]int{"br": 64 << 10, "gzip": 64 << 10, "zstd": 160 << 10} {
+		t.Run(encoding, func(t *testing.T) {
+			encoded, err := contentencodingtest.CompressBody(payload, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			const n = 1024
+			body := &readSizeRecorder{r: bytes.NewReader(encoded)}
+			h := contentencoding.Decode(contentencoding.WithMaxReadAhead(n))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadFull(r.Body, make([]byte, 10)); err != nil {
+					t.Fatal(err)
+				}
+				// only the small internal buffers of the decoders are read ahead.
+				if body.total > maxReadAhead {
+					t.Errorf("%d bytes are read ahead", body.total)
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", body)
+			req.Header.Set("Content-Encoding", encoding)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if body.maxRead > n {
+				t.Errorf("reads should be limited to %d but got %d", n, body.maxRead)
+			}
+		})
+	}
+}
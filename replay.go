@@ -0,0 +1,107 @@
+package contentencoding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrNotReplayable is the error of RewindBody when the decoded body can't be restarted.
+var ErrNotReplayable = errors.New("contentencoding: body is not replayable")
+
+// WithReplayableBody returns a Option to keep a copy of the encoded request body of up to maxBytes,
+// so that RewindBody can restart the decoded body for frameworks that dispatch a request to handlers again.
+// Only bodies decoded with built-in codings are replayable, and the limits of WithLimits apply to each pass.
+// Statistics, digests and spools reflect the first pass only.
+func WithReplayableBody(maxBytes int64) Option {
+	return func(cfg *config) {
+		cfg.replayMax = maxBytes
+	}
+}
+
+type replayKey struct{}
+
+// RewindBody restarts the decoded body of r from the beginning by decoding the kept copy of the encoded body again,
+// see WithReplayableBody.
+// It returns ErrNotReplayable if the option is not used, a coding has no built-in decoder,
+// or the encoded body read so far exceeds the size to keep.
+func RewindBody(r *http.Request) error {
+	b, _ := r.Context().Value(replayKey{}).(*replayBody)
+	if b == nil {
+		return ErrNotReplayable
+	}
+	return b.rewind()
+}
+
+// replayBuffer keeps what is read from r until it exceeds max, and reads it again after rewind.
+type replayBuffer struct {
+	r        io.Reader
+	max      int64
+	buf      []byte
+	pos      int
+	overflow bool
+}
+
+func (b *replayBuffer) Read(p []byte) (int, error) {
+	if b.pos < len(b.buf) {
+		n := copy(p, b.buf[b.pos:])
+		b.pos += n
+		return n, nil
+	}
+	n, err := b.r.Read(p)
+	if !b.overflow {
+		if int64(len(b.buf)+n) > b.max {
+			b.overflow = true
+			b.buf = nil
+		} else {
+			b.buf = append(b.buf, p[:n]...)
+		}
+		b.pos = len(b.buf)
+	}
+	return n, err
+}
+
+// replayBody is the decoded body that can be restarted.
+type replayBody struct {
+	io.ReadCloser
+	cfg      *config
+	encoding string
+	buf      *replayBuffer
+	// rebuilt reports whether ReadCloser is decoded again by rewind, the first one is closed by the middleware.
+	rebuilt bool
+}
+
+// replay wraps the decoded body of r to be restarted from buf, if all codings have built-in decoders.
+func (cfg *config) replay(r *http.Request, buf *replayBuffer) *http.Request {
+	encoding := r.Header.Get("Content-Encoding")
+	codings, err := contentCodings(encoding)
+	if err != nil {
+		return r
+	}
+	for _, c := range codings {
+		if c != "identity" && !cfg.builtinEnabled(c) {
+			return r
+		}
+	}
+	b := &replayBody{ReadCloser: r.Body, cfg: cfg, encoding: encoding, buf: buf}
+	r.Body = b
+	return r.WithContext(context.WithValue(r.Context(), replayKey{}, b))
+}
+
+func (b *replayBody) rewind() error {
+	if b.buf.overflow {
+		return ErrNotReplayable
+	}
+	b.buf.pos = 0
+	body, err := b.cfg.newReader(b.buf, b.encoding)
+	if err != nil {
+		return err
+	}
+	if b.rebuilt {
+		b.ReadCloser.Close()
+	}
+	b.rebuilt = true
+	b.ReadCloser = b.cfg.limits.limitBody(body, func() int64 { return int64(b.buf.pos) })
+	return nil
+}
@@ -0,0 +1,79 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithReplayableBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("replay test "), 1000)
+	tests := []struct {
+		name     string
+		encoding string
+		maxBytes int64
+		partial  bool
+		wantErr  error
+	}{
+		{"gzip", "gzip", 1 << 20, false, nil},
+		{"chain", "br, zstd", 1 << 20, false, nil},
+		{"partial", "zstd", 1 << 20, true, nil},
+		{"too large", "gzip", 16, false, contentencoding.ErrNotReplayable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := contentencoding.New(contentencoding.WithReplayableBody(tt.maxBytes)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				var err error
+				if tt.partial {
+					_, err = io.ReadFull(r.Body, make([]byte, 100))
+				} else {
+					_, err = ioutil.ReadAll(r.Body)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := contentencoding.RewindBody(r); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error should be %v but got %v", tt.wantErr, err)
+				}
+				if tt.wantErr != nil {
+					return
+				}
+				for i := 0; i < 2; i++ {
+					b, err := ioutil.ReadAll(r.Body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(b, payload) {
+						t.Errorf("pass %d should read the payload", i)
+					}
+					if err := contentencoding.RewindBody(r); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", payload, strings.Split(tt.encoding, ", ")...))
+			if !called {
+				t.Error("handler should be called")
+			}
+		})
+	}
+}
+
+func TestRewindBody_NotReplayable(t *testing.T) {
+	h := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := contentencoding.RewindBody(r); !errors.Is(err, contentencoding.ErrNotReplayable) {
+			t.Errorf("error should be %v but got %v", contentencoding.ErrNotReplayable, err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "gzip"))
+}
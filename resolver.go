@@ -0,0 +1,107 @@
+package contentencoding
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// Config is a configuration of the decoding middleware built from Options,
+// resolved for each request by ConfigResolver.
+type Config struct {
+	cfg *config
+}
+
+// NewConfig returns a Config configured with opts, as Decode is.
+func NewConfig(opts ...Option) *Config {
+	return &Config{cfg: newConfig(opts)}
+}
+
+// ConfigResolver returns the configuration to decode r with, e.g. by the API key or the Host header of r.
+// nil means the configuration of the middleware.
+type ConfigResolver func(r *http.Request) *Config
+
+// WithConfigResolver returns a Option to resolve the configuration of each request by resolve,
+// so that multi-tenant servers can apply different limits and codings to each tenant.
+// The resolved configuration is used instead of the one of the middleware as a whole, it applies to Decode and Middleware.
+// ConfigResolver of the resolved configuration is ignored, and its zstd decoders are not pooled.
+// resolve is called for every request, see CachedResolver to avoid building the same configuration again.
+func WithConfigResolver(resolve ConfigResolver) Option {
+	return func(cfg *config) {
+		cfg.resolver = resolve
+	}
+}
+
+// resolve returns the configuration for r.
+func (cfg *config) resolve(r *http.Request) *config {
+	if cfg.resolver == nil {
+		return cfg
+	}
+	if c := cfg.resolver(r); c != nil && c.cfg != nil {
+		return c.cfg
+	}
+	return cfg
+}
+
+// CachedResolver returns a ConfigResolver that calls resolve with the key of each request
+// and caches the result for the key.
+// Up to size configurations are kept, and the least recently used ones are evicted.
+// resolve may be called concurrently and more than once for the same key.
+func CachedResolver(size int, key func(r *http.Request) string, resolve func(key string) *Config) ConfigResolver {
+	c := &configCache{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	return func(r *http.Request) *Config {
+		k := key(r)
+		if cfg, ok := c.get(k); ok {
+			return cfg
+		}
+		cfg := resolve(k)
+		c.set(k, cfg)
+		return cfg
+	}
+}
+
+type configCache struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type configEntry struct {
+	key string
+	cfg *Config
+}
+
+func (c *configCache) get(key string) (*Config, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*configEntry).cfg, true
+}
+
+func (c *configCache) set(key string, cfg *Config) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*configEntry).cfg = cfg
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&configEntry{key: key, cfg: cfg})
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.entries, e.Value.(*configEntry).key)
+	}
+}
@@ -0,0 +1,56 @@
+package contentencoding_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithConfigResolver(t *testing.T) {
+	small := contentencoding.NewConfig(contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1}))
+	calls := map[string]int{}
+	resolve := contentencoding.CachedResolver(1, func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	}, func(key string) *contentencoding.Config {
+		calls[key]++
+		if key == "small" {
+			return small
+		}
+		return nil
+	})
+	h := contentencoding.Decode(contentencoding.WithConfigResolver(resolve))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	tests := []struct {
+		tenant string
+		want   int
+		calls  int
+	}{
+		{"small", http.StatusRequestEntityTooLarge, 1},
+		{"small", http.StatusRequestEntityTooLarge, 1},
+		{"other", http.StatusOK, 1},
+		{"small", http.StatusRequestEntityTooLarge, 2}, // evicted by other
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+tt.tenant, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "gzip")
+			req.Header.Set("X-Tenant", tt.tenant)
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+			if calls[tt.tenant] != tt.calls {
+				t.Errorf("resolve should be called %d times but got %d", tt.calls, calls[tt.tenant])
+			}
+		})
+	}
+}
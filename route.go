@@ -0,0 +1,60 @@
+package contentencoding
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// For returns a Middleware configured with opts for the route pattern,
+// e.g. "POST /ingest" of http.ServeMux or "/ingest/{id}" of chi, to configure each route in place:
+//
+//	mux.Handle("POST /ingest", contentencoding.For("POST /ingest", opts...).Handler(h))
+//
+// The pattern is reported as Stats.Route so that metrics can be tagged by route.
+// It is kept by Update.
+func For(route string, opts ...Option) *Middleware {
+	m := New(opts...)
+	m.route = route
+	return m
+}
+
+// handledKey marks requests whose bodies are decoded by a Middleware.
+type handledKey struct{}
+
+// ErrNestedDecode is reported to the error handler by a Middleware with WithRejectNested
+// for a request whose body is already decoded by another one, which is a mistake of the server configuration.
+// DefaultErrorHandler responds 500 Internal Server Error for it.
+var ErrNestedDecode = &StatusError{StatusCode: http.StatusInternalServerError, Err: errors.New("contentencoding: request already handled by another Decode")}
+
+// WithRejectNested returns a Option to report requests already decoded by another Middleware in the chain
+// as ErrNestedDecode, instead of passing them to the handler as they are, to find accidental nesting.
+// It applies to Decode and Middleware.
+func WithRejectNested() Option {
+	return func(cfg *config) {
+		cfg.rejectNested = true
+	}
+}
+
+// ScopedDecode returns net/http compatible middleware that decodes requests whose path starts with prefix
+// with its own configuration, e.g. larger limits for an upload API, and passes the other requests as they are.
+// It wraps the middleware applied to the whole server, which doesn't decode the requests handled by it again:
+//
+//	handler = contentencoding.ScopedDecode("/upload/", uploadOpts...)(contentencoding.Decode(opts...)(mux))
+//
+// The prefix is matched against r.URL.Path as http.StripPrefix does, but the path is not changed.
+// Requests handled by it are not rejected by WithRejectNested of the wrapped middleware.
+func ScopedDecode(prefix string, opts ...Option) func(next http.Handler) http.Handler {
+	m := New(opts...)
+	m.scoped = true
+	return func(next http.Handler) http.Handler {
+		scoped := m.Handler(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				scoped.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestFor(t *testing.T) {
+	var routes []string
+	hook := contentencoding.WithStatsHook(func(r *http.Request, s contentencoding.Stats) {
+		routes = append(routes, s.Route)
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	})
+	mux := http.NewServeMux()
+	ingest := contentencoding.For("/ingest", hook, contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1}))
+	mux.Handle("/ingest", ingest.Handler(handler))
+	mux.Handle("/upload", contentencoding.For("/upload", hook).Handler(handler))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/ingest", http.StatusRequestEntityTooLarge},
+		{"/upload", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, contentencodingtest.NewCompressedRequest(http.MethodPost, tt.path, []byte("test"), "gzip"))
+		if rec.Code != tt.want {
+			t.Errorf("status code of %s should be %d but got %d", tt.path, tt.want, rec.Code)
+		}
+	}
+	ingest.Update(hook)
+	mux.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/ingest", []byte("test"), "gzip"))
+
+	want := []string{"/ingest", "/upload", "/ingest"}
+	if len(routes) != len(want) {
+		t.Fatalf("routes should be %v but got %v", want, routes)
+	}
+	for i := range want {
+		if routes[i] != want[i] {
+			t.Errorf("routes should be %v but got %v", want, routes)
+		}
+	}
+}
+
+func TestScopedDecode(t *testing.T) {
+	payload := []byte("scoped decode test")
+	// the body decoded once is still gzip, so decoding it twice would give the payload.
+	once, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := contentencodingtest.CompressBody(once, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(b)
+	})
+	limits := contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 1})
+	h := contentencoding.ScopedDecode("/upload/", limits)(contentencoding.Decode()(handler))
+
+	tests := []struct {
+		name string
+		h    http.Handler
+		path string
+		want int
+	}{
+		{"scoped", h, "/upload/a", http.StatusRequestEntityTooLarge},
+		{"outside", h, "/other", http.StatusOK},
+		{"prefix without slash", h, "/upload", http.StatusOK},
+		{"nested Decode", contentencoding.Decode()(contentencoding.Decode()(handler)), "/", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(twice))
+			req.Header.Set("Content-Encoding", "gzip")
+			tt.h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("should be %d but got %d", tt.want, rec.Code)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), once) {
+				t.Error("body should be decoded once")
+			}
+		})
+	}
+}
+
+func TestMiddleware_nestedSkipped(t *testing.T) {
+	var got []byte
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = b
+	})
+	outer := contentencoding.Decode(contentencoding.WithMethods(http.MethodPut))
+	tests := []struct {
+		name string
+		h    http.Handler
+	}{
+		{"inner", outer(contentencoding.Decode()(handler))},
+		{"inner rejecting nested", outer(contentencoding.Decode(contentencoding.WithRejectNested())(handler))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			rec := httptest.NewRecorder()
+			req := contentencodingtest.NewCompressedRequest(http.MethodPost, "/", []byte("test"), "gzip")
+			tt.h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("should be 200 but got %d", rec.Code)
+			}
+			if string(got) != "test" {
+				t.Errorf("should be decoded by the inner middleware but got %q", got)
+			}
+		})
+	}
+}
+
+func TestWithRejectNested(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	reject := contentencoding.WithRejectNested()
+	tests := []struct {
+		name string
+		h    http.Handler
+		path string
+		want int
+	}{
+		{"nested", contentencoding.Decode()(contentencoding.Decode(reject)(handler)), "/", http.StatusInternalServerError},
+		{"not nested", contentencoding.Decode(reject)(handler), "/", http.StatusOK},
+		{"scoped", contentencoding.ScopedDecode("/upload/")(contentencoding.Decode(reject)(handler)), "/upload/a", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := contentencodingtest.NewCompressedRequest(http.MethodPost, tt.path, []byte("test"), "gzip")
+			tt.h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("should be %d but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package contentencoding
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ServeContent replies to the request like http.ServeContent, including the handling of modtime
+// and conditional requests, with the content encoded by the coding negotiated with Accept-Encoding.
+// variants are precompressed variants of content keyed by their coding, which are served as they are.
+// If no variant is preferred, content is compressed on the fly with a built-in coding.
+// Requests with Range receive the unencoded content, since ranges of an encoding computed on the fly are not stable,
+// and so do gRPC-web responses whose Content-Type is set by the caller.
+// A strong ETag set by the caller is made specific to each coding by a suffix, e.g. "v1" becomes "v1-gzip",
+// so that If-None-Match compares the representation actually sent.
+// Options other than WithEncodingPreference, WithCapabilityOverride, WithAssumedAcceptEncoding, WithoutVary,
+// WithMinSize, WithCompressContentTypes, WithSkipContentTypes, compression levels and encoder options such as WithZstdLongWindow are ignored.
+func ServeContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker, variants map[string]io.ReadSeeker, opts ...Option) {
+	cfg := newConfig(opts)
+	h := w.Header()
+	cfg.varyAcceptEncoding(h)
+	if r.Header.Get("Range") != "" || isGRPC(h.Get("Content-Type")) {
+		http.ServeContent(w, r, name, modtime, content)
+		return
+	}
+
+	offered := variantOrder(variants, cfg.preference)
+	for _, e := range cfg.preference {
+		if _, ok := variants[e]; !ok {
+			offered = append(offered, e)
+		}
+	}
+	coding, ok := cfg.negotiateRequest(r, offered)
+	if !ok || coding == "identity" {
+		http.ServeContent(w, r, name, modtime, content)
+		return
+	}
+	if _, ok := variants[coding]; !ok && cfg.minSize > 0 {
+		// the ETag must not be made specific to a coding that is not used.
+		size, err := content.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = content.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			http.Error(w, "seeker can't seek", http.StatusInternalServerError)
+			return
+		}
+		if size < int64(cfg.minSize) {
+			http.ServeContent(w, r, name, modtime, content)
+			return
+		}
+	}
+
+	if _, ok := h["Content-Type"]; !ok {
+		// the type must be detected from the unencoded content.
+		ctype, err := detectContentType(name, content)
+		if err != nil {
+			http.Error(w, "seeker can't seek", http.StatusInternalServerError)
+			return
+		}
+		h.Set("Content-Type", ctype)
+	}
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) && len(etag) > 1 {
+		h.Set("ETag", etag[:len(etag)-1]+"-"+coding+`"`)
+	}
+
+	if variant, ok := variants[coding]; ok {
+		h.Set("Content-Encoding", coding)
+		http.ServeContent(w, r, name, modtime, variant)
+		return
+	}
+	cw := cfg.newCompressWriter(w, r, coding)
+	defer cw.Close()
+	http.ServeContent(cw, cw.req, name, modtime, content)
+}
+
+// variantOrder returns the codings of variants in the order of preference, followed by the others by name.
+func variantOrder(variants map[string]io.ReadSeeker, preference []string) []string {
+	var order, others []string
+	for _, e := range preference {
+		if _, ok := variants[e]; ok {
+			order = append(order, e)
+		}
+	}
+	for e := range variants {
+		found := false
+		for _, o := range order {
+			found = found || o == e
+		}
+		if !found {
+			others = append(others, e)
+		}
+	}
+	sort.Strings(others)
+	return append(order, others...)
+}
+
+func detectContentType(name string, content io.ReadSeeker) (string, error) {
+	if ctype := mime.TypeByExtension(filepath.Ext(name)); ctype != "" {
+		return ctype, nil
+	}
+	var buf [512]byte
+	n, _ := io.ReadFull(content, buf[:])
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
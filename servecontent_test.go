@@ -0,0 +1,133 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestServeContent(t *testing.T) {
+	content := []byte(strings.Repeat("<p>test</p>", 100))
+	gz, err := contentencodingtest.CompressBody(content, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		header            http.Header
+		wantStatus        int
+		wantEncoding      string
+		wantETag          string
+		wantPrecompressed bool
+	}{
+		{"precompressed", http.Header{"Accept-Encoding": {"gzip"}}, http.StatusOK, "gzip", `"v1-gzip"`, true},
+		{"on the fly", http.Header{"Accept-Encoding": {"br"}}, http.StatusOK, "br", `"v1-br"`, false},
+		{"identity", http.Header{"Accept-Encoding": {"compress"}}, http.StatusOK, "", `"v1"`, false},
+		{"range", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}}, http.StatusPartialContent, "", `"v1"`, false},
+		{"If-None-Match", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`"v1-gzip"`}}, http.StatusNotModified, "", `"v1-gzip"`, false},
+		{"If-None-Match of other coding", http.Header{"Accept-Encoding": {"br"}, "If-None-Match": {`"v1-gzip"`}}, http.StatusOK, "br", `"v1-br"`, false},
+		{"If-Modified-Since", http.Header{"Accept-Encoding": {"br"}, "If-Modified-Since": {modtime.Format(http.TimeFormat)}}, http.StatusNotModified, "", `"v1-br"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = tt.header
+			rec := contentencodingtest.NewRecorder()
+			rec.Header().Set("ETag", `"v1"`)
+			contentencoding.ServeContent(rec, req, "index.html", modtime, bytes.NewReader(content),
+				map[string]io.ReadSeeker{"gzip": bytes.NewReader(gz)})
+
+			resp := rec.Result()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("should be %d but got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.wantEncoding, got)
+			}
+			if got := resp.Header.Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag should be %s but got %s", tt.wantETag, got)
+			}
+			if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary should be Accept-Encoding but got %s", got)
+			}
+			if tt.wantStatus == http.StatusNotModified {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+				t.Errorf("unexpected Content-Type %s", got)
+			}
+			if tt.wantPrecompressed && !bytes.Equal(rec.Body.Bytes(), gz) {
+				t.Error("precompressed variant should be served as it is")
+			}
+			b, err := rec.DecodedBody()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := content
+			if tt.wantStatus == http.StatusPartialContent {
+				want = content[:10]
+			}
+			if !bytes.Equal(b, want) {
+				t.Errorf("body differs: %s", b)
+			}
+		})
+	}
+}
+
+func TestServeContent_GRPCWeb(t *testing.T) {
+	content := []byte(strings.Repeat("frame", 100))
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/grpc-web+proto")
+	contentencoding.ServeContent(rec, req, "", time.Time{}, bytes.NewReader(content), nil)
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding should be empty but got %q", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), content) {
+		t.Error("body should not be encoded")
+	}
+}
+
+func TestServeContent_AssumedAcceptEncoding(t *testing.T) {
+	content := []byte(strings.Repeat("<p>test</p>", 100))
+	tests := []struct {
+		name   string
+		proto  string
+		accept string
+		opts   []contentencoding.Option
+		want   string
+	}{
+		{"missing", "HTTP/1.1", "", nil, ""},
+		{"HTTP/1.0", "HTTP/1.0", "gzip", nil, ""},
+		{"assumed for missing", "HTTP/1.1", "", []contentencoding.Option{contentencoding.WithAssumedAcceptEncoding("gzip")}, "gzip"},
+		{"HTTP/1.0 with option", "HTTP/1.0", "zstd", []contentencoding.Option{contentencoding.WithAssumedAcceptEncoding("gzip")}, "zstd"},
+		{"HTTP/1.0 missing with option", "HTTP/1.0", "", []contentencoding.Option{contentencoding.WithAssumedAcceptEncoding("gzip")}, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Proto = tt.proto
+			req.ProtoMajor, req.ProtoMinor, _ = http.ParseHTTPVersion(tt.proto)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			contentencoding.ServeContent(rec, req, "index.html", time.Time{}, bytes.NewReader(content), nil, tt.opts...)
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package contentencoding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrSessionBusy is reported to the error handler for a request of a session that another request is uploading to.
+// DefaultErrorHandler responds 409 Conflict for it.
+var ErrSessionBusy = &StatusError{StatusCode: http.StatusConflict, Err: errors.New("contentencoding: session is busy")}
+
+// ErrTooManySessions is reported to the error handler for a request that would start a session over WithMaxSessions.
+// DefaultErrorHandler responds 503 Service Unavailable for it.
+var ErrTooManySessions = &StatusError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("contentencoding: too many sessions")}
+
+// errSessionAborted is returned by reads of a session that expired, was closed or lost a part.
+var errSessionAborted = errors.New("contentencoding: session aborted")
+
+// Sessions decodes a stream compressed with br, gzip or zstd and uploaded in parts by requests,
+// each of which carries the next bytes of the stream and the session ID in a header,
+// so that a large upload can be streamed and resumed across requests without decoding it from the start.
+// The decoder state of each session is kept between requests until the stream ends or the session expires.
+//
+// The body of each request is decoded as far as the decoder can go with the bytes received so far,
+// and the rest is read by the next request of the session. The handler must read the body to the end,
+// otherwise the session is aborted since the decoded bytes can't be delivered again.
+// The part with "Upload-Complete: ?1", as in the resumable uploads draft of the IETF, ends the stream,
+// and so does the end of a brotli or single member gzip stream.
+// Requests without the header are passed to the handler as they are.
+//
+// Any client that knows a session ID can upload to the session, so the IDs must be unguessable,
+// e.g. 128 random bits issued by the server, or the requests must be authenticated
+// and the IDs scoped to the authenticated client, e.g. by a middleware that rewrites the header.
+type Sessions struct {
+	cfg    *config
+	header string
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// sessionWindow is the default MaxDecoderMemory of a session, which holds the window of its decoder
+// between requests. It is enough for the default windows of common encoders, 4MB of brotli and 8MB of zstd.
+const sessionWindow = 8 << 20
+
+// NewSessions returns Sessions identified by the request header that expire after ttl without a request.
+// WithLimits and WithDOptions apply to each session as a whole, WithMaxSessions to the sessions
+// and WithErrorHandler to the requests. The window of each decoder is bounded by MaxDecoderMemory of WithLimits,
+// which is 8MB unless it is set, since idle sessions keep their windows in memory.
+func NewSessions(header string, ttl time.Duration, opts ...Option) *Sessions {
+	cfg := newConfig(opts)
+	if cfg.limits.MaxDecoderMemory <= 0 {
+		cfg.limits.MaxDecoderMemory = sessionWindow
+	}
+	return &Sessions{cfg: cfg, header: header, ttl: ttl, sessions: make(map[string]*session)}
+}
+
+// WithMaxSessions returns a Option to bound the number of sessions of Sessions in progress to n.
+// A request that would start another session is rejected with ErrTooManySessions.
+// n <= 0 means no limit, which is the default.
+func WithMaxSessions(n int) Option {
+	return func(cfg *config) {
+		cfg.maxSessions = n
+	}
+}
+
+// SessionPart describes the part of a session uploaded by a request, see SessionPartFromRequest.
+type SessionPart struct {
+	// ID is the session ID.
+	ID string
+	// Offset is the size of the stream decoded by the previous requests of the session.
+	Offset int64
+	// Complete reports whether the decoded stream has ended, it is set when the body is read to the end.
+	Complete bool
+}
+
+type sessionPartKey struct{}
+
+// SessionPartFromRequest returns the SessionPart of r, or nil if r was not decoded by Sessions.
+func SessionPartFromRequest(r *http.Request) *SessionPart {
+	p, _ := r.Context().Value(sessionPartKey{}).(*SessionPart)
+	return p
+}
+
+// Len returns the number of sessions in progress.
+func (s *Sessions) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Close aborts all sessions.
+func (s *Sessions) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		sess.abort()
+		delete(s.sessions, id)
+	}
+	return nil
+}
+
+// Handler returns next wrapped to decode the parts of sessions.
+func (s *Sessions) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(s.header)
+		if id == "" || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		codings, err := contentCodings(r.Header.Get("Content-Encoding"))
+		if err != nil {
+			s.cfg.handleError(w, r, err)
+			return
+		}
+		if len(codings) != 1 || (codings[0] != "br" && codings[0] != "gzip" && codings[0] != "zstd") {
+			s.cfg.handleError(w, r, &UnsupportedEncodingError{Coding: strings.Join(codings, ", ")})
+			return
+		}
+		sess, err := s.acquire(id, codings[0])
+		if err != nil {
+			s.cfg.handleError(w, r, err)
+			return
+		}
+		part := &SessionPart{ID: id, Offset: sess.decoded}
+		body := &sessionBody{sess: sess, part: part}
+		sess.parts <- sessionChunk{body: r.Body, final: r.Header.Get("Upload-Complete") == "?1"}
+		defer s.release(sess, body)
+
+		r = r.WithContext(context.WithValue(r.Context(), sessionPartKey{}, part))
+		r.Body = body
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire returns the session of id for a request, which is started if it doesn't exist.
+func (s *Sessions) acquire(id, coding string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		if s.cfg.maxSessions > 0 && len(s.sessions) >= s.cfg.maxSessions {
+			return nil, ErrTooManySessions
+		}
+		sess = s.start(id, coding)
+		s.sessions[id] = sess
+	}
+	if sess.coding != coding {
+		return nil, &UnsupportedEncodingError{Coding: coding}
+	}
+	if sess.busy {
+		return nil, ErrSessionBusy
+	}
+	sess.busy = true
+	sess.timer.Stop()
+	return sess, nil
+}
+
+// release ends the request of body, the session is removed if its stream ended or the body was not read to the end.
+// It returns after the decoder has stopped reading the body of the request, which must not be read after the handler returns.
+func (s *Sessions) release(sess *session, body *sessionBody) {
+	s.mu.Lock()
+	sess.busy = false
+	sess.decoded = body.part.Offset + body.n
+	if body.err == io.EOF && !body.part.Complete {
+		// the part has been read to the end.
+		sess.timer.Reset(s.ttl)
+		s.mu.Unlock()
+		return
+	}
+	sess.abort()
+	if s.sessions[sess.id] == sess {
+		delete(s.sessions, sess.id)
+	}
+	s.mu.Unlock()
+	<-sess.done
+}
+
+// expire removes sess if no request is uploading to it.
+func (s *Sessions) expire(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess.busy || s.sessions[sess.id] != sess {
+		return
+	}
+	sess.abort()
+	delete(s.sessions, sess.id)
+}
+
+// start starts the decoder of a session, which reads the parts given by the requests as one stream.
+func (s *Sessions) start(id, coding string) *session {
+	sess := &session{
+		id:     id,
+		coding: coding,
+		parts:  make(chan sessionChunk, 1),
+		out:    make(chan []byte),
+		idle:   make(chan struct{}),
+		done:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	sess.timer = time.AfterFunc(s.ttl, func() { s.expire(sess) })
+	go sess.run(s.cfg)
+	return sess
+}
+
+// session is the decoder state of a stream uploaded by requests one at a time.
+type session struct {
+	id     string
+	coding string
+	timer  *time.Timer
+
+	// busy and decoded are guarded by the mutex of Sessions.
+	busy    bool
+	decoded int64
+
+	parts chan sessionChunk
+	// out sends the decoded bytes, idle tells that the current part has been read,
+	// and done is closed with err when the stream ends.
+	out  chan []byte
+	idle chan struct{}
+	done chan struct{}
+	err  error
+
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+type sessionChunk struct {
+	body  io.Reader
+	final bool
+}
+
+func (sess *session) abort() {
+	sess.stopped.Do(func() { close(sess.stop) })
+}
+
+func (sess *session) run(cfg *config) {
+	src := &sessionSource{sess: sess}
+	dec, err := sess.newDecoder(cfg, src)
+	if err == nil {
+		err = sess.pump(cfg.limits.limitBody(dec, func() int64 { return src.n }))
+		dec.Close()
+	}
+	sess.err = err
+	close(sess.done)
+}
+
+func (sess *session) newDecoder(cfg *config, src io.Reader) (io.ReadCloser, error) {
+	switch sess.coding {
+	case "br":
+		return io.NopCloser(brotli.NewReader(cfg.limits.limitBrotliWindow(src))), nil
+	case "gzip":
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+	// the synchronous decoder reads the stream only as far as the output needs.
+	zr, err := zstd.NewReader(src, append(append([]zstd.DOption{}, cfg.zstdOptions()...), zstd.WithDecoderConcurrency(1))...)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.limits.limitMemory(zr.IOReadCloser()), nil
+}
+
+// pump sends the decoded bytes to the requests until the stream ends.
+func (sess *session) pump(dec io.Reader) error {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := dec.Read(buf)
+		if n > 0 {
+			select {
+			case sess.out <- append([]byte(nil), buf[:n]...):
+			case <-sess.stop:
+				return errSessionAborted
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sessionSource reads the parts of the requests as one stream.
+type sessionSource struct {
+	sess    *session
+	cur     *sessionChunk
+	started bool
+	n       int64
+}
+
+func (src *sessionSource) Read(p []byte) (int, error) {
+	for {
+		select {
+		case <-src.sess.stop:
+			// the request may have returned, its body must not be read any more.
+			return 0, errSessionAborted
+		default:
+		}
+		if src.cur != nil {
+			n, err := src.cur.body.Read(p)
+			src.n += int64(n)
+			if err == io.EOF {
+				if src.cur.final {
+					return n, io.EOF
+				}
+				src.cur = nil
+				err = nil
+			}
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		if src.started {
+			// the part has been read, its request ends here.
+			select {
+			case src.sess.idle <- struct{}{}:
+			case <-src.sess.stop:
+				return 0, errSessionAborted
+			}
+		}
+		src.started = true
+		select {
+		case c := <-src.sess.parts:
+			src.cur = &c
+		case <-src.sess.stop:
+			return 0, errSessionAborted
+		}
+	}
+}
+
+// sessionBody is the body of a request with the bytes decoded from its part.
+type sessionBody struct {
+	sess    *session
+	part    *SessionPart
+	pending []byte
+	n       int64
+	err     error
+}
+
+func (b *sessionBody) Read(p []byte) (int, error) {
+	if len(b.pending) == 0 && b.err == nil {
+		select {
+		case b.pending = <-b.sess.out:
+		case <-b.sess.idle:
+			b.err = io.EOF
+		case <-b.sess.done:
+			b.err = b.sess.err
+			if b.err == io.EOF {
+				b.part.Complete = true
+			}
+		case <-b.sess.stop:
+			b.err = errSessionAborted
+		}
+	}
+	if len(b.pending) > 0 {
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		b.n += int64(n)
+		return n, nil
+	}
+	return 0, b.err
+}
+
+func (b *sessionBody) Close() error {
+	return nil
+}
@@ -0,0 +1,210 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestSessions(t *testing.T) {
+	payload := bytes.Repeat([]byte("session test "), 10000)
+	for _, coding := range []string{"br", "gzip", "zstd"} {
+		t.Run(coding, func(t *testing.T) {
+			encoded, err := contentencodingtest.CompressBody(payload, coding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := contentencoding.NewSessions("Upload-Session", time.Minute)
+			defer s.Close()
+			var decoded []byte
+			var complete bool
+			h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				part := contentencoding.SessionPartFromRequest(r)
+				if part.Offset != int64(len(decoded)) {
+					t.Errorf("offset should be %d but got %d", len(decoded), part.Offset)
+				}
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded = append(decoded, b...)
+				complete = part.Complete
+			}))
+
+			parts := [][]byte{encoded[:10], encoded[10 : len(encoded)/2], encoded[len(encoded)/2:]}
+			for i, p := range parts {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(p))
+				req.Header.Set("Content-Encoding", coding)
+				req.Header.Set("Upload-Session", "1")
+				if i == len(parts)-1 {
+					req.Header.Set("Upload-Complete", "?1")
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("should be 200 but got %d", rec.Code)
+				}
+			}
+			if !bytes.Equal(decoded, payload) {
+				t.Errorf("decoded stream is wrong, %d bytes of %d", len(decoded), len(payload))
+			}
+			if !complete {
+				t.Error("session should be complete")
+			}
+			if n := s.Len(); n != 0 {
+				t.Errorf("sessions should be 0 but got %d", n)
+			}
+		})
+	}
+}
+
+func TestSessions_Expire(t *testing.T) {
+	encoded, err := contentencodingtest.CompressBody([]byte("expire test"), "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := contentencoding.NewSessions("Upload-Session", 10*time.Millisecond)
+	defer s.Close()
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded[:5]))
+	req.Header.Set("Content-Encoding", "zstd")
+	req.Header.Set("Upload-Session", "1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if n := s.Len(); n != 1 {
+		t.Fatalf("sessions should be 1 but got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := s.Len(); n != 0 {
+		t.Errorf("sessions should be 0 but got %d", n)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded[:5]))
+	req.Header.Set("Content-Encoding", "br")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if n := s.Len(); n != 0 {
+		t.Errorf("requests without session should be passed but got %d sessions", n)
+	}
+}
+
+func TestSessions_MaxSessions(t *testing.T) {
+	encoded, err := contentencodingtest.CompressBody([]byte("max sessions test"), "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := contentencoding.NewSessions("Upload-Session", time.Minute, contentencoding.WithMaxSessions(1))
+	defer s.Close()
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusServiceUnavailable},
+		{"1", http.StatusOK}, // the session in progress is continued.
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded[:5]))
+		req.Header.Set("Content-Encoding", "zstd")
+		req.Header.Set("Upload-Session", tt.id)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("session %s should be %d but got %d", tt.id, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestSessions_window(t *testing.T) {
+	// a zstd frame declaring the window of 16MB with a raw last block.
+	frame := append([]byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x70, 0x59, 0x00, 0x00}, "window test"...)
+
+	tests := []struct {
+		name string
+		opts []contentencoding.Option
+		want int
+	}{
+		{"default", nil, http.StatusRequestEntityTooLarge},
+		{"MaxDecoderMemory", []contentencoding.Option{contentencoding.WithLimits(contentencoding.Limits{MaxDecoderMemory: 32 << 20})}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := contentencoding.NewSessions("Upload-Session", time.Minute, tt.opts...)
+			defer s.Close()
+			h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var limitErr *contentencoding.LimitError
+				if _, err := io.ReadAll(r.Body); errors.As(err, &limitErr) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+				} else if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(frame))
+			req.Header.Set("Content-Encoding", "zstd")
+			req.Header.Set("Upload-Session", "1")
+			req.Header.Set("Upload-Complete", "?1")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("should be %d but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+// watchedBody reports reads after the request has returned.
+type watchedBody struct {
+	io.Reader
+	t        *testing.T
+	returned int32
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if atomic.LoadInt32(&b.returned) != 0 {
+		b.t.Error("body should not be read after the request returned")
+	}
+	return n, err
+}
+
+func TestSessions_abort(t *testing.T) {
+	encoded, err := contentencodingtest.CompressBody(bytes.Repeat([]byte("abort test "), 10000), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := contentencoding.NewSessions("Upload-Session", time.Minute)
+	defer s.Close()
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the handler returns without reading the body to the end.
+		io.ReadFull(r.Body, make([]byte, 10))
+	}))
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(encoded[:len(encoded)/2])
+		// the decoder is blocked reading the body until it is closed.
+		time.Sleep(20 * time.Millisecond)
+		pw.Close()
+	}()
+	body := &watchedBody{Reader: pr, t: t}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Upload-Session", "1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	atomic.StoreInt32(&body.returned, 1)
+	time.Sleep(20 * time.Millisecond)
+	if n := s.Len(); n != 0 {
+		t.Errorf("sessions should be 0 but got %d", n)
+	}
+}
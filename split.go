@@ -0,0 +1,122 @@
+package contentencoding
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// errSplitClosed is returned by reads of a closed reader of Split.
+var errSplitClosed = errors.New("contentencoding: read on closed split reader")
+
+// Split returns n readers that each read the whole stream of r, e.g. a decoded request body,
+// so that a validator and the main processor can consume the same upload concurrently.
+// Up to bufSize bytes read from r are held until all readers have read them, 32KB if bufSize is not positive,
+// so the readers must be read concurrently, a reader falling behind by bufSize blocks the others.
+// Close a reader that is no longer needed so that it does not block the others.
+// Closing the readers does not close r.
+func Split(r io.Reader, n, bufSize int) []io.ReadCloser {
+	if bufSize <= 0 {
+		bufSize = 32 << 10
+	}
+	s := &splitter{src: r, max: bufSize, pos: make([]int64, n)}
+	s.cond = sync.NewCond(&s.mu)
+	readers := make([]io.ReadCloser, n)
+	for i := range readers {
+		readers[i] = &splitReader{s: s, i: i}
+	}
+	return readers
+}
+
+// splitter holds the bytes read from src from the offset base until all readers have read them.
+type splitter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	src     io.Reader
+	max     int
+	buf     []byte
+	base    int64
+	err     error
+	filling bool
+	// pos is the offset of each reader, -1 if it is closed.
+	pos []int64
+}
+
+func (s *splitter) read(i int, p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.pos[i] < 0 {
+			return 0, errSplitClosed
+		}
+		if off := s.pos[i] - s.base; off < int64(len(s.buf)) {
+			n := copy(p, s.buf[off:])
+			s.pos[i] += int64(n)
+			s.trim()
+			return n, nil
+		}
+		if s.err != nil {
+			return 0, s.err
+		}
+		if !s.filling && len(s.buf) < s.max {
+			s.fill()
+			continue
+		}
+		s.cond.Wait()
+	}
+}
+
+// fill reads from src into the free space of buf, the lock is released during the read.
+func (s *splitter) fill() {
+	s.filling = true
+	tmp := make([]byte, s.max-len(s.buf))
+	s.mu.Unlock()
+	n, err := s.src.Read(tmp)
+	s.mu.Lock()
+	s.buf = append(s.buf, tmp[:n]...)
+	if err != nil {
+		s.err = err
+	}
+	s.filling = false
+	s.cond.Broadcast()
+}
+
+// trim drops the bytes read by all open readers.
+func (s *splitter) trim() {
+	low := s.base + int64(len(s.buf))
+	for _, p := range s.pos {
+		if p >= 0 && p < low {
+			low = p
+		}
+	}
+	if d := int(low - s.base); d > 0 {
+		s.buf = s.buf[:copy(s.buf, s.buf[d:])]
+		s.base = low
+		s.cond.Broadcast()
+	}
+}
+
+func (s *splitter) close(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pos[i] = -1
+	s.trim()
+	s.cond.Broadcast()
+}
+
+type splitReader struct {
+	s *splitter
+	i int
+}
+
+func (r *splitReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.s.read(r.i, p)
+}
+
+func (r *splitReader) Close() error {
+	r.s.close(r.i)
+	return nil
+}
@@ -0,0 +1,61 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestSplit(t *testing.T) {
+	payload := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(payload)
+
+	h := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readers := contentencoding.Split(r.Body, 3, 1024)
+		// the third reader gives up early and must not block the others.
+		readers[2].Read(make([]byte, 10))
+		readers[2].Close()
+
+		var wg sync.WaitGroup
+		for _, sr := range readers[:2] {
+			wg.Add(1)
+			go func(sr io.ReadCloser) {
+				defer wg.Done()
+				defer sr.Close()
+				b, err := ioutil.ReadAll(sr)
+				if err != nil {
+					t.Error(err)
+				}
+				if !bytes.Equal(b, payload) {
+					t.Errorf("should read the payload but got %d bytes", len(b))
+				}
+			}(sr)
+		}
+		wg.Wait()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", payload, "zstd"))
+}
+
+func TestSplit_error(t *testing.T) {
+	want := errors.New("broken")
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("test"))
+		pw.CloseWithError(want)
+	}()
+	for _, sr := range contentencoding.Split(pr, 2, 0) {
+		b, err := ioutil.ReadAll(sr)
+		if string(b) != "test" || !errors.Is(err, want) {
+			t.Errorf("should read test and the error but got %q, %v", b, err)
+		}
+	}
+}
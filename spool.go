@@ -0,0 +1,179 @@
+package contentencoding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// ErrSpoolIncomplete is returned by Spool.Open when the handler has not read the decoded body to the end.
+var ErrSpoolIncomplete = errors.New("contentencoding: spool is incomplete")
+
+// WithSpool returns a Option to re-encode the decoded request body with encoding while the handler reads it,
+// into a Spool ready to persist, e.g. to upload to a blob storage without compressing the payload again.
+// The Spool of a request is returned by SpoolFromRequest.
+// Up to maxMemory bytes of the encoded payload are kept in memory, the rest is written to a temporary file,
+// which is removed after the handler returns.
+// Requests with codings without decoder are not spooled.
+// encoding must be one of br, gzip, zstd and identity, it applies to Decode and Middleware.
+func WithSpool(encoding string, maxMemory int64) Option {
+	encoding = CanonicalCoding(encoding)
+	if _, ok, _ := builtinWriter(encoding, io.Discard); !ok && encoding != "identity" {
+		panic("contentencoding: unsupported coding for WithSpool: " + encoding)
+	}
+	return func(cfg *config) {
+		cfg.spoolEncoding = encoding
+		cfg.spoolMaxMemory = maxMemory
+	}
+}
+
+// Spool is the request payload re-encoded by WithSpool.
+type Spool struct {
+	cfg       *config
+	encoding  string
+	maxMemory int64
+
+	buf      bytes.Buffer
+	file     *os.File
+	size     int64
+	enc      io.WriteCloser
+	complete bool
+	err      error
+}
+
+type spoolKey struct{}
+
+// SpoolFromRequest returns the Spool of r, or nil if r is not spooled.
+func SpoolFromRequest(r *http.Request) *Spool {
+	s, _ := r.Context().Value(spoolKey{}).(*Spool)
+	return s
+}
+
+// Encoding returns the coding of the spooled payload.
+func (s *Spool) Encoding() string {
+	return s.encoding
+}
+
+// Complete reports whether the whole payload is spooled, that is the handler has read the decoded body to the end.
+func (s *Spool) Complete() bool {
+	return s.complete
+}
+
+// Size returns the number of bytes spooled.
+func (s *Spool) Size() int64 {
+	return s.size
+}
+
+// Open returns a reader of the encoded payload.
+// It can be called more than once, the readers are valid until the handler returns.
+func (s *Spool) Open() (io.ReadSeeker, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if !s.complete {
+		return nil, ErrSpoolIncomplete
+	}
+	if s.file != nil {
+		return io.NewSectionReader(s.file, 0, s.size), nil
+	}
+	return bytes.NewReader(s.buf.Bytes()), nil
+}
+
+// spoolSink is the destination of the encoder.
+type spoolSink struct {
+	s *Spool
+}
+
+func (sink spoolSink) Write(p []byte) (int, error) {
+	s := sink.s
+	if s.file == nil && int64(s.buf.Len()+len(p)) > s.maxMemory {
+		f, err := os.CreateTemp("", "contentencoding-spool-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := f.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf = bytes.Buffer{}
+	}
+	var (
+		n   int
+		err error
+	)
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+// write encodes p into the spool.
+func (s *Spool) write(p []byte) {
+	if s.err != nil || len(p) == 0 {
+		return
+	}
+	if s.enc == nil {
+		if s.encoding == "identity" {
+			s.enc = nopWriteCloser{spoolSink{s}}
+		} else if s.enc, s.err = s.cfg.encoder(s.encoding, spoolSink{s}); s.err != nil {
+			return
+		}
+	}
+	_, s.err = s.enc.Write(p)
+}
+
+// finish closes the encoder at the end of the payload.
+func (s *Spool) finish() {
+	if s.err != nil {
+		return
+	}
+	if s.enc == nil {
+		s.write([]byte{}) // an empty payload is still encoded.
+	}
+	if s.err = s.enc.Close(); s.err == nil {
+		s.complete = true
+	}
+}
+
+// cleanup removes the temporary file.
+func (s *Spool) cleanup() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
+
+// spool wraps r.Body to be spooled if WithSpool is used, it returns the cleanup function.
+func (cfg *config) spool(r *http.Request) (*http.Request, func()) {
+	if cfg.spoolEncoding == "" {
+		return r, func() {}
+	}
+	s := &Spool{cfg: cfg, encoding: cfg.spoolEncoding, maxMemory: cfg.spoolMaxMemory}
+	r.Body = &spoolBody{ReadCloser: r.Body, s: s}
+	return r.WithContext(context.WithValue(r.Context(), spoolKey{}, s)), s.cleanup
+}
+
+type spoolBody struct {
+	io.ReadCloser
+	s   *Spool
+	eof bool
+}
+
+func (b *spoolBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.eof {
+		return n, err
+	}
+	b.s.write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+		b.s.finish()
+	}
+	return n, err
+}
@@ -0,0 +1,89 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithSpool(t *testing.T) {
+	payload := bytes.Repeat([]byte("spool test "), 1000)
+	tests := []struct {
+		name      string
+		encoding  string
+		target    string
+		maxMemory int64
+		read      bool
+		wantErr   error
+	}{
+		{"memory", "gzip", "zstd", 1 << 20, true, nil},
+		{"file", "br, gzip", "gzip", 16, true, nil},
+		{"identity request", "identity", "br", 1 << 20, true, nil},
+		{"identity spool", "zstd", "identity", 16, true, nil},
+		{"incomplete", "gzip", "zstd", 1 << 20, false, contentencoding.ErrSpoolIncomplete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := contentencoding.Decode(contentencoding.WithSpool(tt.target, tt.maxMemory))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if tt.read {
+					b, err := ioutil.ReadAll(r.Body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(b, payload) {
+						t.Error("decoded body should be the payload")
+					}
+				}
+				s := contentencoding.SpoolFromRequest(r)
+				if s == nil {
+					t.Fatal("spool should be found")
+				}
+				sr, err := s.Open()
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error should be %v but got %v", tt.wantErr, err)
+				}
+				if err != nil {
+					return
+				}
+				encoded, err := ioutil.ReadAll(sr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(len(encoded)) != s.Size() {
+					t.Errorf("size should be %d but got %d", len(encoded), s.Size())
+				}
+				got, err := contentencoding.DecodeBytes(s.Encoding(), encoded, contentencoding.Limits{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Error("spooled payload should be decoded to the payload")
+				}
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", payload, strings.Split(tt.encoding, ", ")...))
+			if !called {
+				t.Error("handler should be called")
+			}
+		})
+	}
+}
+
+func TestWithSpool_undecoded(t *testing.T) {
+	h := contentencoding.Decode(contentencoding.WithSpool("gzip", 0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentencoding.SpoolFromRequest(r) != nil {
+			t.Error("request with unknown coding should not be spooled")
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("test")))
+	req.Header.Set("Content-Encoding", "unknown")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
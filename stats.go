@@ -0,0 +1,127 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Stats are the statistics of decoding a request body.
+type Stats struct {
+	// ContentEncoding is the Content-Encoding of the request with canonical codings, see CanonicalCoding,
+	// so that it can be used as a metric label. Since the header is controlled by clients, it is "other"
+	// if it is malformed, has a coding without a decoder or has more than three codings.
+	ContentEncoding string
+	// EncodedBytes is the number of encoded bytes read from the request body while decoding.
+	EncodedBytes int64
+	// DecodedBytes is the number of decoded bytes read by the handler.
+	DecodedBytes int64
+	// Complete reports whether the handler read the decoded body to the end.
+	Complete bool
+	// DrainedBytes is the number of encoded bytes discarded after the handler returned, see WithDrain.
+	DrainedBytes int64
+	// CPUTime is the CPU time spent reading the decoded body, see WithCPUAccounting.
+	CPUTime time.Duration
+	// Route is the route pattern given to For, empty for other middleware.
+	Route string
+	// Err is the error of reading the decoded body other than io.EOF, e.g. of corrupt data or limits.
+	Err error
+}
+
+// StatsHook is called with the statistics of each decoded request after the handler returns.
+type StatsHook func(r *http.Request, s Stats)
+
+// WithStatsHook returns a Option to receive the statistics of decoded requests.
+func WithStatsHook(hook StatsHook) Option {
+	return func(cfg *config) {
+		cfg.statsHook = hook
+	}
+}
+
+// WithDrain returns a Option to define what happens to the rest of the request body
+// when the handler returns without reading the decoded body to the end.
+// If maxBytes is positive, up to maxBytes of the encoded body are read and discarded without decoding,
+// so the connection can be reused without wasting decompression work.
+// Otherwise the body is closed immediately, which is the default.
+func WithDrain(maxBytes int64) Option {
+	return func(cfg *config) {
+		cfg.drainMax = maxBytes
+	}
+}
+
+// countingBody counts the bytes read from the body and whether it reached EOF or failed.
+type countingBody struct {
+	io.ReadCloser
+	n   int64
+	eof bool
+	err error
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.eof = true
+	} else if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// finish drains the rest of the encoded body, closes the decoded body and reports the statistics.
+func (cfg *config) finish(r *http.Request, route string, encoded, decoded *countingBody, cpu *cpuBody, body io.Closer) {
+	var drained int64
+	if cfg.drainMax > 0 && !decoded.eof && !encoded.eof && r.Context().Err() == nil {
+		drained, _ = io.CopyN(io.Discard, encoded.ReadCloser, cfg.drainMax)
+	}
+	body.Close()
+	if cfg.statsHook == nil && cfg.aggregator == nil {
+		return
+	}
+	var cpuTime time.Duration
+	if cpu != nil {
+		cpuTime = cpu.d
+	}
+	s := Stats{
+		ContentEncoding: cfg.statsEncoding(r.Header.Get("Content-Encoding")),
+		EncodedBytes:    encoded.n,
+		DecodedBytes:    decoded.n,
+		Complete:        decoded.eof,
+		DrainedBytes:    drained,
+		CPUTime:         cpuTime,
+		Route:           route,
+		Err:             decoded.err,
+	}
+	if cfg.statsHook != nil {
+		cfg.statsHook(r, s)
+	}
+	cfg.aggregator.observe(s)
+}
+
+// maxStatsLayers is the maximum number of codings of Stats.ContentEncoding other than "other".
+const maxStatsLayers = 3
+
+// statsEncoding returns raw with canonical codings, or "other" if it is not a known value of a bounded set.
+func (cfg *config) statsEncoding(raw string) string {
+	codings, err := contentCodings(raw)
+	if err != nil || len(codings) > maxStatsLayers {
+		return "other"
+	}
+	for _, c := range codings {
+		if c != "identity" && !isBuiltin(c) && !cfg.hasDecoder(c) {
+			return "other"
+		}
+	}
+	return strings.Join(codings, ", ")
+}
+
+// hasDecoder reports whether coding has a decoder given by WithDecoder.
+func (cfg *config) hasDecoder(coding string) bool {
+	for _, d := range cfg.decoders {
+		if CanonicalCoding(d.Encoding) == coding {
+			return true
+		}
+	}
+	return false
+}
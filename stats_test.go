@@ -0,0 +1,115 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestWithStatsHook(t *testing.T) {
+	// random data is not compressible, so the encoded body is larger than a single read.
+	payload := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(payload)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         []contentencoding.Option
+		read         int64
+		wantComplete bool
+		wantDrained  bool
+	}{
+		{"read all", nil, -1, true, false},
+		{"early exit", nil, 10, false, false},
+		{"early exit with drain", []contentencoding.Option{contentencoding.WithDrain(1 << 30)}, 10, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got contentencoding.Stats
+			opts := append(tt.opts, contentencoding.WithStatsHook(func(r *http.Request, s contentencoding.Stats) {
+				got = s
+			}))
+			h := contentencoding.Decode(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.read < 0 {
+					ioutil.ReadAll(r.Body)
+					return
+				}
+				io.CopyN(ioutil.Discard, r.Body, tt.read)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+			req.Header.Set("Content-Encoding", "gzip")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got.ContentEncoding != "gzip" {
+				t.Errorf("ContentEncoding should be gzip but got %s", got.ContentEncoding)
+			}
+			if got.Complete != tt.wantComplete {
+				t.Errorf("Complete should be %v but got %v", tt.wantComplete, got.Complete)
+			}
+			if tt.wantComplete && (got.DecodedBytes != int64(len(payload)) || got.EncodedBytes != int64(len(encoded))) {
+				t.Errorf("unexpected stats %+v", got)
+			}
+			if !tt.wantComplete && got.DecodedBytes != tt.read {
+				t.Errorf("DecodedBytes should be %d but got %d", tt.read, got.DecodedBytes)
+			}
+			if tt.wantDrained {
+				if got.EncodedBytes+got.DrainedBytes != int64(len(encoded)) {
+					t.Errorf("whole body should be consumed %+v", got)
+				}
+			} else if got.DrainedBytes != 0 {
+				t.Errorf("DrainedBytes should be 0 but got %d", got.DrainedBytes)
+			}
+		})
+	}
+}
+
+func TestStats_ContentEncoding(t *testing.T) {
+	encoded, err := contentencodingtest.CompressBody([]byte("test"), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := &contentencoding.Decoder{
+		Encoding: "custom",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	}
+	tests := []struct {
+		encoding string
+		want     string
+	}{
+		{"X-GZIP", "gzip"},
+		{"custom, gzip", "custom, gzip"},
+		{"unknown-123, gzip", "other"},
+		{"custom, custom, custom, gzip", "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			var got contentencoding.Stats
+			h := contentencoding.Decode(contentencoding.WithDecoder(custom), contentencoding.WithStatsHook(func(r *http.Request, s contentencoding.Stats) {
+				got = s
+			}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ioutil.ReadAll(r.Body)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got.ContentEncoding != tt.want {
+				t.Errorf("ContentEncoding should be %q but got %q", tt.want, got.ContentEncoding)
+			}
+		})
+	}
+}
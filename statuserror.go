@@ -0,0 +1,118 @@
+package contentencoding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+)
+
+// ErrorCategory is the category of errors of decoding, see WithStatusCodes.
+type ErrorCategory int
+
+const (
+	// ErrorCorrupt is malformed Content-Encoding or encoded data, and any other error not in the categories below.
+	ErrorCorrupt ErrorCategory = iota
+	// ErrorUnsupported is UnsupportedEncodingError.
+	ErrorUnsupported
+	// ErrorTooLarge is LimitError.
+	ErrorTooLarge
+	// ErrorTimeout is the deadline of the request context or of reading the body.
+	ErrorTimeout
+)
+
+// Categorize returns the category of err.
+func Categorize(err error) ErrorCategory {
+	var (
+		limitErr       *LimitError
+		unsupportedErr *UnsupportedEncodingError
+		netErr         net.Error
+	)
+	switch {
+	case errors.As(err, &limitErr):
+		return ErrorTooLarge
+	case errors.As(err, &unsupportedErr):
+		return ErrorUnsupported
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	default:
+		return ErrorCorrupt
+	}
+}
+
+// StatusError is an error with the status code of the response, given by WithStatusCodes.
+// DefaultErrorHandler and JSONErrorHandler respond StatusCode for it.
+type StatusError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// Err is the error of decoding.
+	Err error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// WithStatusCodes returns a Option to choose the status codes of errors by their categories,
+// e.g. map[ErrorCategory]int{ErrorTimeout: http.StatusRequestTimeout}, without writing a whole ErrorHandler.
+// Errors of the categories in codes are passed to the error handler as StatusError,
+// and errors read from the decoded body are returned as StatusError too,
+// so that handlers can respond consistently. Errors of the other categories are left as they are.
+func WithStatusCodes(codes map[ErrorCategory]int) Option {
+	copied := make(map[ErrorCategory]int, len(codes))
+	for c, code := range codes {
+		copied[c] = code
+	}
+	return func(cfg *config) {
+		cfg.statusCodes = copied
+	}
+}
+
+// handleError calls the error handler with err mapped by WithStatusCodes, and counts the rejection of r.
+func (cfg *config) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if cfg.aggregator != nil {
+		cfg.aggregator.reject(cfg.statsEncoding(r.Header.Get("Content-Encoding")))
+	}
+	cfg.handleReadError(w, r, err)
+}
+
+// handleReadError calls the error handler for an error of reading the decoded body,
+// which is counted by the statistics of the request instead of as a rejection.
+func (cfg *config) handleReadError(w http.ResponseWriter, r *http.Request, err error) {
+	cfg.errHandler(w, r, cfg.mapStatus(err))
+}
+
+func (cfg *config) mapStatus(err error) error {
+	if cfg.statusCodes == nil || err == nil {
+		return err
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return err
+	}
+	if code, ok := cfg.statusCodes[Categorize(err)]; ok {
+		return &StatusError{StatusCode: code, Err: err}
+	}
+	return err
+}
+
+// statusBody maps the errors of reading the decoded body by WithStatusCodes.
+type statusBody struct {
+	io.ReadCloser
+	cfg *config
+}
+
+func (b *statusBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.cfg.mapStatus(err)
+	}
+	return n, err
+}
@@ -0,0 +1,94 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestCategorize(t *testing.T) {
+	tests := []struct {
+		err  error
+		want contentencoding.ErrorCategory
+	}{
+		{errors.New("gzip: invalid header"), contentencoding.ErrorCorrupt},
+		{&contentencoding.UnsupportedEncodingError{Coding: "deflate"}, contentencoding.ErrorUnsupported},
+		{fmt.Errorf("wrapped: %w", &contentencoding.LimitError{Limit: "MaxLayers", Max: 1}), contentencoding.ErrorTooLarge},
+		{context.DeadlineExceeded, contentencoding.ErrorTimeout},
+		{os.ErrDeadlineExceeded, contentencoding.ErrorTimeout},
+	}
+	for _, tt := range tests {
+		if got := contentencoding.Categorize(tt.err); got != tt.want {
+			t.Errorf("category of %v should be %d but got %d", tt.err, tt.want, got)
+		}
+	}
+}
+
+type timeoutReader struct{}
+
+func (timeoutReader) Read(p []byte) (int, error) {
+	return 0, os.ErrDeadlineExceeded
+}
+
+func TestWithStatusCodes(t *testing.T) {
+	codes := map[contentencoding.ErrorCategory]int{
+		contentencoding.ErrorCorrupt:     http.StatusUnprocessableEntity,
+		contentencoding.ErrorUnsupported: http.StatusNotImplemented,
+		contentencoding.ErrorTimeout:     http.StatusRequestTimeout,
+	}
+	tests := []struct {
+		name       string
+		encoding   string
+		body       io.Reader
+		wantStatus int
+	}{
+		{"corrupt", "gzip", bytes.NewReader(bytes.Repeat([]byte("x"), 100)), http.StatusUnprocessableEntity},
+		{"unsupported", "deflate", bytes.NewReader([]byte("test")), http.StatusNotImplemented},
+		{"timeout", "gzip", timeoutReader{}, http.StatusRequestTimeout},
+		{"not mapped", "gzip, gzip, gzip", bytes.NewReader([]byte("test")), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := contentencoding.Decode(
+				contentencoding.WithStrict(),
+				contentencoding.WithLimits(contentencoding.Limits{MaxLayers: 2}),
+				contentencoding.WithStatusCodes(codes),
+			)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodPost, "/", tt.body)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestWithStatusCodes_Read(t *testing.T) {
+	h := contentencoding.Decode(
+		contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 10}),
+		contentencoding.WithStatusCodes(map[contentencoding.ErrorCategory]int{contentencoding.ErrorTooLarge: http.StatusBadRequest}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		var statusErr *contentencoding.StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("should be StatusError with 400 but got %v", err)
+		}
+		var limitErr *contentencoding.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("should wrap LimitError but got %v", err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), contentencodingtest.NewCompressedRequest(http.MethodPost, "/", bytes.Repeat([]byte("a"), 100), "gzip"))
+}
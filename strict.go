@@ -0,0 +1,92 @@
+package contentencoding
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// UnsupportedEncodingError is the error for a request coding without decoder when WithStrict is used,
+// or for a built-in coding disabled by WithEncodings when WithDisabledStatus is used.
+// DefaultErrorHandler responds StatusCode for it, or 415 Unsupported Media Type if StatusCode is zero.
+type UnsupportedEncodingError struct {
+	// Coding is the unsupported coding.
+	Coding string
+	// Disabled reports whether the coding is supported by this package but disabled by configuration.
+	Disabled bool
+	// StatusCode is the status code of the response, see WithDisabledStatus.
+	StatusCode int
+	// Capabilities are the codings and limits the middleware accepts, see JSONErrorHandler.
+	Capabilities Capabilities
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	if e.Disabled {
+		return fmt.Sprintf("contentencoding: content coding %q is supported but disabled by configuration", e.Coding)
+	}
+	return fmt.Sprintf("contentencoding: unsupported content coding %q", e.Coding)
+}
+
+// WithStrict returns a Option to reject requests with codings that have no decoder, instead of passing them through.
+// The error handler is called with UnsupportedEncodingError, and the response has Accept-Encoding
+// with the supported codings as RFC 7694 describes, so that clients such as Transport can retry with one of them.
+func WithStrict() Option {
+	return func(cfg *config) {
+		cfg.strict = true
+	}
+}
+
+// WithDisabledStatus returns a Option to reject requests with built-in codings disabled by WithEncodings,
+// even without WithStrict, with the status code, which must be 415 Unsupported Media Type or 501 Not Implemented.
+// The error handler is called with UnsupportedEncodingError whose Disabled is true,
+// so that operators and clients can tell misconfiguration apart from unsupported formats.
+func WithDisabledStatus(code int) Option {
+	if code != http.StatusUnsupportedMediaType && code != http.StatusNotImplemented {
+		panic(fmt.Sprintf("contentencoding: invalid status code %d for disabled codings", code))
+	}
+	return func(cfg *config) {
+		cfg.disabledStatus = code
+	}
+}
+
+// checkSupported returns UnsupportedEncodingError for the first coding without decoder,
+// and sets Accept-Encoding to the response.
+func (cfg *config) checkSupported(w http.ResponseWriter, codings []string) error {
+	for _, c := range codings {
+		if cfg.supports(c) {
+			continue
+		}
+		disabled := isBuiltin(c)
+		if !cfg.strict && (!disabled || cfg.disabledStatus == 0) {
+			continue
+		}
+		w.Header().Set("Accept-Encoding", strings.Join(cfg.supportedCodings(), ", "))
+		err := &UnsupportedEncodingError{Coding: c, Disabled: disabled, Capabilities: cfg.capabilities()}
+		if disabled {
+			err.StatusCode = cfg.disabledStatus
+		}
+		return err
+	}
+	return nil
+}
+
+// isBuiltin reports whether coding is supported by this package regardless of WithEncodings.
+func isBuiltin(coding string) bool {
+	switch coding {
+	case "br", "gzip", "zstd":
+		return true
+	}
+	return false
+}
+
+func (cfg *config) supports(coding string) bool {
+	if coding == "identity" || cfg.builtinEnabled(coding) {
+		return true
+	}
+	for _, d := range cfg.decoders {
+		if coding == CanonicalCoding(d.Encoding) {
+			return true
+		}
+	}
+	return false
+}
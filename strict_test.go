@@ -0,0 +1,105 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestWithStrict(t *testing.T) {
+	custom := &contentencoding.Decoder{
+		Encoding: "custom",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	}
+	alias := &contentencoding.Decoder{
+		Encoding: "x-compress",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	}
+	tests := []struct {
+		name     string
+		opts     []contentencoding.Option
+		encoding string
+		want     int
+		accept   string
+	}{
+		{"unknown", []contentencoding.Option{contentencoding.WithStrict()}, "deflate", http.StatusUnsupportedMediaType, "br, gzip, zstd"},
+		{"unknown in chain", []contentencoding.Option{contentencoding.WithStrict()}, "deflate, gzip", http.StatusUnsupportedMediaType, "br, gzip, zstd"},
+		{"custom", []contentencoding.Option{contentencoding.WithStrict(), contentencoding.WithDecoder(custom)}, "custom", http.StatusOK, ""},
+		{"identity", []contentencoding.Option{contentencoding.WithStrict()}, "identity", http.StatusOK, ""},
+		{"alias", []contentencoding.Option{contentencoding.WithStrict(), contentencoding.WithDecoder(alias)}, "compress", http.StatusOK, ""},
+		{"not strict", nil, "deflate", http.StatusOK, ""},
+		{"disabled strict", []contentencoding.Option{contentencoding.WithStrict(), contentencoding.WithEncodings("gzip")}, "br", http.StatusUnsupportedMediaType, "gzip"},
+		{"disabled 501", []contentencoding.Option{contentencoding.WithEncodings("gzip"), contentencoding.WithDisabledStatus(http.StatusNotImplemented)}, "zstd", http.StatusNotImplemented, "gzip"},
+		{"unknown not strict with disabled status", []contentencoding.Option{contentencoding.WithEncodings("gzip"), contentencoding.WithDisabledStatus(http.StatusNotImplemented)}, "deflate", http.StatusOK, ""},
+		{"strict with disabled status", []contentencoding.Option{contentencoding.WithStrict(), contentencoding.WithEncodings("gzip"), contentencoding.WithDisabledStatus(http.StatusNotImplemented)}, "deflate", http.StatusUnsupportedMediaType, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := contentencoding.Decode(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("test")))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status code should be %d but got %d", tt.want, rec.Code)
+			}
+			if got := rec.Header().Get("Accept-Encoding"); got != tt.accept {
+				t.Errorf("Accept-Encoding should be %q but got %q", tt.accept, got)
+			}
+			if called != (tt.want == http.StatusOK) {
+				t.Errorf("handler called should be %v but got %v", tt.want == http.StatusOK, called)
+			}
+		})
+	}
+}
+
+func TestWithDisabledStatus(t *testing.T) {
+	var got error
+	h := contentencoding.Decode(
+		contentencoding.WithEncodings("gzip"),
+		contentencoding.WithDisabledStatus(http.StatusNotImplemented),
+		contentencoding.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			got = err
+			contentencoding.DefaultErrorHandler(w, r, err)
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("test")))
+	req.Header.Set("Content-Encoding", "br")
+	h.ServeHTTP(rec, req)
+
+	var uerr *contentencoding.UnsupportedEncodingError
+	if !errors.As(got, &uerr) {
+		t.Fatalf("error should be UnsupportedEncodingError but got %v", got)
+	}
+	if !uerr.Disabled || uerr.Coding != "br" {
+		t.Errorf("error should be disabled br but got %+v", uerr)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "disabled by configuration") {
+		t.Errorf("body should explain the coding is disabled but got %q", body)
+	}
+}
+
+func TestWithDisabledStatus_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic")
+		}
+	}()
+	contentencoding.WithDisabledStatus(http.StatusBadRequest)
+}
@@ -0,0 +1,169 @@
+package contentencoding
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Transcode returns net/http compatible middleware that decodes request body like Decode
+// and re-encodes it into the single coding to, for upstreams that only accept one format.
+// Content-Encoding is set to the coding and Content-Length is removed.
+// The body is re-encoded while it is read, so it is never buffered as a whole.
+// Requests that are already encoded with the coding or that have a coding without decoder are left as they are.
+// WithLimits applies to the decoding as Decode does, and errors of the re-encoded body are returned by its reads.
+// to must be one of br, gzip and zstd.
+func Transcode(to string, opts ...Option) func(next http.Handler) http.Handler {
+	to = CanonicalCoding(to)
+	if _, ok, _ := builtinWriter(to, io.Discard); !ok {
+		panic("contentencoding: unsupported coding for Transcode: " + to)
+	}
+	cfg := newConfig(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.skipMethod(r) || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			values, _ := contentCodings(r.Header.Get("Content-Encoding"))
+			if len(values) == 1 && values[0] == to {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !cfg.strict {
+				// the body is left as it is rather than decoded partly, which WithStrict rejects.
+				for _, v := range values {
+					if !cfg.supports(v) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			encoded := &countingBody{ReadCloser: r.Body}
+			r.Body = encoded
+			if _, ok := cfg.decodeRequest(w, r); !ok {
+				return
+			}
+
+			dec := r.Body
+			src := io.Reader(dec)
+			if dec != encoded {
+				src = cfg.limits.limitBody(dec, func() int64 { return encoded.n })
+			}
+			// the decoders are closed by the encoding goroutine, which may still be reading them.
+			pr := encodePipe(src, func(w io.Writer) (io.WriteCloser, error) {
+				return cfg.encoder(to, w)
+			}, func(error) {
+				dec.Close()
+			})
+			defer pr.Close()
+
+			r.Body = pr
+			r.Header.Set("Content-Encoding", to)
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Transcoder converts streams encoded with one content encoding to another, e.g. to migrate stored blobs from gzip to zstd.
+// Streams are decoded and re-encoded while they are read or written, so they are never buffered as a whole.
+type Transcoder struct {
+	cfg      *config
+	from, to string
+}
+
+// NewTranscoder returns a Transcoder from the content encoding from to to,
+// which are comma-separated lists of codings in the same form as the Content-Encoding header.
+// Only built-in codings and identity are supported.
+// Compression levels, WithDOptions and WithEOptions are applied, other options are ignored.
+func NewTranscoder(from, to string, opts ...Option) (*Transcoder, error) {
+	for _, ce := range []string{from, to} {
+		values, err := contentCodings(ce)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if v != "identity" && !isBuiltin(v) {
+				return nil, fmt.Errorf("contentencoding: unsupported coding %q", v)
+			}
+		}
+	}
+	return &Transcoder{cfg: newConfig(opts), from: from, to: to}, nil
+}
+
+// Reader returns a reader of r transcoded.
+// Errors of decoding r are returned by reads of the returned reader.
+// Closing the returned reader stops the transcoding but does not close r.
+func (t *Transcoder) Reader(r io.Reader) (io.ReadCloser, error) {
+	dec, err := t.cfg.newReader(r, t.from)
+	if err != nil {
+		return nil, err
+	}
+	return encodePipe(dec, func(w io.Writer) (io.WriteCloser, error) {
+		return t.cfg.newWriter(w, t.to)
+	}, func(error) {
+		dec.Close()
+	}), nil
+}
+
+// Writer returns a writer that transcodes the stream written to it and writes the result to w.
+// Close must be called to flush the encoders, it returns the error of decoding and does not close w.
+func (t *Transcoder) Writer(w io.Writer) (io.WriteCloser, error) {
+	enc, err := t.cfg.newWriter(w, t.to)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	tw := &transcodeWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		dec, err := t.cfg.newReader(pr, t.from)
+		if err == nil {
+			_, err = io.Copy(enc, dec)
+			dec.Close()
+		}
+		if cerr := enc.Close(); err == nil {
+			err = cerr
+		}
+		// writes after the end of the stream fail.
+		pr.CloseWithError(err)
+		tw.done <- err
+	}()
+	return tw, nil
+}
+
+type transcodeWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (tw *transcodeWriter) Write(p []byte) (int, error) {
+	return tw.pw.Write(p)
+}
+
+func (tw *transcodeWriter) Close() error {
+	tw.pw.Close()
+	return <-tw.done
+}
+
+// encodePipe returns a reader of src encoded by the encoder from newEncoder, the encoding runs in a goroutine.
+// done is called with the result of the encoding, if not nil, before the reader reports the end.
+func encodePipe(src io.Reader, newEncoder func(w io.Writer) (io.WriteCloser, error), done func(err error)) *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		enc, err := newEncoder(pw)
+		if err == nil {
+			_, err = io.Copy(enc, src)
+			if cerr := enc.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if done != nil {
+			done(err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,243 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestTranscode(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		data     string
+	}{
+		{"brotli", "br", "testdata/test.txt.br"},
+		{"gzip", "gzip", "testdata/test.txt.gz"},
+		{"zstd", "zstd", "testdata/test.txt.zst"},
+		{"gzip+zstd", "gzip, zstd", "testdata/test.txt.gz.zst"},
+		{"identity", "", "testdata/test.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := contentencoding.Decode()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ce := r.Header.Get("Content-Encoding"); ce != "zstd" {
+					t.Errorf("Content-Encoding should be zstd but got='%s'", ce)
+				}
+				if r.ContentLength != -1 {
+					t.Errorf("ContentLength should be unknown but got=%d", r.ContentLength)
+				}
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if txt := strings.TrimSpace(string(b)); txt != "test" {
+					t.Errorf("should be test but got='%s'", txt)
+				}
+			}))
+
+			f, err := os.Open(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", f)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			contentencoding.Transcode("zstd")(upstream).ServeHTTP(rec, req)
+
+			if result := rec.Result(); result.StatusCode != http.StatusOK {
+				t.Errorf("%v", result)
+			}
+		})
+	}
+}
+
+func TestTranscode_passthrough(t *testing.T) {
+	gzipped, err := contentencodingtest.CompressBody([]byte("test"), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"same coding", "gzip", []byte("test")},
+		{"unknown coding", "custom", []byte("test")},
+		{"unknown coding left of gzip", "custom, gzip", gzipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			called := false
+			contentencoding.Transcode("gzip")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if ce := r.Header.Get("Content-Encoding"); ce != tt.encoding {
+					t.Errorf("Content-Encoding should be %s but got='%s'", tt.encoding, ce)
+				}
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b, tt.body) {
+					t.Errorf("should be the body as it is but got='%s'", b)
+				}
+			})).ServeHTTP(rec, req)
+			if !called {
+				t.Errorf("next should be called but got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestTranscode_error(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("test")) // not compressed
+	req.Header.Set("Content-Encoding", "gzip")
+	contentencoding.Transcode("br")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next should not be called")
+	})).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("should be 400 but got %d", rec.Code)
+	}
+}
+
+func TestTranscode_unsupported(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic")
+		}
+	}()
+	contentencoding.Transcode("custom")
+}
+
+func TestTranscoder(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		data string
+		to   string
+	}{
+		{"gzip to zstd", "gzip", "testdata/test.txt.gz", "zstd"},
+		{"br to chain", "br", "testdata/test.txt.br", "gzip, zstd"},
+		{"chain to br", "gzip, zstd", "testdata/test.txt.gz.zst", "br"},
+		{"zstd to identity", "zstd", "testdata/test.txt.zst", "identity"},
+		{"identity to gzip", "identity", "testdata/test.txt", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc, err := contentencoding.NewTranscoder(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			check := func(t *testing.T, encoded []byte) {
+				t.Helper()
+				dec, err := contentencoding.NewReader(bytes.NewReader(encoded), tt.to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := io.ReadAll(dec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if txt := strings.TrimSpace(string(b)); txt != "test" {
+					t.Errorf("should be test but got %s", txt)
+				}
+			}
+
+			t.Run("Reader", func(t *testing.T) {
+				r, err := tc.Reader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				b, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				check(t, b)
+			})
+
+			t.Run("Writer", func(t *testing.T) {
+				var buf bytes.Buffer
+				w, err := tc.Writer(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write(data); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				check(t, buf.Bytes())
+			})
+		})
+	}
+}
+
+func TestTranscoder_error(t *testing.T) {
+	if _, err := contentencoding.NewTranscoder("deflate", "gzip"); err == nil {
+		t.Error("unsupported coding should be error")
+	}
+
+	tc, err := contentencoding.NewTranscoder("zstd", "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := tc.Reader(strings.NewReader("not zstd"))
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("corrupted stream should be error of Reader")
+	}
+
+	w, err := tc.Writer(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("not zstd"))
+	if err := w.Close(); err == nil {
+		t.Error("corrupted stream should be error of Writer")
+	}
+}
+
+func TestTranscode_limits(t *testing.T) {
+	b, err := contentencoding.EncodeBytes("gzip", make([]byte, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	req.Header.Set("Content-Encoding", "gzip")
+	limits := contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 100})
+	contentencoding.Transcode("zstd", limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		var limitErr *contentencoding.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("should be LimitError but got %v", err)
+		}
+	})).ServeHTTP(rec, req)
+}
@@ -0,0 +1,60 @@
+package contentencoding
+
+import (
+	"log"
+	"net/http"
+	"sync"
+)
+
+// UndecodedHook is called with the codings of r left undecoded because they have no decoder,
+// the last applied one first. It is never called with WithStrict, which rejects such requests.
+type UndecodedHook func(r *http.Request, codings []string)
+
+// WithUndecodedHook returns a Option to observe requests with codings that are passed to the handler undecoded,
+// so that operators can find the codings clients use before turning on WithStrict, see UndecodedCounter.
+func WithUndecodedHook(hook UndecodedHook) Option {
+	return func(cfg *config) {
+		cfg.undecodedHook = hook
+	}
+}
+
+// UndecodedCounter counts the requests with undecoded codings by coding.
+// Its Observe method is an UndecodedHook.
+type UndecodedCounter struct {
+	// Logger logs the first request with each undecoded coding if not nil.
+	Logger *log.Logger
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// Observe counts the request r with the undecoded codings.
+func (c *UndecodedCounter) Observe(r *http.Request, codings []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	seen := make(map[string]bool, len(codings))
+	for _, coding := range codings {
+		if seen[coding] {
+			continue
+		}
+		seen[coding] = true
+		if c.counts[coding] == 0 && c.Logger != nil {
+			c.Logger.Printf("contentencoding: undecoded coding %q in %s %s from %s", coding, r.Method, r.URL.Path, r.RemoteAddr)
+		}
+		c.counts[coding]++
+	}
+}
+
+// Counts returns a copy of the numbers of requests by undecoded coding.
+func (c *UndecodedCounter) Counts() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	return counts
+}
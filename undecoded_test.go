@@ -0,0 +1,36 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestWithUndecodedHook(t *testing.T) {
+	var logs bytes.Buffer
+	counter := &contentencoding.UndecodedCounter{Logger: log.New(&logs, "", 0)}
+	h := contentencoding.Decode(contentencoding.WithUndecodedHook(counter.Observe))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, ce := range []string{"deflate", "identity", "X-Compress, deflate", "deflate, deflate"} {
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("test"))
+		req.Header.Set("Content-Encoding", ce)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	want := map[string]int64{"deflate": 3, "compress": 1}
+	if got := counter.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("should be %v but got %v", want, got)
+	}
+	if n := strings.Count(logs.String(), "\n"); n != 2 {
+		t.Errorf("the first request of each coding should be logged but got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), "POST /upload") {
+		t.Errorf("log should contain the request but got %q", logs.String())
+	}
+}
@@ -0,0 +1,52 @@
+package contentencoding
+
+import (
+	"io"
+	"net/http"
+)
+
+// CopyResult is the result of CopyBody.
+type CopyResult struct {
+	// EncodedBytes is the number of encoded bytes read from the request body.
+	EncodedBytes int64
+	// DecodedBytes is the number of decoded bytes written to the destination.
+	DecodedBytes int64
+	// Digests are the digests computed by WithDigests, nil if it is not used.
+	Digests *Digests
+}
+
+// CopyBody decodes the body of r as Decode does and copies the decoded body to dst,
+// e.g. a multipart uploader of object storage, so that a handler can store uploads without buffering them.
+// Options such as WithLimits, WithProgressHook, WithDigests and WithStatsHook are applied while copying.
+// Unlike Decode, requests of any method are decoded, a coding without decoder is UnsupportedEncodingError
+// even without WithStrict, and WithPassthrough, WithSpool and WithReplayableBody are ignored.
+// Errors of decoding are returned instead of being written by the error handler, and the body of r is not closed.
+func CopyBody(dst io.Writer, r *http.Request, opts ...Option) (CopyResult, error) {
+	var result CopyResult
+	if r.Body == nil || r.Body == http.NoBody {
+		return result, nil
+	}
+	cfg := *newConfig(opts).resolve(r)
+	cfg.resolver = nil
+	cfg.methods = map[string]bool{r.Method: true}
+	cfg.strict = true
+	cfg.passthrough = false
+	cfg.contentRange = ContentRangeDecode
+	cfg.oversize = OversizeReject
+	cfg.spoolEncoding = ""
+	cfg.replayMax = 0
+	var copyErr error
+	cfg.errHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		copyErr = err
+	}
+
+	encoded := &countingBody{ReadCloser: io.NopCloser(r.Body)}
+	r = r.WithContext(r.Context())
+	r.Body = encoded
+	internalMiddleware(&cfg).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result.Digests = DigestsFromRequest(r)
+		result.DecodedBytes, copyErr = io.Copy(dst, r.Body)
+	})).ServeHTTP(discardWriter{}, r)
+	result.EncodedBytes = encoded.n
+	return result, copyErr
+}
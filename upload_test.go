@@ -0,0 +1,103 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestCopyBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("upload test "), 1000)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []contentencoding.Progress
+	req := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(encoded))
+	req.Header.Set("Content-Encoding", "gzip")
+	var dst bytes.Buffer
+	got, err := contentencoding.CopyBody(&dst, req,
+		contentencoding.WithDigests(contentencoding.SHA256),
+		contentencoding.WithProgressHook(0, func(r *http.Request, p contentencoding.Progress) error {
+			progress = append(progress, p)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst.Bytes(), payload) {
+		t.Error("decoded body should be copied to dst")
+	}
+	if got.EncodedBytes != int64(len(encoded)) {
+		t.Errorf("EncodedBytes should be %d but got %d", len(encoded), got.EncodedBytes)
+	}
+	if got.DecodedBytes != int64(len(payload)) {
+		t.Errorf("DecodedBytes should be %d but got %d", len(payload), got.DecodedBytes)
+	}
+	if sum := sha256.Sum256(payload); !bytes.Equal(got.Digests.Decoded(contentencoding.SHA256), sum[:]) {
+		t.Error("digest of decoded body is wrong")
+	}
+	if len(progress) != 1 || !progress[0].Done {
+		t.Errorf("progress should be reported at the end but got %+v", progress)
+	}
+	if req.Header.Get("Content-Encoding") != "gzip" {
+		t.Error("request should not be modified")
+	}
+}
+
+func TestCopyBody_nested(t *testing.T) {
+	payload := bytes.Repeat([]byte("nested upload test "), 100)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst bytes.Buffer
+	// the outer middleware handles the request without decoding it.
+	h := contentencoding.Decode(contentencoding.WithMethods(http.MethodPut), contentencoding.WithRejectNested())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := contentencoding.CopyBody(&dst, r, contentencoding.WithRejectNested()); err != nil {
+			t.Error(err)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !bytes.Equal(dst.Bytes(), payload) {
+		t.Error("decoded body should be copied to dst")
+	}
+}
+
+func TestCopyBody_error(t *testing.T) {
+	payload := bytes.Repeat([]byte("upload test "), 1000)
+	encoded, err := contentencodingtest.CompressBody(payload, "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+		req.Header.Set("Content-Encoding", "zstd")
+		_, err := contentencoding.CopyBody(&bytes.Buffer{}, req, contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: 100}))
+		var limitErr *contentencoding.LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != "MaxDecodedBytes" {
+			t.Errorf("should be MaxDecodedBytes LimitError but got %v", err)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+		req.Header.Set("Content-Encoding", "deflate")
+		_, err := contentencoding.CopyBody(&bytes.Buffer{}, req)
+		var unsupported *contentencoding.UnsupportedEncodingError
+		if !errors.As(err, &unsupported) {
+			t.Errorf("should be UnsupportedEncodingError but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package contentencoding
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ValidationReport is the result of decoding a request body, it is served as JSON by Middleware.ValidationHandler.
+type ValidationReport struct {
+	// ContentEncoding is the Content-Encoding of the request.
+	ContentEncoding string `json:"contentEncoding"`
+	// Codings are the codings of ContentEncoding in the order they were applied.
+	Codings []string `json:"codings"`
+	// EncodedBytes is the size of the encoded body.
+	EncodedBytes int64 `json:"encodedBytes"`
+	// DecodedBytes is the size of the decoded body.
+	DecodedBytes int64 `json:"decodedBytes"`
+	// Digests are the hex encoded digests of the decoded body keyed by the name of the algorithm.
+	Digests map[string]string `json:"digests"`
+	// EncodedDigests are the hex encoded digests of the encoded body keyed by the name of the algorithm.
+	EncodedDigests map[string]string `json:"encodedDigests"`
+}
+
+// ValidationHandler returns a handler that decodes a posted body with the configuration of m and reports
+// ValidationReport as JSON without storing anything, so that client developers can test their encoders
+// against production limits. Codings without decoder are rejected as WithStrict does,
+// and requests that would be passed through are decoded or rejected instead.
+// The digests of WithDigests are reported, or all of DigestAlgorithm if it is not used.
+// Errors are written by JSONErrorHandler.
+func (m *Middleware) ValidationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		cfg := *m.resolve(r)
+		cfg.resolver = nil
+		cfg.methods = nil
+		cfg.strict = true
+		cfg.passthrough = false
+		cfg.contentRange = ContentRangeDecode
+		cfg.oversize = OversizeReject
+		cfg.spoolEncoding = ""
+		cfg.errHandler = JSONErrorHandler
+		if len(cfg.digests) == 0 {
+			cfg.digests = []DigestAlgorithm{CRC32C, SHA256}
+		}
+
+		encoded := &countingBody{ReadCloser: r.Body}
+		r.Body = encoded
+		internalMiddleware(&cfg).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n, err := io.Copy(io.Discard, r.Body)
+			if err != nil {
+				JSONErrorHandler(w, r, cfg.mapStatus(err))
+				return
+			}
+			report := ValidationReport{
+				ContentEncoding: r.Header.Get("Content-Encoding"),
+				Codings:         []string{},
+				EncodedBytes:    encoded.n,
+				DecodedBytes:    n,
+				Digests:         make(map[string]string),
+				EncodedDigests:  make(map[string]string),
+			}
+			codings, _ := contentCodings(report.ContentEncoding)
+			for _, c := range codings {
+				if c != "identity" {
+					report.Codings = append(report.Codings, c)
+				}
+			}
+			if d := DigestsFromRequest(r); d != nil {
+				for _, alg := range cfg.digests {
+					report.Digests[alg.String()] = hex.EncodeToString(d.Decoded(alg))
+					report.EncodedDigests[alg.String()] = hex.EncodeToString(d.Encoded(alg))
+				}
+			}
+			b, err := json.Marshal(report)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		})).ServeHTTP(w, r)
+	})
+}
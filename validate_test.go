@@ -0,0 +1,113 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+)
+
+func TestMiddleware_ValidationHandler(t *testing.T) {
+	payload := bytes.Repeat([]byte("validation test "), 1000)
+	encoded, err := contentencodingtest.CompressBody(payload, "gzip", "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(payload)
+	encodedSum := sha256.Sum256(encoded)
+
+	m := contentencoding.New(
+		contentencoding.WithDigests(contentencoding.SHA256),
+		contentencoding.WithLimits(contentencoding.Limits{MaxDecodedBytes: int64(len(payload))}),
+	)
+	h := m.ValidationHandler()
+
+	t.Run("valid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+		req.Header.Set("Content-Encoding", "gzip, zstd")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("should be %d but got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		var got contentencoding.ValidationReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := contentencoding.ValidationReport{
+			ContentEncoding: "gzip, zstd",
+			Codings:         []string{"gzip", "zstd"},
+			EncodedBytes:    int64(len(encoded)),
+			DecodedBytes:    int64(len(payload)),
+			Digests:         map[string]string{"sha-256": hex.EncodeToString(sum[:])},
+			EncodedDigests:  map[string]string{"sha-256": hex.EncodeToString(encodedSum[:])},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("report should be %+v but got %+v", want, got)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		encoding   string
+		body       []byte
+		wantStatus int
+	}{
+		{"identity is not limited", http.MethodPost, "identity", append(payload, 'x'), http.StatusOK},
+		{"too large encoded", http.MethodPut, "zstd", mustCompress(t, append(payload, 'x'), "zstd"), http.StatusRequestEntityTooLarge},
+		{"unsupported", http.MethodPost, "deflate", payload, http.StatusUnsupportedMediaType},
+		{"corrupt", http.MethodPost, "gzip", payload, http.StatusBadRequest},
+		{"method", http.MethodGet, "", nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ValidationHandler_nested(t *testing.T) {
+	payload := bytes.Repeat([]byte("nested validation test "), 100)
+	encoded := mustCompress(t, payload, "gzip")
+	m := contentencoding.New(contentencoding.WithRejectNested())
+	// the outer middleware handles the request without decoding it.
+	h := contentencoding.Decode(contentencoding.WithMethods(http.MethodPut))(m.ValidationHandler())
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("should be %d but got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var got contentencoding.ValidationReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.DecodedBytes != int64(len(payload)) || !reflect.DeepEqual(got.Codings, []string{"gzip"}) {
+		t.Errorf("report should be of the decoded body but got %+v", got)
+	}
+}
+
+func mustCompress(t *testing.T, b []byte, encodings ...string) []byte {
+	t.Helper()
+	encoded, err := contentencodingtest.CompressBody(b, encodings...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
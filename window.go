@@ -0,0 +1,62 @@
+package contentencoding
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ClientCapability reports whether the client of r is able to decode responses that exceed the default limits,
+// such as windows larger than most clients allocate.
+type ClientCapability func(r *http.Request) bool
+
+// HeaderCapability returns a ClientCapability that reports whether the request header contains token,
+// a comma-separated element compared case-insensitively, e.g. HeaderCapability("X-Zstd-Window", "long").
+func HeaderCapability(header, token string) ClientCapability {
+	return func(r *http.Request) bool {
+		for _, v := range r.Header.Values(header) {
+			for _, e := range strings.Split(v, ",") {
+				if strings.EqualFold(strings.TrimSpace(e), token) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// WithEOptions returns a Option to customize zstd encoders of responses, spools and transcoded bodies with zstd.EOptions.
+// They are applied after the level of the configuration.
+// See https://pkg.go.dev/github.com/klauspost/compress/zstd?tab=doc#EOption.
+func WithEOptions(eopts ...zstd.EOption) Option {
+	eopts = append([]zstd.EOption(nil), eopts...)
+	return func(cfg *config) {
+		cfg.eopts = eopts
+	}
+}
+
+// WithZstdLongWindow returns a Option to encode zstd responses with windowSize, a power of 2 up to zstd.MaxWindowSize,
+// for clients for which capable reports true, which improves the ratio of large responses with distant repetitions.
+// Other clients receive frames within the default window of 8MB, since RFC 8878 allows HTTP clients
+// to reject larger windows and some do.
+// The window of the other encoded output, such as spools, is not changed.
+func WithZstdLongWindow(windowSize int, capable ClientCapability) Option {
+	if windowSize < zstd.MinWindowSize || windowSize > zstd.MaxWindowSize || windowSize&(windowSize-1) != 0 {
+		panic(fmt.Sprintf("contentencoding: invalid zstd window size %d", windowSize))
+	}
+	return func(cfg *config) {
+		cfg.zstdWindow = windowSize
+		cfg.zstdWindowCapable = capable
+	}
+}
+
+// largeWindow reports whether the response to r encoded with encoding can use the large window.
+func (cfg *config) largeWindow(r *http.Request, encoding string) bool {
+	if r == nil {
+		return false
+	}
+	// the brotli encoder does not implement the large window extension, WithBrotliWindow bounds the standard one.
+	return encoding == "zstd" && cfg.zstdWindow > 0 && cfg.zstdWindowCapable != nil && cfg.zstdWindowCapable(r)
+}
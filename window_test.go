@@ -0,0 +1,84 @@
+package contentencoding_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+func TestWithZstdLongWindow(t *testing.T) {
+	content := []byte(strings.Repeat("<p>long window test</p>", 10000))
+	opts := []contentencoding.Option{
+		contentencoding.WithEncodingPreference("zstd"),
+		contentencoding.WithZstdLongWindow(64<<20, contentencoding.HeaderCapability("X-Zstd-Window", "long")),
+	}
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantWindow uint64
+	}{
+		{"capable", http.Header{"Accept-Encoding": {"zstd"}, "X-Zstd-Window": {"other, LONG"}}, 64 << 20},
+		{"not capable", http.Header{"Accept-Encoding": {"zstd"}}, 8 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+			contentencoding.ServeContent(rec, req, "index.html", time.Time{}, bytes.NewReader(content), nil, opts...)
+			if got := rec.Header().Get("Content-Encoding"); got != "zstd" {
+				t.Fatalf("Content-Encoding should be zstd but got %q", got)
+			}
+			var h zstd.Header
+			if err := h.Decode(rec.Body.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if h.WindowSize > tt.wantWindow || (tt.wantWindow > 8<<20 && h.WindowSize != tt.wantWindow) {
+				t.Errorf("window size should be %d but got %d", tt.wantWindow, h.WindowSize)
+			}
+			d, err := zstd.NewReader(bytes.NewReader(rec.Body.Bytes()), zstd.WithDecoderMaxWindow(64<<20))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+			b, err := ioutil.ReadAll(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, content) {
+				t.Error("decoded response should be the content")
+			}
+		})
+	}
+}
+
+func TestWithZstdLongWindow_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic")
+		}
+	}()
+	contentencoding.WithZstdLongWindow(3<<20, nil)
+}
+
+// brotliWindowBits returns WBITS of the brotli stream header in b as RFC 7932 describes.
+func brotliWindowBits(b byte) int {
+	if b&1 == 0 {
+		return 16
+	}
+	if n := (b >> 1) & 7; n != 0 {
+		return 17 + int(n)
+	}
+	if n := (b >> 4) & 7; n != 0 {
+		return 8 + int(n)
+	}
+	return 17
+}
@@ -0,0 +1,223 @@
+package contentencoding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// builtinWriter returns a writer that encodes to w with the built-in encoder for encoding.
+// ok is false if encoding is not built-in.
+func builtinWriter(encoding string, w io.Writer) (wc io.WriteCloser, ok bool, err error) {
+	switch encoding {
+	case "br":
+		return brotli.NewWriter(w), true, nil
+	case "gzip":
+		return gzip.NewWriter(w), true, nil
+	case "zstd":
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, true, err
+		}
+		return zw, true, nil
+	}
+	return nil, false, nil
+}
+
+// encoder returns a writer that encodes to w with the built-in encoder for encoding at the configured level.
+func (cfg *config) encoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	return cfg.encoderPool.encoder(context.Background(), func() (io.WriteCloser, error) {
+		return cfg.newEncoder(encoding, w, false, -1)
+	})
+}
+
+// responseEncoder returns a writer that encodes the response to r as encoder does,
+// with the zstd long window if the client is capable of it, or the window narrowed to size if it is known.
+func (cfg *config) responseEncoder(r *http.Request, encoding string, w io.Writer, size int64) (io.WriteCloser, error) {
+	return cfg.encoderPool.encoder(r.Context(), func() (io.WriteCloser, error) {
+		return cfg.newEncoder(encoding, w, cfg.largeWindow(r, encoding), size)
+	})
+}
+
+// newEncoder returns the encoder for encoding, size is the hinted size of the input or -1 if unknown.
+func (cfg *config) newEncoder(encoding string, w io.Writer, large bool, size int64) (io.WriteCloser, error) {
+	encoding = CanonicalCoding(encoding)
+	level, ok := cfg.levels[encoding]
+	switch encoding {
+	case "br":
+		if !ok {
+			level = brotli.DefaultCompression
+		}
+		opts := brotli.WriterOptions{Quality: level, LGWin: cfg.brotliWindow}
+		if window := hintedWindow(size, 1<<10, 1<<22); window > 0 {
+			if lgwin := bits.Len(uint(window)) - 1; opts.LGWin == 0 || lgwin < opts.LGWin {
+				opts.LGWin = lgwin
+			}
+		}
+		return pooledEncoder(encoderKey{encoding, level, opts.LGWin}, w, func() resetEncoder {
+			return brotli.NewWriterOptions(nil, opts)
+		}), nil
+	case "gzip":
+		if !ok {
+			level = gzip.DefaultCompression
+		}
+		if level < gzip.StatelessCompression || level > gzip.BestCompression {
+			return nil, fmt.Errorf("contentencoding: invalid gzip level %d", level)
+		}
+		return pooledEncoder(encoderKey{encoding, level, 0}, w, func() resetEncoder {
+			zw, _ := gzip.NewWriterLevel(nil, level)
+			return zw
+		}), nil
+	case "zstd":
+		// a stream is encoded by the goroutine writing it instead of the goroutines of the encoder.
+		eopts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if ok {
+			eopts = append(eopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		if window := hintedWindow(size, zstd.MinWindowSize, 8<<20); window > 0 && !large {
+			eopts = append(eopts, zstd.WithWindowSize(window))
+		}
+		eopts = append(eopts, cfg.eopts...)
+		if large {
+			eopts = append(eopts, zstd.WithWindowSize(cfg.zstdWindow))
+		}
+		return zstd.NewWriter(w, eopts...)
+	}
+	return nil, fmt.Errorf("contentencoding: unsupported coding %q", encoding)
+}
+
+// encodeAll returns src encoded with encoding at once holding a slot of the EncoderPool.
+// zstd is encoded by EncodeAll of the encoder shared by the responses of cfg,
+// which narrows the window to src as a hinted size does.
+func (cfg *config) encodeAll(r *http.Request, encoding string, src []byte) ([]byte, error) {
+	if CanonicalCoding(encoding) != "zstd" {
+		var out bytes.Buffer
+		enc, err := cfg.responseEncoder(r, encoding, &out, int64(len(src)))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := enc.Write(src); err != nil {
+			enc.Close()
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	release, err := cfg.encoderPool.acquire(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	enc, err := cfg.zstdEncoders.encoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return enc.EncodeAll(src, make([]byte, 0, len(src)/2)), nil
+}
+
+// zstdEncoders are the zstd encoders shared by the buffered responses of a config by level.
+// A zstd.Encoder encodes by EncodeAll concurrently, unlike its streams.
+type zstdEncoders struct {
+	mu sync.Mutex
+	m  map[int]*zstd.Encoder
+}
+
+func (e *zstdEncoders) encoder(cfg *config) (*zstd.Encoder, error) {
+	level, ok := cfg.levels["zstd"]
+	if !ok {
+		level = -1
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if enc := e.m[level]; enc != nil {
+		return enc, nil
+	}
+	var eopts []zstd.EOption
+	if ok {
+		eopts = append(eopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	enc, err := zstd.NewWriter(nil, append(eopts, cfg.eopts...)...)
+	if err != nil {
+		return nil, err
+	}
+	if e.m == nil {
+		e.m = make(map[int]*zstd.Encoder)
+	}
+	e.m[level] = enc
+	return enc, nil
+}
+
+// resetEncoder is an encoder that can be reused for another writer, gzip.Writer and brotli.Writer.
+type resetEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoderKey identifies the gzip and brotli encoders that are reused for each other.
+type encoderKey struct {
+	encoding string
+	level    int
+	window   int
+}
+
+// encoderPools are the pools of gzip and brotli encoders by encoderKey, which are shared by all configs
+// since the encoders have no other options.
+var encoderPools sync.Map // encoderKey -> *sync.Pool
+
+// pooledEncoder returns a pooled encoder of key writing to w, or a new one by newEncoder.
+// The encoder is returned to the pool when it is closed.
+func pooledEncoder(key encoderKey, w io.Writer, newEncoder func() resetEncoder) io.WriteCloser {
+	v, ok := encoderPools.Load(key)
+	if !ok {
+		v, _ = encoderPools.LoadOrStore(key, &sync.Pool{New: func() interface{} { return newEncoder() }})
+	}
+	pool := v.(*sync.Pool)
+	enc := pool.Get().(resetEncoder)
+	enc.Reset(w)
+	return &pooledWriter{enc: enc, pool: pool}
+}
+
+type pooledWriter struct {
+	enc  resetEncoder
+	pool *sync.Pool
+}
+
+func (pw *pooledWriter) Write(p []byte) (int, error) {
+	if pw.enc == nil {
+		return 0, errEncoderClosed
+	}
+	return pw.enc.Write(p)
+}
+
+func (pw *pooledWriter) Flush() error {
+	if pw.enc == nil {
+		return errEncoderClosed
+	}
+	return pw.enc.Flush()
+}
+
+func (pw *pooledWriter) Close() error {
+	if pw.enc == nil {
+		return nil
+	}
+	err := pw.enc.Close()
+	// release the reference to the writer.
+	pw.enc.Reset(nil)
+	pw.pool.Put(pw.enc)
+	pw.enc = nil
+	return err
+}
+
+var errEncoderClosed = errors.New("contentencoding: write to closed encoder")
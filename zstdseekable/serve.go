@@ -0,0 +1,46 @@
+package zstdseekable
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	contentencoding "github.com/johejo/go-content-encoding"
+)
+
+// ServeContent serves the seekable zstd file ra of size bytes like http.ServeContent,
+// where name is the name of the decompressed content.
+// Requests accepting zstd without Range receive the file as it is with Content-Encoding: zstd.
+// Other requests receive the decompressed content, and ranges are decompressed only from the frames containing them.
+// Since the two representations differ, an ETag set by the caller should be different for each or weak.
+func ServeContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, ra io.ReaderAt, size int64) {
+	sr, err := NewReader(ra, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	content := io.NewSectionReader(sr, 0, sr.Size())
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if _, ok := h["Content-Type"]; !ok {
+		// the type must be detected from the decompressed content.
+		ctype := mime.TypeByExtension(filepath.Ext(name))
+		if ctype == "" {
+			var buf [512]byte
+			n, _ := io.ReadFull(content, buf[:])
+			ctype = http.DetectContentType(buf[:n])
+		}
+		h.Set("Content-Type", ctype)
+	}
+
+	if r.Header.Get("Range") == "" {
+		if coding, _ := contentencoding.Negotiate(r.Header.Get("Accept-Encoding"), []string{"zstd"}); coding == "zstd" {
+			h.Set("Content-Encoding", "zstd")
+			http.ServeContent(w, r, name, modtime, io.NewSectionReader(ra, 0, size))
+			return
+		}
+	}
+	http.ServeContent(w, r, name, modtime, content)
+}
@@ -0,0 +1,229 @@
+// Package zstdseekable implements the zstd seekable format, which splits the content into independent zstd frames
+// followed by a seek table in a skippable frame, so that any range can be decompressed from the frames containing it.
+// The output is still a valid zstd stream for decoders unaware of the format.
+// See https://github.com/facebook/zstd/blob/dev/contrib/seekable_format/zstd_seekable_compression_format.md.
+package zstdseekable
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	skippableMagic = 0x184D2A5E
+	seekableMagic  = 0x8F92EAB1
+	footerSize     = 9
+	checksumFlag   = 1 << 7
+)
+
+// ErrInvalidSeekTable is returned by NewReader when the input has no valid seek table.
+var ErrInvalidSeekTable = errors.New("zstdseekable: invalid seek table")
+
+// DefaultFrameSize is the default decompressed size of frames.
+const DefaultFrameSize = 1 << 20
+
+var encoder, _ = zstd.NewWriter(nil)
+
+var decoder, _ = zstd.NewReader(nil)
+
+// Writer compresses to the seekable format.
+type Writer struct {
+	w         io.Writer
+	frameSize int
+	buf       []byte
+	frames    []frame
+	err       error
+}
+
+type frame struct {
+	compressed, decompressed uint32
+}
+
+// NewWriter returns a Writer that writes frames of up to frameSize decompressed bytes to w.
+// DefaultFrameSize is used if frameSize is not positive.
+// Smaller frames make ranges cheaper to read and compress worse.
+func NewWriter(w io.Writer, frameSize int) *Writer {
+	if frameSize <= 0 {
+		frameSize = DefaultFrameSize
+	}
+	return &Writer{w: w, frameSize: frameSize}
+}
+
+// Write compresses p, full frames are written to the underlying writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := w.frameSize - len(w.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		w.buf = append(w.buf, p[:m]...)
+		p = p[m:]
+		if len(w.buf) == w.frameSize {
+			if err := w.flushFrame(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *Writer) flushFrame() error {
+	b := encoder.EncodeAll(w.buf, nil)
+	if _, err := w.w.Write(b); err != nil {
+		w.err = err
+		return err
+	}
+	w.frames = append(w.frames, frame{compressed: uint32(len(b)), decompressed: uint32(len(w.buf))})
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close writes the last frame and the seek table, it does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.flushFrame(); err != nil {
+			return err
+		}
+	}
+	size := len(w.frames)*8 + footerSize
+	b := make([]byte, 8, 8+size)
+	binary.LittleEndian.PutUint32(b[0:], skippableMagic)
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	for _, f := range w.frames {
+		b = appendUint32(b, f.compressed)
+		b = appendUint32(b, f.decompressed)
+	}
+	b = appendUint32(b, uint32(len(w.frames)))
+	b = append(b, 0)
+	b = appendUint32(b, seekableMagic)
+	_, err := w.w.Write(b)
+	w.err = errors.New("zstdseekable: writer is closed")
+	return err
+}
+
+// Reader reads the decompressed content of the seekable format at any offset.
+// It is safe for concurrent use.
+type Reader struct {
+	ra io.ReaderAt
+	// offsets of the frames, with the end of the last frame at the end.
+	compressedOffsets   []int64
+	decompressedOffsets []int64
+
+	mu     sync.Mutex
+	cached int
+	buf    []byte
+}
+
+// NewReader reads the seek table of the size bytes of ra.
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	if size < footerSize+8 {
+		return nil, ErrInvalidSeekTable
+	}
+	footer := make([]byte, footerSize)
+	if _, err := ra.ReadAt(footer, size-footerSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic || footer[4]&0x7c != 0 {
+		return nil, ErrInvalidSeekTable
+	}
+	n := int64(binary.LittleEndian.Uint32(footer))
+	entrySize := int64(8)
+	if footer[4]&checksumFlag != 0 {
+		entrySize = 12
+	}
+	tableSize := n*entrySize + footerSize
+	if tableSize+8 > size {
+		return nil, ErrInvalidSeekTable
+	}
+	table := make([]byte, tableSize+8)
+	if _, err := ra.ReadAt(table, size-tableSize-8); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table) != skippableMagic || int64(binary.LittleEndian.Uint32(table[4:])) != tableSize {
+		return nil, ErrInvalidSeekTable
+	}
+
+	r := &Reader{ra: ra, cached: -1}
+	r.compressedOffsets = make([]int64, n+1)
+	r.decompressedOffsets = make([]int64, n+1)
+	entries := table[8:]
+	for i := int64(0); i < n; i++ {
+		e := entries[i*entrySize:]
+		r.compressedOffsets[i+1] = r.compressedOffsets[i] + int64(binary.LittleEndian.Uint32(e))
+		r.decompressedOffsets[i+1] = r.decompressedOffsets[i] + int64(binary.LittleEndian.Uint32(e[4:]))
+	}
+	if r.compressedOffsets[n] != size-tableSize-8 {
+		return nil, ErrInvalidSeekTable
+	}
+	return r, nil
+}
+
+// Size returns the decompressed size.
+func (r *Reader) Size() int64 {
+	return r.decompressedOffsets[len(r.decompressedOffsets)-1]
+}
+
+// ReadAt reads the decompressed content at off, decompressing only the frames containing it.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("zstdseekable: negative offset")
+	}
+	n := 0
+	for len(p) > 0 {
+		if off >= r.Size() {
+			return n, io.EOF
+		}
+		// the frame containing off.
+		i := sort.Search(len(r.decompressedOffsets)-1, func(i int) bool {
+			return r.decompressedOffsets[i+1] > off
+		})
+		m, err := r.readFrame(i, p, off-r.decompressedOffsets[i])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// readFrame copies the decompressed frame i from off into p.
+func (r *Reader) readFrame(i int, p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cached != i {
+		compressed := make([]byte, r.compressedOffsets[i+1]-r.compressedOffsets[i])
+		if _, err := r.ra.ReadAt(compressed, r.compressedOffsets[i]); err != nil {
+			return 0, err
+		}
+		b, err := decoder.DecodeAll(compressed, r.buf[:0])
+		if err != nil {
+			r.cached = -1
+			return 0, err
+		}
+		if int64(len(b)) != r.decompressedOffsets[i+1]-r.decompressedOffsets[i] {
+			r.cached = -1
+			return 0, ErrInvalidSeekTable
+		}
+		r.buf = b
+		r.cached = i
+	}
+	return copy(p, r.buf[off:]), nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
@@ -0,0 +1,139 @@
+package zstdseekable_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/johejo/go-content-encoding/contentencodingtest"
+	"github.com/johejo/go-content-encoding/zstdseekable"
+)
+
+func compress(t *testing.T, content []byte, frameSize int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zstdseekable.NewWriter(&buf, frameSize)
+	// write in odd sizes to cross frame boundaries.
+	for b := content; len(b) > 0; {
+		n := 1000
+		if n > len(b) {
+			n = len(b)
+		}
+		if _, err := w.Write(b[:n]); err != nil {
+			t.Fatal(err)
+		}
+		b = b[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testContent() []byte {
+	content := make([]byte, 100_000)
+	rnd := rand.New(rand.NewSource(1))
+	for i := range content {
+		content[i] = "abcdefgh"[rnd.Intn(8)]
+	}
+	return content
+}
+
+func TestReader(t *testing.T) {
+	content := testContent()
+	compressed := compress(t, content, 4096)
+
+	// the output is a valid zstd stream.
+	b, err := contentencodingtest.DecompressBody(compressed, "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatal("decompressed content differs")
+	}
+
+	r, err := zstdseekable.NewReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(content)) {
+		t.Fatalf("size should be %d but got %d", len(content), r.Size())
+	}
+	for _, tt := range []struct{ off, n int }{{0, 10}, {4090, 10}, {50_000, 20_000}, {99_990, 10}, {0, 100_000}} {
+		t.Run(fmt.Sprintf("%d+%d", tt.off, tt.n), func(t *testing.T) {
+			p := make([]byte, tt.n)
+			if _, err := r.ReadAt(p, int64(tt.off)); err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(p, content[tt.off:tt.off+tt.n]) {
+				t.Error("read content differs")
+			}
+		})
+	}
+	if _, err := r.ReadAt(make([]byte, 10), int64(len(content)-5)); err != io.EOF {
+		t.Errorf("should be EOF but got %v", err)
+	}
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	b, err := contentencodingtest.CompressBody([]byte("test"), "zstd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zstdseekable.NewReader(bytes.NewReader(b), int64(len(b))); err != zstdseekable.ErrInvalidSeekTable {
+		t.Errorf("should be %v but got %v", zstdseekable.ErrInvalidSeekTable, err)
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	content := testContent()
+	compressed := compress(t, content, 4096)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zstdseekable.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(compressed), int64(len(compressed)))
+	})
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		rangeHeader    string
+		wantStatus     int
+		wantEncoding   string
+		want           []byte
+	}{
+		{"zstd", "gzip, zstd", "", http.StatusOK, "zstd", compressed},
+		{"identity", "gzip", "", http.StatusOK, "", content},
+		{"range", "zstd", "bytes=50000-50099", http.StatusPartialContent, "", content[50000:50100]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			resp := rec.Result()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("should be %d but got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding should be %q but got %q", tt.wantEncoding, got)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+				t.Errorf("unexpected Content-Type %s", got)
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			if !bytes.Equal(b, tt.want) {
+				t.Error("body differs")
+			}
+		})
+	}
+}